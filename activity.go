@@ -0,0 +1,471 @@
+package adb
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+/*
+Intent describes an activity to start, a service to start or a broadcast to send with the
+activity manager, e.g.
+	adb.Intent{
+		Action:     "android.intent.action.VIEW",
+		Data:       "https://example.com",
+		Categories: []string{"android.intent.category.BROWSABLE"},
+		Extras:     map[string]interface{}{"retries": 3, "tags": []string{"a", "b"}},
+	}
+*/
+type Intent struct {
+	Action string
+
+	// Data is the data URI of the intent.
+	Data     string
+	MimeType string
+
+	Categories []string
+
+	// Component is the component to deliver the intent to, e.g. "com.example.app/.MainActivity".
+	Component string
+
+	// Package limits the components the intent is delivered to to the ones of the package.
+	Package string
+
+	Flags IntentFlag
+
+	// Extras are the extras of the intent, by key. The type of each value must be one of the
+	// types supported by the activity manager:
+	//	nil: --esn, string: --es, bool: --ez
+	//	int and int32: --ei, int64: --el, float32: --ef, float64: --ed
+	//	ExtraURI: --eu, ExtraComponentName: --ecn
+	//	[]int and []int32: --eia, []int64: --ela, []float32: --efa, []string: --esa
+	//	ExtraIntList: --eial, ExtraLongList: --elal, ExtraFloatList: --efal, ExtraStringList: --esal
+	// float64 values require Android 11 or later.
+	Extras map[string]interface{}
+}
+
+// Extra types that can't be told apart from others by their Go type, see Intent.Extras.
+type (
+	// ExtraURI is an android.net.Uri.
+	ExtraURI string
+
+	// ExtraComponentName is an android.content.ComponentName, e.g. "com.example.app/.Main".
+	ExtraComponentName string
+
+	// The lists are ArrayLists, their slice counterparts are arrays.
+	ExtraIntList    []int32
+	ExtraLongList   []int64
+	ExtraFloatList  []float32
+	ExtraStringList []string
+)
+
+// IntentFlag is a flag of an Intent, as defined by android.content.Intent.
+type IntentFlag int
+
+const (
+	FlagGrantReadURIPermission    IntentFlag = 0x00000001
+	FlagGrantWriteURIPermission   IntentFlag = 0x00000002
+	FlagIncludeStoppedPackages    IntentFlag = 0x00000020
+	FlagActivityClearTask         IntentFlag = 0x00008000
+	FlagActivityNoAnimation       IntentFlag = 0x00010000
+	FlagActivityReorderToFront    IntentFlag = 0x00020000
+	FlagActivityExcludeFromRecent IntentFlag = 0x00800000
+	FlagActivityClearTop          IntentFlag = 0x04000000
+	FlagActivityMultipleTask      IntentFlag = 0x08000000
+	FlagActivityNewTask           IntentFlag = 0x10000000
+	FlagActivitySingleTop         IntentFlag = 0x20000000
+	FlagActivityNoHistory         IntentFlag = 0x40000000
+	FlagReceiverForeground        IntentFlag = 0x10000000
+	FlagReceiverRegisteredOnly    IntentFlag = 0x40000000
+)
+
+// args returns the arguments of am that describe the intent.
+func (i Intent) args() ([]string, error) {
+	var args []string
+	if i.Action != "" {
+		args = append(args, "-a", i.Action)
+	}
+	if i.Data != "" {
+		args = append(args, "-d", i.Data)
+	}
+	if i.MimeType != "" {
+		args = append(args, "-t", i.MimeType)
+	}
+	for _, category := range i.Categories {
+		args = append(args, "-c", category)
+	}
+
+	// Sort the extras so the arguments are always the same.
+	keys := make([]string, 0, len(i.Extras))
+	for key := range i.Extras {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		extra, err := intentExtraArgs(key, i.Extras[key])
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, extra...)
+	}
+
+	if i.Flags != 0 {
+		args = append(args, "-f", fmt.Sprintf("0x%x", int(i.Flags)))
+	}
+	if i.Package != "" {
+		args = append(args, "-p", i.Package)
+	}
+	if i.Component != "" {
+		args = append(args, "-n", i.Component)
+	}
+	if len(args) == 0 {
+		return nil, errors.AssertionErrorf("intent is empty")
+	}
+	return args, nil
+}
+
+func intentExtraArgs(key string, value interface{}) ([]string, error) {
+	var flag string
+	var values []string
+	switch value := value.(type) {
+	case nil:
+		return []string{"--esn", key}, nil
+	case string:
+		flag, values = "--es", []string{value}
+	case bool:
+		flag, values = "--ez", []string{strconv.FormatBool(value)}
+	case int:
+		if value < math.MinInt32 || value > math.MaxInt32 {
+			return nil, errors.AssertionErrorf("extra %s overflows an int, use an int64", key)
+		}
+		flag, values = "--ei", []string{strconv.Itoa(value)}
+	case int32:
+		flag, values = "--ei", []string{strconv.Itoa(int(value))}
+	case int64:
+		flag, values = "--el", []string{strconv.FormatInt(value, 10)}
+	case float32:
+		flag, values = "--ef", []string{strconv.FormatFloat(float64(value), 'g', -1, 32)}
+	case float64:
+		flag, values = "--ed", []string{strconv.FormatFloat(value, 'g', -1, 64)}
+	case ExtraURI:
+		flag, values = "--eu", []string{string(value)}
+	case ExtraComponentName:
+		flag, values = "--ecn", []string{string(value)}
+	case []int:
+		flag = "--eia"
+		for _, v := range value {
+			if v < math.MinInt32 || v > math.MaxInt32 {
+				return nil, errors.AssertionErrorf("extra %s overflows an int, use an []int64", key)
+			}
+			values = append(values, strconv.Itoa(v))
+		}
+	case []int32:
+		flag, values = "--eia", formatInt32s(value)
+	case ExtraIntList:
+		flag, values = "--eial", formatInt32s(value)
+	case []int64:
+		flag, values = "--ela", formatInt64s(value)
+	case ExtraLongList:
+		flag, values = "--elal", formatInt64s(value)
+	case []float32:
+		flag, values = "--efa", formatFloat32s(value)
+	case ExtraFloatList:
+		flag, values = "--efal", formatFloat32s(value)
+	case []string:
+		flag, values = "--esa", escapeStringArray(value)
+	case ExtraStringList:
+		flag, values = "--esal", escapeStringArray(value)
+	default:
+		return nil, errors.AssertionErrorf("extra %s has unsupported type %T", key, value)
+	}
+	// Arrays are separated by commas.
+	return []string{flag, key, strings.Join(values, ",")}, nil
+}
+
+func formatInt32s(values []int32) []string {
+	formatted := make([]string, len(values))
+	for i, v := range values {
+		formatted[i] = strconv.FormatInt(int64(v), 10)
+	}
+	return formatted
+}
+
+func formatInt64s(values []int64) []string {
+	formatted := make([]string, len(values))
+	for i, v := range values {
+		formatted[i] = strconv.FormatInt(v, 10)
+	}
+	return formatted
+}
+
+func formatFloat32s(values []float32) []string {
+	formatted := make([]string, len(values))
+	for i, v := range values {
+		formatted[i] = strconv.FormatFloat(float64(v), 'g', -1, 32)
+	}
+	return formatted
+}
+
+// escapeStringArray escapes the commas of the strings, which would otherwise separate them.
+func escapeStringArray(values []string) []string {
+	escaped := make([]string, len(values))
+	for i, v := range values {
+		escaped[i] = strings.ReplaceAll(v, ",", `\,`)
+	}
+	return escaped
+}
+
+// LaunchState is how an activity was launched, reported since Android 10.
+type LaunchState string
+
+const (
+	// LaunchCold is a launch that had to start the process of the activity.
+	LaunchCold LaunchState = "COLD"
+
+	// LaunchWarm is a launch that had to create the activity in its running process.
+	LaunchWarm LaunchState = "WARM"
+
+	// LaunchHot is a launch that only brought the existing activity to the front.
+	LaunchHot LaunchState = "HOT"
+
+	LaunchRelaunch LaunchState = "RELAUNCH"
+	LaunchUnknown  LaunchState = "UNKNOWN"
+)
+
+// StartActivityOptions configures Device.StartActivityWithOptions.
+type StartActivityOptions struct {
+	// Wait waits for the activity to be launched, and reports how long it took.
+	Wait bool
+
+	// ForceStop stops the app before starting the activity, so it's launched cold.
+	ForceStop bool
+
+	// User is the id of the user to start the activity as, or "current", the current user if
+	// empty.
+	User string
+
+	// Display is the id of the display to start the activity on, 0 for the default display.
+	// Other displays require Android 10 or later.
+	Display int
+}
+
+func (opts StartActivityOptions) args() []string {
+	var args []string
+	if opts.Wait {
+		args = append(args, "-W")
+	}
+	if opts.ForceStop {
+		args = append(args, "-S")
+	}
+	if opts.User != "" {
+		args = append(args, "--user", opts.User)
+	}
+	if opts.Display != 0 {
+		args = append(args, "--display", strconv.Itoa(opts.Display))
+	}
+	return args
+}
+
+// ActivityLaunch is the result of starting an activity. Only Warning is set unless the
+// activity manager waited for the launch.
+type ActivityLaunch struct {
+	// Status is "ok" once the activity was launched, "timeout" if it took too long.
+	Status string
+
+	// Activity is the component of the launched activity, e.g. "com.example.app/.Main".
+	Activity string
+
+	LaunchState LaunchState
+
+	// TotalTime is how long launching the activity took, including starting its process.
+	TotalTime time.Duration
+
+	// WaitTime is how long the activity manager waited, including the time it took to pause
+	// the previous activity.
+	WaitTime time.Duration
+
+	// ThisTime is how long launching the last activity took, when an app launches more than
+	// one. Only reported before Android 10.
+	ThisTime time.Duration
+
+	// Warning is set when the activity wasn't started anew, e.g. "Activity not started, its
+	// current task has been brought to the front".
+	Warning string
+}
+
+// StartActivity starts the activity that intent resolves to, as the current user.
+func (c *Device) StartActivity(intent Intent) (*ActivityLaunch, error) {
+	return c.StartActivityContext(context.Background(), intent)
+}
+
+// StartActivityContext is like StartActivity, but gives up as soon as ctx is done.
+func (c *Device) StartActivityContext(ctx context.Context, intent Intent) (*ActivityLaunch, error) {
+	return c.StartActivityWithOptions(ctx, intent, StartActivityOptions{})
+}
+
+/*
+StartActivityWithOptions is like StartActivityContext, but allows waiting for the launch, e.g.
+to measure the startup time of an app:
+	launch, err := device.StartActivityWithOptions(ctx, adb.Intent{Component: "com.example.app/.Main"},
+		adb.StartActivityOptions{Wait: true, ForceStop: true})
+	fmt.Println(launch.LaunchState, launch.TotalTime)
+*/
+func (c *Device) StartActivityWithOptions(ctx context.Context, intent Intent, opts StartActivityOptions) (*ActivityLaunch, error) {
+	intentArgs, err := intent.args()
+	if err != nil {
+		return nil, wrapClientError(err, c, "StartActivity")
+	}
+	args := append(append([]string{"start"}, opts.args()...), intentArgs...)
+	output, err := c.runServiceCommand(ctx, "activity", nil, 0, args...)
+	if err == nil {
+		err = parseServiceOutput("activity", "start", output)
+	}
+	if err != nil {
+		return nil, wrapClientError(err, c, "StartActivity")
+	}
+	launch, err := parseActivityLaunch(output)
+	return launch, wrapClientError(err, c, "StartActivity")
+}
+
+// StartService starts the service that intent resolves to, as the current user. Since Android
+// 8, apps that are in the background can't start services that way.
+func (c *Device) StartService(intent Intent) error {
+	return c.StartServiceContext(context.Background(), intent)
+}
+
+// StartServiceContext is like StartService, but gives up as soon as ctx is done.
+func (c *Device) StartServiceContext(ctx context.Context, intent Intent) error {
+	return wrapClientError(c.runIntentCommand(ctx, "startservice", intent), c, "StartService")
+}
+
+// BroadcastResult is the result of a broadcast, as set by the receivers of an ordered broadcast.
+type BroadcastResult struct {
+	Code int
+	Data string
+
+	// Extras is the result bundle as formatted by the activity manager, e.g.
+	// "Bundle[{key=value}]", empty if there's none.
+	Extras string
+}
+
+// Broadcast sends intent to the broadcast receivers of all users, and waits for them to have
+// received it.
+func (c *Device) Broadcast(intent Intent) (*BroadcastResult, error) {
+	return c.BroadcastContext(context.Background(), intent)
+}
+
+// BroadcastContext is like Broadcast, but gives up as soon as ctx is done.
+func (c *Device) BroadcastContext(ctx context.Context, intent Intent) (*BroadcastResult, error) {
+	intentArgs, err := intent.args()
+	if err != nil {
+		return nil, wrapClientError(err, c, "Broadcast")
+	}
+	output, err := c.runServiceCommand(ctx, "activity", nil, 0, append([]string{"broadcast"}, intentArgs...)...)
+	if err == nil {
+		err = parseServiceOutput("activity", "broadcast", output)
+	}
+	if err != nil {
+		return nil, wrapClientError(err, c, "Broadcast")
+	}
+	result, err := parseBroadcastResult(output)
+	return result, wrapClientError(err, c, "Broadcast")
+}
+
+// ForceStop stops everything associated with the package packageName: its processes, services
+// and alarms.
+func (c *Device) ForceStop(packageName string) error {
+	return c.ForceStopContext(context.Background(), packageName)
+}
+
+// ForceStopContext is like ForceStop, but gives up as soon as ctx is done.
+func (c *Device) ForceStopContext(ctx context.Context, packageName string) error {
+	output, err := c.runServiceCommand(ctx, "activity", nil, 0, "force-stop", packageName)
+	if err == nil {
+		err = parseServiceOutput("activity", "force-stop", output)
+	}
+	return wrapClientError(err, c, "ForceStop(%s)", packageName)
+}
+
+func (c *Device) runIntentCommand(ctx context.Context, command string, intent Intent) error {
+	intentArgs, err := intent.args()
+	if err != nil {
+		return err
+	}
+	output, err := c.runServiceCommand(ctx, "activity", nil, 0, append([]string{command}, intentArgs...)...)
+	if err != nil {
+		return err
+	}
+	return parseServiceOutput("activity", command, output)
+}
+
+/*
+parseActivityLaunch parses the output of am start, e.g.
+	Starting: Intent { cmp=com.example.app/.Main }
+	Status: ok
+	LaunchState: COLD
+	Activity: com.example.app/.Main
+	TotalTime: 512
+	WaitTime: 520
+	Complete
+*/
+func parseActivityLaunch(output string) (*ActivityLaunch, error) {
+	launch := &ActivityLaunch{}
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), ": ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, value := parts[0], strings.TrimSpace(parts[1])
+		var duration *time.Duration
+		switch key {
+		case "Status":
+			launch.Status = value
+		case "Activity":
+			launch.Activity = value
+		case "LaunchState":
+			launch.LaunchState = LaunchState(value)
+		case "Warning":
+			launch.Warning = value
+		case "TotalTime":
+			duration = &launch.TotalTime
+		case "WaitTime":
+			duration = &launch.WaitTime
+		case "ThisTime":
+			duration = &launch.ThisTime
+		}
+		if duration != nil {
+			ms, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, errors.WrapErrorf(err, errors.ParseError, "invalid launch time: %s", line)
+			}
+			*duration = time.Duration(ms) * time.Millisecond
+		}
+	}
+	return launch, nil
+}
+
+var reBroadcastResult = regexp.MustCompile(`^Broadcast completed: result=(-?\d+)(?:, data="(.*?)")?(?:, extras: (.*))?$`)
+
+/*
+parseBroadcastResult parses the output of am broadcast, e.g.
+	Broadcasting: Intent { act=com.example.ACTION flg=0x400000 }
+	Broadcast completed: result=-1, data="done"
+*/
+func parseBroadcastResult(output string) (*BroadcastResult, error) {
+	for _, line := range strings.Split(output, "\n") {
+		matches := reBroadcastResult.FindStringSubmatch(strings.TrimSpace(line))
+		if matches == nil {
+			continue
+		}
+		code, _ := strconv.Atoi(matches[1])
+		return &BroadcastResult{Code: code, Data: matches[2], Extras: matches[3]}, nil
+	}
+	return nil, errors.Errorf(errors.ParseError, "no broadcast result in output: %s", strings.TrimSpace(output))
+}
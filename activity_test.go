@@ -0,0 +1,158 @@
+package adb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/kvnxiao/go-adb/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntentArgs(t *testing.T) {
+	args, err := Intent{
+		Action:     "android.intent.action.VIEW",
+		Data:       "https://example.com/?a=1&b=2",
+		MimeType:   "text/html",
+		Categories: []string{"android.intent.category.DEFAULT", "android.intent.category.BROWSABLE"},
+		Component:  "com.example.app/.MainActivity",
+		Flags:      FlagActivityNewTask | FlagActivityClearTask,
+		Extras: map[string]interface{}{
+			"string":    "hello world",
+			"null":      nil,
+			"bool":      true,
+			"int":       42,
+			"int32":     int32(-1),
+			"long":      int64(1) << 40,
+			"float":     float32(1.5),
+			"double":    0.25,
+			"uri":       ExtraURI("content://contacts/people/1"),
+			"component": ExtraComponentName("com.example.app/.Receiver"),
+			"ints":      []int{1, 2},
+			"longs":     []int64{3},
+			"floats":    []float32{0.5, 1},
+			"strings":   []string{"a,b", "c"},
+			"int-list":  ExtraIntList{4, 5},
+			"long-list": ExtraLongList{6},
+			"flt-list":  ExtraFloatList{2.5},
+			"str-list":  ExtraStringList{"d"},
+		},
+	}.args()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"-a", "android.intent.action.VIEW",
+		"-d", "https://example.com/?a=1&b=2",
+		"-t", "text/html",
+		"-c", "android.intent.category.DEFAULT",
+		"-c", "android.intent.category.BROWSABLE",
+		"--ez", "bool", "true",
+		"--ecn", "component", "com.example.app/.Receiver",
+		"--ed", "double", "0.25",
+		"--ef", "float", "1.5",
+		"--efa", "floats", "0.5,1",
+		"--efal", "flt-list", "2.5",
+		"--ei", "int", "42",
+		"--eial", "int-list", "4,5",
+		"--ei", "int32", "-1",
+		"--eia", "ints", "1,2",
+		"--el", "long", "1099511627776",
+		"--elal", "long-list", "6",
+		"--ela", "longs", "3",
+		"--esn", "null",
+		"--esal", "str-list", "d",
+		"--es", "string", "hello world",
+		"--esa", "strings", `a\,b,c`,
+		"--eu", "uri", "content://contacts/people/1",
+		"-f", "0x10008000",
+		"-n", "com.example.app/.MainActivity",
+	}, args)
+
+	args, err = Intent{Action: "com.example.PING", Package: "com.example.app"}.args()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-a", "com.example.PING", "-p", "com.example.app"}, args)
+
+	for _, intent := range []Intent{
+		{},
+		{Action: "a", Extras: map[string]interface{}{"x": struct{}{}}},
+		{Action: "a", Extras: map[string]interface{}{"x": 1 << 40}},
+	} {
+		_, err := intent.args()
+		assert.Equal(t, errors.AssertionError, err.(*errors.Err).Code, "%+v", intent)
+	}
+}
+
+func TestParseActivityLaunch(t *testing.T) {
+	launch, err := parseActivityLaunch("Starting: Intent { cmp=com.example.app/.Main }\n" +
+		"Status: ok\n" +
+		"LaunchState: COLD\n" +
+		"Activity: com.example.app/.Main\n" +
+		"TotalTime: 512\n" +
+		"WaitTime: 520\n" +
+		"Complete\n")
+	require.NoError(t, err)
+	assert.Equal(t, &ActivityLaunch{
+		Status:      "ok",
+		Activity:    "com.example.app/.Main",
+		LaunchState: LaunchCold,
+		TotalTime:   512 * time.Millisecond,
+		WaitTime:    520 * time.Millisecond,
+	}, launch)
+
+	launch, err = parseActivityLaunch("Starting: Intent { cmp=com.example.app/.Main }\n" +
+		"Warning: Activity not started, its current task has been brought to the front\n" +
+		"Status: ok\n" +
+		"Activity: com.example.app/.Main\n" +
+		"ThisTime: 80\n" +
+		"TotalTime: 80\n" +
+		"WaitTime: 95\n" +
+		"Complete\n")
+	require.NoError(t, err)
+	assert.Equal(t, "Activity not started, its current task has been brought to the front", launch.Warning)
+	assert.Equal(t, 80*time.Millisecond, launch.ThisTime)
+	assert.Equal(t, LaunchState(""), launch.LaunchState)
+
+	_, err = parseActivityLaunch("TotalTime: soon\n")
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+}
+
+func TestParseBroadcastResult(t *testing.T) {
+	result, err := parseBroadcastResult("Broadcasting: Intent { act=com.example.PING flg=0x400000 }\n" +
+		"Broadcast completed: result=0\n")
+	require.NoError(t, err)
+	assert.Equal(t, &BroadcastResult{}, result)
+
+	result, err = parseBroadcastResult(`Broadcast completed: result=-1, data="say "hi", bye", extras: Bundle[{count=2}]` + "\n")
+	require.NoError(t, err)
+	assert.Equal(t, &BroadcastResult{Code: -1, Data: `say "hi", bye`, Extras: "Bundle[{count=2}]"}, result)
+
+	_, err = parseBroadcastResult("Broadcasting: Intent { act=com.example.PING }\n")
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+}
+
+func TestStartActivity(t *testing.T) {
+	s := &MockServer{
+		Status: wire.StatusSuccess,
+		Messages: []string{"cmd", "Starting: Intent { cmp=com.example.app/.Main }\n" +
+			"Status: ok\nLaunchState: WARM\nActivity: com.example.app/.Main\nTotalTime: 210\nWaitTime: 215\nComplete\n"},
+	}
+	client := (&Adb{s}).Device(DeviceWithSerial("abc"))
+	launch, err := client.StartActivityWithOptions(context.Background(),
+		Intent{Component: "com.example.app/.Main", Extras: map[string]interface{}{"name": "it's me"}},
+		StartActivityOptions{Wait: true, ForceStop: true})
+	require.NoError(t, err)
+	assert.Equal(t, `exec:cmd activity start -W -S --es name 'it'\''s me' -n com.example.app/.Main`, s.Requests[2])
+	assert.Equal(t, LaunchWarm, launch.LaunchState)
+	assert.Equal(t, 210*time.Millisecond, launch.TotalTime)
+}
+
+func TestForceStop(t *testing.T) {
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"cmd", ""},
+	}
+	client := (&Adb{s}).Device(DeviceWithSerial("abc"))
+	assert.NoError(t, client.ForceStop("com.example.app"))
+	assert.Equal(t, "exec:cmd activity force-stop com.example.app", s.Requests[2])
+}
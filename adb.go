@@ -1,6 +1,8 @@
 package adb
 
 import (
+	"context"
+	"fmt"
 	"strconv"
 
 	"github.com/kvnxiao/go-adb/internal/errors"
@@ -39,6 +41,11 @@ func (c *Adb) Dial() (*wire.Conn, error) {
 	return c.server.Dial()
 }
 
+// DialContext is like Dial, but the connection is closed as soon as ctx is done.
+func (c *Adb) DialContext(ctx context.Context) (*wire.Conn, error) {
+	return c.server.DialContext(ctx)
+}
+
 // Starts the adb server if it’s not running.
 func (c *Adb) StartServer() error {
 	return c.server.Start()
@@ -48,17 +55,41 @@ func (c *Adb) Device(descriptor DeviceDescriptor) *Device {
 	return &Device{
 		server:         c.server,
 		descriptor:     descriptor,
-		deviceListFunc: c.ListDevices,
+		deviceListFunc: c.ListDevicesContext,
 	}
 }
 
 func (c *Adb) NewDeviceWatcher() *DeviceWatcher {
-	return newDeviceWatcher(c.server)
+	return c.NewDeviceWatcherContext(context.Background())
+}
+
+// NewDeviceWatcherContext is like NewDeviceWatcher, but the watcher stops as soon as ctx is done.
+// Its channel is then closed and Err returns a ContextCanceled error.
+func (c *Adb) NewDeviceWatcherContext(ctx context.Context) *DeviceWatcher {
+	return c.NewDeviceWatcherWithConfig(ctx, DeviceWatcherConfig{})
+}
+
+// NewDeviceWatcherWithConfig is like NewDeviceWatcherContext, but allows configuring how
+// the watcher behaves, e.g. when reconnecting after the server dies.
+func (c *Adb) NewDeviceWatcherWithConfig(ctx context.Context, config DeviceWatcherConfig) *DeviceWatcher {
+	return newDeviceWatcher(ctx, c.server, config)
+}
+
+// NewDeviceInfoWatcher returns a watcher that tracks the long form of the device list.
+// Unlike DeviceWatcher, its events carry the attributes of devices and are also sent when
+// only the attributes of a device change.
+func (c *Adb) NewDeviceInfoWatcher(ctx context.Context, config DeviceWatcherConfig) *DeviceInfoWatcher {
+	return newDeviceInfoWatcher(ctx, c.server, config)
 }
 
 // ServerVersion asks the ADB server for its internal version number.
 func (c *Adb) ServerVersion() (int, error) {
-	resp, err := roundTripSingleResponse(c.server, "host:version")
+	return c.ServerVersionContext(context.Background())
+}
+
+// ServerVersionContext is like ServerVersion, but gives up as soon as ctx is done.
+func (c *Adb) ServerVersionContext(ctx context.Context) (int, error) {
+	resp, err := roundTripSingleResponse(ctx, c.server, "host:version")
 	if err != nil {
 		return 0, wrapClientError(err, c, "GetServerVersion")
 	}
@@ -77,14 +108,19 @@ Corresponds to the command:
 	adb kill-server
 */
 func (c *Adb) KillServer() error {
-	conn, err := c.server.Dial()
+	return c.KillServerContext(context.Background())
+}
+
+// KillServerContext is like KillServer, but gives up as soon as ctx is done.
+func (c *Adb) KillServerContext(ctx context.Context) error {
+	conn, err := c.server.DialContext(ctx)
 	if err != nil {
 		return wrapClientError(err, c, "KillServer")
 	}
 	defer conn.Close()
 
 	if err = wire.SendMessageString(conn, "host:kill"); err != nil {
-		return wrapClientError(err, c, "KillServer")
+		return wrapClientError(wrapContextErr(ctx, err), c, "KillServer")
 	}
 
 	return nil
@@ -97,7 +133,12 @@ Corresponds to the command:
 	adb devices
 */
 func (c *Adb) ListDeviceSerials() ([]string, error) {
-	resp, err := roundTripSingleResponse(c.server, "host:devices")
+	return c.ListDeviceSerialsContext(context.Background())
+}
+
+// ListDeviceSerialsContext is like ListDeviceSerials, but gives up as soon as ctx is done.
+func (c *Adb) ListDeviceSerialsContext(ctx context.Context) ([]string, error) {
+	resp, err := roundTripSingleResponse(ctx, c.server, "host:devices")
 	if err != nil {
 		return nil, wrapClientError(err, c, "ListDeviceSerials")
 	}
@@ -121,7 +162,12 @@ Corresponds to the command:
 	adb devices -l
 */
 func (c *Adb) ListDevices() ([]*DeviceInfo, error) {
-	resp, err := roundTripSingleResponse(c.server, "host:devices-l")
+	return c.ListDevicesContext(context.Background())
+}
+
+// ListDevicesContext is like ListDevices, but gives up as soon as ctx is done.
+func (c *Adb) ListDevicesContext(ctx context.Context) ([]*DeviceInfo, error) {
+	resp, err := roundTripSingleResponse(ctx, c.server, "host:devices-l")
 	if err != nil {
 		return nil, wrapClientError(err, c, "ListDevices")
 	}
@@ -140,7 +186,12 @@ Corresponds to the command:
 	adb connect
 */
 func (c *Adb) Connect(host string, port int) error {
-	_, err := roundTripSingleResponse(c.server, fmt.Sprintf("host:connect:%s:%d", host, port))
+	return c.ConnectContext(context.Background(), host, port)
+}
+
+// ConnectContext is like Connect, but gives up as soon as ctx is done.
+func (c *Adb) ConnectContext(ctx context.Context, host string, port int) error {
+	_, err := roundTripSingleResponse(ctx, c.server, fmt.Sprintf("host:connect:%s:%d", host, port))
 	if err != nil {
 		return wrapClientError(err, c, "Connect")
 	}
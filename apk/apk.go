@@ -0,0 +1,229 @@
+/*
+Package apk reads the manifest of APK files on the host, e.g. to inspect an app before
+installing it on a device, without the Android SDK:
+	m, err := apk.Open("app-release.apk")
+	fmt.Println(m.Package, m.VersionCode, m.LaunchableActivity)
+
+APKs are zip archives whose AndroidManifest.xml is compiled to a binary XML format, which
+DecodeXML decodes. Values that are references to resources, e.g. a versionName of
+@string/version, aren't resolved since that would need resources.arsc.
+*/
+package apk
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// AndroidNamespace is the namespace URI of the android: attributes.
+const AndroidNamespace = "http://schemas.android.com/apk/res/android"
+
+// ManifestPath is the path of the manifest in an APK.
+const ManifestPath = "AndroidManifest.xml"
+
+// Resource ids of the android: attributes used to build a Manifest.
+const (
+	attrName             = 0x01010003
+	attrEnabled          = 0x0101000e
+	attrMinSdkVersion    = 0x0101020c
+	attrVersionCode      = 0x0101021b
+	attrVersionName      = 0x0101021c
+	attrTargetSdkVersion = 0x01010270
+	attrVersionCodeMajor = 0x01010576
+)
+
+const (
+	actionMain       = "android.intent.action.MAIN"
+	categoryLauncher = "android.intent.category.LAUNCHER"
+)
+
+// ErrNoManifest is returned when an APK has no AndroidManifest.xml.
+var ErrNoManifest = errors.New("apk: no " + ManifestPath)
+
+// Manifest is the information about an app from its AndroidManifest.xml.
+type Manifest struct {
+	Package string
+
+	// VersionCode includes versionCodeMajor in its high 32 bits, like the versionCode reported
+	// by the package manager.
+	VersionCode int64
+	VersionName string
+
+	// MinSdk is 1 if the manifest doesn't set it, and TargetSdk is MinSdk if it doesn't.
+	MinSdk    int
+	TargetSdk int
+
+	// Split is the name of the split if the APK is a split of an app bundle, empty for the
+	// base APK.
+	Split string
+
+	// Permissions are the permissions requested by uses-permission and uses-permission-sdk-23,
+	// in the order of the manifest.
+	Permissions []string
+
+	// LaunchableActivity is the fully qualified name of the first enabled activity or
+	// activity-alias with a MAIN action and LAUNCHER category, empty if there is none.
+	LaunchableActivity string
+
+	// XML is the decoded manifest, for the information that Manifest doesn't have.
+	XML *Element
+}
+
+// Open reads the manifest of the APK at path.
+func Open(path string) (*Manifest, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readManifest(&r.Reader)
+}
+
+// Read reads the manifest of the APK in r, which has size bytes.
+func Read(r io.ReaderAt, size int64) (*Manifest, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return readManifest(zr)
+}
+
+func readManifest(r *zip.Reader) (*Manifest, error) {
+	for _, file := range r.File {
+		if file.Name != ManifestPath {
+			continue
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		data, err := ioutil.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		return ParseManifest(data)
+	}
+	return nil, ErrNoManifest
+}
+
+// ParseManifest parses a binary AndroidManifest.xml.
+func ParseManifest(data []byte) (*Manifest, error) {
+	root, err := DecodeXML(data)
+	if err != nil {
+		return nil, err
+	}
+	if root.Name != "manifest" {
+		return nil, fmt.Errorf("%w: root element is %s, not manifest", ErrInvalidXML, root.Name)
+	}
+
+	m := &Manifest{XML: root, MinSdk: 1}
+	if attr := root.Attr("", "package", 0); attr != nil {
+		m.Package = attr.Value
+	}
+	if m.Package == "" {
+		return nil, fmt.Errorf("%w: manifest has no package", ErrInvalidXML)
+	}
+	if attr := root.Attr("", "split", 0); attr != nil {
+		m.Split = attr.Value
+	}
+	if attr := root.Attr(AndroidNamespace, "versionCode", attrVersionCode); attr != nil {
+		code, _ := attr.Int()
+		m.VersionCode = int64(uint32(code))
+	}
+	if attr := root.Attr(AndroidNamespace, "versionCodeMajor", attrVersionCodeMajor); attr != nil {
+		major, _ := attr.Int()
+		m.VersionCode |= major << 32
+	}
+	if attr := root.Attr(AndroidNamespace, "versionName", attrVersionName); attr != nil {
+		m.VersionName = attr.Value
+	}
+
+	targetSdk := 0
+	for _, sdk := range root.ChildrenNamed("uses-sdk") {
+		if attr := sdk.Attr(AndroidNamespace, "minSdkVersion", attrMinSdkVersion); attr != nil {
+			if n, ok := attr.Int(); ok {
+				m.MinSdk = int(n)
+			}
+		}
+		if attr := sdk.Attr(AndroidNamespace, "targetSdkVersion", attrTargetSdkVersion); attr != nil {
+			if n, ok := attr.Int(); ok {
+				targetSdk = int(n)
+			}
+		}
+	}
+	m.TargetSdk = targetSdk
+	if m.TargetSdk == 0 {
+		m.TargetSdk = m.MinSdk
+	}
+
+	for _, child := range root.Children {
+		if child.Name != "uses-permission" && child.Name != "uses-permission-sdk-23" {
+			continue
+		}
+		if attr := child.Attr(AndroidNamespace, "name", attrName); attr != nil {
+			m.Permissions = append(m.Permissions, attr.Value)
+		}
+	}
+
+	for _, app := range root.ChildrenNamed("application") {
+		if m.LaunchableActivity = findLaunchableActivity(m.Package, app); m.LaunchableActivity != "" {
+			break
+		}
+	}
+	return m, nil
+}
+
+// findLaunchableActivity returns the name of the first launchable activity of app.
+func findLaunchableActivity(packageName string, app *Element) string {
+	for _, activity := range app.Children {
+		if activity.Name != "activity" && activity.Name != "activity-alias" {
+			continue
+		}
+		if attr := activity.Attr(AndroidNamespace, "enabled", attrEnabled); attr != nil && attr.Value == "false" {
+			continue
+		}
+		name := activity.Attr(AndroidNamespace, "name", attrName)
+		if name == nil || !isLauncher(activity) {
+			continue
+		}
+		return qualifyClassName(packageName, name.Value)
+	}
+	return ""
+}
+
+// isLauncher returns true if activity has an intent filter with the MAIN action and the
+// LAUNCHER category.
+func isLauncher(activity *Element) bool {
+	for _, filter := range activity.ChildrenNamed("intent-filter") {
+		var main, launcher bool
+		for _, child := range filter.Children {
+			attr := child.Attr(AndroidNamespace, "name", attrName)
+			if attr == nil {
+				continue
+			}
+			main = main || child.Name == "action" && attr.Value == actionMain
+			launcher = launcher || child.Name == "category" && attr.Value == categoryLauncher
+		}
+		if main && launcher {
+			return true
+		}
+	}
+	return false
+}
+
+// qualifyClassName returns the fully qualified name of a class of the package, whose name may
+// be relative to the package, e.g. ".MainActivity" or "MainActivity".
+func qualifyClassName(packageName, name string) string {
+	if strings.HasPrefix(name, ".") {
+		return packageName + name
+	}
+	if !strings.Contains(name, ".") {
+		return packageName + "." + name
+	}
+	return name
+}
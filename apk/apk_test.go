@@ -0,0 +1,137 @@
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	stderrors "errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testManifest() testElement {
+	name := func(tag, value string) testElement {
+		return testElement{name: tag, attrs: []testAttr{androidString("name", attrName, value)}}
+	}
+	launcherFilter := testElement{name: "intent-filter", children: []testElement{
+		name("action", actionMain),
+		name("category", categoryLauncher),
+	}}
+
+	return testElement{
+		name: "manifest",
+		attrs: []testAttr{
+			androidAttr("versionCode", attrVersionCode, TypeIntDec, 42),
+			androidAttr("versionCodeMajor", attrVersionCodeMajor, TypeIntDec, 1),
+			androidString("versionName", attrVersionName, "1.2.3"),
+			{name: "package", typ: TypeString, str: "com.example.app"},
+		},
+		children: []testElement{
+			{name: "uses-sdk", attrs: []testAttr{
+				androidAttr("minSdkVersion", attrMinSdkVersion, TypeIntDec, 24),
+				androidAttr("targetSdkVersion", attrTargetSdkVersion, TypeIntDec, 33),
+			}},
+			name("uses-permission", "android.permission.INTERNET"),
+			name("uses-permission-sdk-23", "android.permission.CAMERA"),
+			{name: "application", children: []testElement{
+				{name: "activity", attrs: []testAttr{androidString("name", attrName, ".SettingsActivity")}},
+				{
+					name: "activity",
+					attrs: []testAttr{
+						androidString("name", attrName, ".DisabledActivity"),
+						androidAttr("enabled", attrEnabled, TypeIntBool, 0),
+					},
+					children: []testElement{launcherFilter},
+				},
+				{
+					name:     "activity-alias",
+					attrs:    []testAttr{androidString("name", attrName, "MainActivity")},
+					children: []testElement{launcherFilter},
+				},
+			}},
+		},
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	m, err := ParseManifest(encodeTestXML(testManifest(), false))
+	require.NoError(t, err)
+	assert.Equal(t, "com.example.app", m.Package)
+	assert.Equal(t, int64(1<<32|42), m.VersionCode)
+	assert.Equal(t, "1.2.3", m.VersionName)
+	assert.Equal(t, 24, m.MinSdk)
+	assert.Equal(t, 33, m.TargetSdk)
+	assert.Equal(t, "", m.Split)
+	assert.Equal(t, []string{"android.permission.INTERNET", "android.permission.CAMERA"}, m.Permissions)
+	assert.Equal(t, "com.example.app.MainActivity", m.LaunchableActivity)
+	assert.Equal(t, "manifest", m.XML.Name)
+}
+
+func TestParseManifestDefaults(t *testing.T) {
+	m, err := ParseManifest(encodeTestXML(testElement{
+		name: "manifest",
+		attrs: []testAttr{
+			{name: "package", typ: TypeString, str: "com.example.app"},
+			{name: "split", typ: TypeString, str: "config.arm64_v8a"},
+			// Obfuscated attribute names are identified by their resource id.
+			androidAttr("a0", attrVersionCode, TypeIntDec, 7),
+		},
+	}, true))
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), m.VersionCode)
+	assert.Equal(t, "config.arm64_v8a", m.Split)
+	assert.Equal(t, 1, m.MinSdk)
+	assert.Equal(t, 1, m.TargetSdk)
+	assert.Equal(t, "", m.LaunchableActivity)
+
+	_, err = ParseManifest(encodeTestXML(testElement{name: "resources"}, true))
+	assert.True(t, stderrors.Is(err, ErrInvalidXML))
+	_, err = ParseManifest(encodeTestXML(testElement{name: "manifest"}, true))
+	assert.True(t, stderrors.Is(err, ErrInvalidXML))
+}
+
+func TestQualifyClassName(t *testing.T) {
+	assert.Equal(t, "com.example.app.Main", qualifyClassName("com.example.app", ".Main"))
+	assert.Equal(t, "com.example.app.Main", qualifyClassName("com.example.app", "Main"))
+	assert.Equal(t, "com.other.Main", qualifyClassName("com.example.app", "com.other.Main"))
+}
+
+func writeTestAPK(t *testing.T, files map[string][]byte) string {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	path := filepath.Join(t.TempDir(), "app.apk")
+	require.NoError(t, ioutil.WriteFile(path, buf.Bytes(), 0644))
+	return path
+}
+
+func TestOpen(t *testing.T) {
+	path := writeTestAPK(t, map[string][]byte{
+		"classes.dex":    []byte("dex\n035"),
+		ManifestPath:     encodeTestXML(testManifest(), true),
+		"res/layout.xml": {0},
+	})
+	m, err := Open(path)
+	require.NoError(t, err)
+	assert.Equal(t, "com.example.app", m.Package)
+	assert.Equal(t, "com.example.app.MainActivity", m.LaunchableActivity)
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	m, err = Read(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1<<32|42), m.VersionCode)
+
+	_, err = Open(writeTestAPK(t, map[string][]byte{"classes.dex": nil}))
+	assert.Equal(t, ErrNoManifest, err)
+}
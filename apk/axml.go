@@ -0,0 +1,381 @@
+package apk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"unicode/utf16"
+)
+
+// ErrInvalidXML is returned when a binary XML document is truncated or malformed.
+var ErrInvalidXML = errors.New("apk: invalid binary XML")
+
+// Chunk types of the binary XML format, from ResourceTypes.h in the Android framework.
+const (
+	chunkStringPool   = 0x0001
+	chunkXML          = 0x0003
+	chunkStartNS      = 0x0100
+	chunkEndNS        = 0x0101
+	chunkStartElement = 0x0102
+	chunkEndElement   = 0x0103
+	chunkCData        = 0x0104
+	chunkResourceMap  = 0x0180
+
+	stringPoolUTF8 = 1 << 8
+
+	// noIndex is the string index of absent strings, e.g. an attribute without a namespace.
+	noIndex = 0xffffffff
+)
+
+// ValueType is the type of the typed value of an attribute.
+type ValueType uint8
+
+// Value types, from Res_value in ResourceTypes.h.
+const (
+	TypeNull      ValueType = 0x00
+	TypeReference ValueType = 0x01
+	TypeAttribute ValueType = 0x02
+	TypeString    ValueType = 0x03
+	TypeFloat     ValueType = 0x04
+	TypeDimension ValueType = 0x05
+	TypeFraction  ValueType = 0x06
+	TypeIntDec    ValueType = 0x10
+	TypeIntHex    ValueType = 0x11
+	TypeIntBool   ValueType = 0x12
+
+	// Colors are stored in types 0x1c to 0x1f.
+	TypeFirstColor ValueType = 0x1c
+	TypeLastColor  ValueType = 0x1f
+)
+
+// Element is an element of a binary XML document.
+type Element struct {
+	// Namespace is the URI of the namespace of the element, usually empty.
+	Namespace string
+	Name      string
+	Attrs     []Attr
+	Children  []*Element
+
+	// Text is the character data directly inside the element.
+	Text string
+}
+
+// Attr is an attribute of an Element.
+type Attr struct {
+	// Namespace is the URI of the namespace, e.g. AndroidNamespace, empty for attributes
+	// without one like package.
+	Namespace string
+	Name      string
+
+	// ResourceID is the id of the attribute in the framework, e.g. 0x0101021b for
+	// android:versionCode, 0 if it has none. Some tools strip or obfuscate the names of
+	// attributes, so the id is the reliable way to identify android attributes.
+	ResourceID uint32
+
+	// Type and Data are the typed value of the attribute.
+	Type ValueType
+	Data uint32
+
+	// Value is the value formatted as a string: the string itself for strings, decimal for
+	// integers, "true" or "false" for booleans, and "@0x7f010000" for references.
+	Value string
+}
+
+// Int returns the value of an integer attribute.
+func (a *Attr) Int() (int64, bool) {
+	switch {
+	case a.Type == TypeIntDec, a.Type == TypeIntHex:
+		return int64(int32(a.Data)), true
+	case a.Type == TypeString:
+		// Some tools write numbers as strings.
+		n, err := strconv.ParseInt(a.Value, 0, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// Attr returns the attribute of e with the resource id, or named name in namespace if no
+// attribute has it. Returns nil if there is no such attribute.
+func (e *Element) Attr(namespace, name string, id uint32) *Attr {
+	if id != 0 {
+		for i := range e.Attrs {
+			if e.Attrs[i].ResourceID == id {
+				return &e.Attrs[i]
+			}
+		}
+	}
+	for i := range e.Attrs {
+		if e.Attrs[i].Namespace == namespace && e.Attrs[i].Name == name {
+			return &e.Attrs[i]
+		}
+	}
+	return nil
+}
+
+// ChildrenNamed returns the children of e named name.
+func (e *Element) ChildrenNamed(name string) []*Element {
+	var children []*Element
+	for _, child := range e.Children {
+		if child.Name == name {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+/*
+DecodeXML decodes a binary XML document, e.g. the AndroidManifest.xml of an APK, and returns its
+root element.
+
+The document is a sequence of chunks, each starting with its type, the size of its header and
+its total size:
+	XML chunk
+		string pool: all the names and string values, referenced by index
+		resource map: the resource ids of the first strings, the names of attributes
+		start namespace, start element, end element, CDATA, end namespace...
+*/
+func DecodeXML(data []byte) (*Element, error) {
+	typ, headerSize, size, err := readChunkHeader(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	if typ != chunkXML {
+		return nil, fmt.Errorf("%w: not an XML document, chunk type 0x%04x", ErrInvalidXML, typ)
+	}
+
+	d := &xmlDecoder{}
+	var root *Element
+	var stack []*Element
+	for offset := uint32(headerSize); offset < size; {
+		typ, headerSize, chunkSize, err := readChunkHeader(data[:size], offset)
+		if err != nil {
+			return nil, err
+		}
+		chunk := data[offset : offset+chunkSize]
+		offset += chunkSize
+
+		switch typ {
+		case chunkStringPool:
+			if d.strings, err = decodeStringPool(chunk, headerSize); err != nil {
+				return nil, err
+			}
+		case chunkResourceMap:
+			d.resourceIDs = make([]uint32, (len(chunk)-int(headerSize))/4)
+			for i := range d.resourceIDs {
+				d.resourceIDs[i] = binary.LittleEndian.Uint32(chunk[int(headerSize)+i*4:])
+			}
+		case chunkStartElement:
+			element, err := d.decodeElement(chunk, headerSize)
+			if err != nil {
+				return nil, err
+			}
+			if len(stack) == 0 {
+				if root != nil {
+					return nil, fmt.Errorf("%w: more than one root element", ErrInvalidXML)
+				}
+				root = element
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, element)
+			}
+			stack = append(stack, element)
+		case chunkEndElement:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("%w: unbalanced end element", ErrInvalidXML)
+			}
+			stack = stack[:len(stack)-1]
+		case chunkCData:
+			if len(stack) > 0 && len(chunk) >= int(headerSize)+4 {
+				stack[len(stack)-1].Text += d.string(binary.LittleEndian.Uint32(chunk[headerSize:]))
+			}
+		}
+		// Namespaces are resolved by URI in the attributes and elements, and unknown chunks
+		// are skipped like the framework does.
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("%w: no root element", ErrInvalidXML)
+	}
+	return root, nil
+}
+
+// readChunkHeader reads the header of the chunk at offset, and checks that it fits in data.
+func readChunkHeader(data []byte, offset uint32) (typ uint16, headerSize uint16, size uint32, err error) {
+	if uint64(offset)+8 > uint64(len(data)) {
+		return 0, 0, 0, fmt.Errorf("%w: truncated chunk at %d", ErrInvalidXML, offset)
+	}
+	typ = binary.LittleEndian.Uint16(data[offset:])
+	headerSize = binary.LittleEndian.Uint16(data[offset+2:])
+	size = binary.LittleEndian.Uint32(data[offset+4:])
+	if headerSize < 8 || uint32(headerSize) > size || uint64(offset)+uint64(size) > uint64(len(data)) {
+		return 0, 0, 0, fmt.Errorf("%w: invalid chunk size at %d", ErrInvalidXML, offset)
+	}
+	return typ, headerSize, size, nil
+}
+
+/*
+decodeStringPool decodes a string pool chunk, whose header is
+	chunk header, stringCount, styleCount, flags, stringsStart, stylesStart
+followed by the offsets of the strings from stringsStart. Strings are prefixed by their length
+and are UTF-16, or UTF-8 if flags has stringPoolUTF8. Styles are ignored.
+*/
+func decodeStringPool(chunk []byte, headerSize uint16) ([]string, error) {
+	if headerSize < 28 {
+		return nil, fmt.Errorf("%w: invalid string pool header", ErrInvalidXML)
+	}
+	count := binary.LittleEndian.Uint32(chunk[8:])
+	flags := binary.LittleEndian.Uint32(chunk[16:])
+	start := binary.LittleEndian.Uint32(chunk[20:])
+	if uint64(headerSize)+uint64(count)*4 > uint64(len(chunk)) || start > uint32(len(chunk)) {
+		return nil, fmt.Errorf("%w: invalid string pool size", ErrInvalidXML)
+	}
+
+	strings := make([]string, count)
+	for i := range strings {
+		offset := uint64(start) + uint64(binary.LittleEndian.Uint32(chunk[int(headerSize)+i*4:]))
+		if offset >= uint64(len(chunk)) {
+			return nil, fmt.Errorf("%w: invalid string offset", ErrInvalidXML)
+		}
+		var err error
+		if flags&stringPoolUTF8 != 0 {
+			strings[i], err = decodeUTF8String(chunk[offset:])
+		} else {
+			strings[i], err = decodeUTF16String(chunk[offset:])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return strings, nil
+}
+
+// decodeUTF8String decodes a string prefixed by its length in UTF-16 units and in bytes, each
+// stored in 1 byte, or 2 if the high bit of the first one is set.
+func decodeUTF8String(data []byte) (string, error) {
+	pos := 0
+	readLength := func() (int, bool) {
+		if pos >= len(data) {
+			return 0, false
+		}
+		n := int(data[pos])
+		pos++
+		if n&0x80 != 0 {
+			if pos >= len(data) {
+				return 0, false
+			}
+			n = (n&0x7f)<<8 | int(data[pos])
+			pos++
+		}
+		return n, true
+	}
+
+	_, ok := readLength()
+	length, ok2 := readLength()
+	if !ok || !ok2 || pos+length > len(data) {
+		return "", fmt.Errorf("%w: truncated string", ErrInvalidXML)
+	}
+	return string(data[pos : pos+length]), nil
+}
+
+// decodeUTF16String decodes a string prefixed by its length in UTF-16 units, stored in 2
+// bytes, or 4 if the high bit of the first two is set.
+func decodeUTF16String(data []byte) (string, error) {
+	if len(data) < 2 {
+		return "", fmt.Errorf("%w: truncated string", ErrInvalidXML)
+	}
+	length := int(binary.LittleEndian.Uint16(data))
+	pos := 2
+	if length&0x8000 != 0 {
+		if len(data) < 4 {
+			return "", fmt.Errorf("%w: truncated string", ErrInvalidXML)
+		}
+		length = (length&0x7fff)<<16 | int(binary.LittleEndian.Uint16(data[2:]))
+		pos = 4
+	}
+	if pos+length*2 > len(data) {
+		return "", fmt.Errorf("%w: truncated string", ErrInvalidXML)
+	}
+
+	units := make([]uint16, length)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[pos+i*2:])
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+type xmlDecoder struct {
+	strings     []string
+	resourceIDs []uint32
+}
+
+// string returns the string at index in the string pool, or "" if there is none.
+func (d *xmlDecoder) string(index uint32) string {
+	if index == noIndex || index >= uint32(len(d.strings)) {
+		return ""
+	}
+	return d.strings[index]
+}
+
+/*
+decodeElement decodes a start element chunk, whose header is followed by
+	namespace, name, attributeStart, attributeSize, attributeCount, idIndex, classIndex, styleIndex
+and the attributes, which are
+	namespace, name, raw value, value size, 0, value type, value data
+*/
+func (d *xmlDecoder) decodeElement(chunk []byte, headerSize uint16) (*Element, error) {
+	ext := chunk[headerSize:]
+	if len(ext) < 20 {
+		return nil, fmt.Errorf("%w: truncated element", ErrInvalidXML)
+	}
+	element := &Element{
+		Namespace: d.string(binary.LittleEndian.Uint32(ext)),
+		Name:      d.string(binary.LittleEndian.Uint32(ext[4:])),
+	}
+	attrStart := int(binary.LittleEndian.Uint16(ext[8:]))
+	attrSize := int(binary.LittleEndian.Uint16(ext[10:]))
+	attrCount := int(binary.LittleEndian.Uint16(ext[12:]))
+	if attrCount > 0 && (attrSize < 20 || attrStart+attrCount*attrSize > len(ext)) {
+		return nil, fmt.Errorf("%w: invalid attributes of %s", ErrInvalidXML, element.Name)
+	}
+
+	for i := 0; i < attrCount; i++ {
+		raw := ext[attrStart+i*attrSize:]
+		nameIndex := binary.LittleEndian.Uint32(raw[4:])
+		attr := Attr{
+			Namespace: d.string(binary.LittleEndian.Uint32(raw)),
+			Name:      d.string(nameIndex),
+			Type:      ValueType(raw[15]),
+			Data:      binary.LittleEndian.Uint32(raw[16:]),
+		}
+		if nameIndex < uint32(len(d.resourceIDs)) {
+			attr.ResourceID = d.resourceIDs[nameIndex]
+		}
+		attr.Value = d.formatValue(binary.LittleEndian.Uint32(raw[8:]), attr.Type, attr.Data)
+		element.Attrs = append(element.Attrs, attr)
+	}
+	return element, nil
+}
+
+// formatValue formats a typed value as a string, see Attr.Value.
+func (d *xmlDecoder) formatValue(rawValue uint32, typ ValueType, data uint32) string {
+	switch {
+	case typ == TypeString:
+		return d.string(data)
+	case typ == TypeIntDec:
+		return strconv.Itoa(int(int32(data)))
+	case typ == TypeIntHex:
+		return fmt.Sprintf("0x%08x", data)
+	case typ == TypeIntBool:
+		return strconv.FormatBool(data != 0)
+	case typ == TypeReference:
+		return fmt.Sprintf("@0x%08x", data)
+	case typ == TypeAttribute:
+		return fmt.Sprintf("?0x%08x", data)
+	case typ >= TypeFirstColor && typ <= TypeLastColor:
+		return fmt.Sprintf("#%08x", data)
+	case rawValue != noIndex:
+		return d.string(rawValue)
+	}
+	return fmt.Sprintf("0x%08x", data)
+}
@@ -0,0 +1,255 @@
+package apk
+
+import (
+	"bytes"
+	"encoding/binary"
+	stderrors "errors"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testElement and testAttr describe a document for encodeTestXML.
+type testElement struct {
+	name     string
+	attrs    []testAttr
+	children []testElement
+	text     string
+}
+
+type testAttr struct {
+	ns   string
+	name string
+	id   uint32
+	typ  ValueType
+	data uint32
+	str  string
+}
+
+func androidAttr(name string, id uint32, typ ValueType, data uint32) testAttr {
+	return testAttr{ns: AndroidNamespace, name: name, id: id, typ: typ, data: data}
+}
+
+func androidString(name string, id uint32, value string) testAttr {
+	return testAttr{ns: AndroidNamespace, name: name, id: id, typ: TypeString, str: value}
+}
+
+// xmlEncoder encodes documents in the binary XML format, like aapt does.
+type xmlEncoder struct {
+	strings []string
+	index   map[string]uint32
+	ids     []uint32
+	body    bytes.Buffer
+}
+
+// encodeTestXML encodes root, with a UTF-8 string pool if utf8 is true else UTF-16.
+func encodeTestXML(root testElement, utf8 bool) []byte {
+	e := &xmlEncoder{index: map[string]uint32{}}
+	// The names of the attributes with resource ids must come first, to match the resource map.
+	e.collectIDs(root)
+	e.chunk(chunkStartNS, 16, e.ref("android"), e.ref(AndroidNamespace))
+	e.element(root)
+	e.chunk(chunkEndNS, 16, e.ref("android"), e.ref(AndroidNamespace))
+
+	var doc bytes.Buffer
+	doc.Write(encodeStringPool(e.strings, utf8))
+	resourceMap := make([]byte, 8+4*len(e.ids))
+	putChunkHeader(resourceMap, chunkResourceMap, 8)
+	for i, id := range e.ids {
+		binary.LittleEndian.PutUint32(resourceMap[8+4*i:], id)
+	}
+	doc.Write(resourceMap)
+	doc.Write(e.body.Bytes())
+
+	header := make([]byte, 8)
+	putChunkHeader(header, chunkXML, 8)
+	data := append(header, doc.Bytes()...)
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)))
+	return data
+}
+
+func (e *xmlEncoder) collectIDs(element testElement) {
+	for _, attr := range element.attrs {
+		if attr.id != 0 {
+			if _, ok := e.index[attr.name]; !ok {
+				e.ref(attr.name)
+				e.ids = append(e.ids, attr.id)
+			}
+		}
+	}
+	for _, child := range element.children {
+		e.collectIDs(child)
+	}
+}
+
+func (e *xmlEncoder) ref(s string) uint32 {
+	if s == "" {
+		return noIndex
+	}
+	if i, ok := e.index[s]; ok {
+		return i
+	}
+	e.index[s] = uint32(len(e.strings))
+	e.strings = append(e.strings, s)
+	return e.index[s]
+}
+
+// chunk writes a tree node chunk with line number and comment, followed by ext.
+func (e *xmlEncoder) chunk(typ uint16, headerSize uint16, ext ...interface{}) {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 8))
+	binary.Write(&buf, binary.LittleEndian, uint32(1))
+	binary.Write(&buf, binary.LittleEndian, uint32(noIndex))
+	for _, v := range ext {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	data := buf.Bytes()
+	putChunkHeader(data, typ, headerSize)
+	e.body.Write(data)
+}
+
+func (e *xmlEncoder) element(element testElement) {
+	var attrs bytes.Buffer
+	for _, attr := range element.attrs {
+		raw, data := uint32(noIndex), attr.data
+		if attr.typ == TypeString {
+			raw = e.ref(attr.str)
+			data = raw
+		}
+		binary.Write(&attrs, binary.LittleEndian, []uint32{e.ref(attr.ns), e.ref(attr.name), raw})
+		binary.Write(&attrs, binary.LittleEndian, []uint16{8})
+		attrs.Write([]byte{0, byte(attr.typ)})
+		binary.Write(&attrs, binary.LittleEndian, data)
+	}
+	e.chunk(chunkStartElement, 16, uint32(noIndex), e.ref(element.name),
+		[]uint16{20, 20, uint16(len(element.attrs)), 0, 0, 0}, attrs.Bytes())
+	if element.text != "" {
+		e.chunk(chunkCData, 16, e.ref(element.text), []uint32{0x03000008, e.ref(element.text)})
+	}
+	for _, child := range element.children {
+		e.element(child)
+	}
+	e.chunk(chunkEndElement, 16, uint32(noIndex), e.ref(element.name))
+}
+
+func putChunkHeader(data []byte, typ uint16, headerSize uint16) {
+	binary.LittleEndian.PutUint16(data, typ)
+	binary.LittleEndian.PutUint16(data[2:], headerSize)
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)))
+}
+
+func encodeStringPool(strings []string, utf8 bool) []byte {
+	var data bytes.Buffer
+	offsets := make([]uint32, len(strings))
+	for i, s := range strings {
+		offsets[i] = uint32(data.Len())
+		if utf8 {
+			data.Write(encodeTestLength8(len(utf16.Encode([]rune(s)))))
+			data.Write(encodeTestLength8(len(s)))
+			data.WriteString(s)
+			data.WriteByte(0)
+		} else {
+			units := utf16.Encode([]rune(s))
+			binary.Write(&data, binary.LittleEndian, uint16(len(units)))
+			binary.Write(&data, binary.LittleEndian, units)
+			binary.Write(&data, binary.LittleEndian, uint16(0))
+		}
+	}
+	for data.Len()%4 != 0 {
+		data.WriteByte(0)
+	}
+
+	var flags uint32
+	if utf8 {
+		flags = stringPoolUTF8
+	}
+	header := make([]byte, 28+4*len(strings))
+	binary.LittleEndian.PutUint32(header[8:], uint32(len(strings)))
+	binary.LittleEndian.PutUint32(header[16:], flags)
+	binary.LittleEndian.PutUint32(header[20:], uint32(len(header)))
+	for i, offset := range offsets {
+		binary.LittleEndian.PutUint32(header[28+4*i:], offset)
+	}
+	chunk := append(header, data.Bytes()...)
+	putChunkHeader(chunk, chunkStringPool, 28)
+	return chunk
+}
+
+func encodeTestLength8(n int) []byte {
+	if n > 0x7f {
+		return []byte{byte(n>>8) | 0x80, byte(n)}
+	}
+	return []byte{byte(n)}
+}
+
+func TestDecodeXML(t *testing.T) {
+	long := string(bytes.Repeat([]byte("é"), 200))
+	doc := testElement{
+		name: "root",
+		attrs: []testAttr{
+			{name: "plain", typ: TypeString, str: long},
+			androidAttr("int", 0x01010001, TypeIntDec, 0xffffffff),
+			androidAttr("hex", 0, TypeIntHex, 0x10),
+			androidAttr("bool", 0x01010002, TypeIntBool, 0xffffffff),
+			androidAttr("ref", 0, TypeReference, 0x7f010000),
+			androidAttr("color", 0, 0x1d, 0xff00ff00),
+		},
+		children: []testElement{
+			{name: "child", text: "text"},
+			{name: "child"},
+		},
+	}
+
+	for _, utf8 := range []bool{true, false} {
+		root, err := DecodeXML(encodeTestXML(doc, utf8))
+		require.NoError(t, err)
+		assert.Equal(t, "root", root.Name)
+		assert.Equal(t, []Attr{
+			{Name: "plain", Type: TypeString, Data: 0, Value: long},
+			{Namespace: AndroidNamespace, Name: "int", ResourceID: 0x01010001, Type: TypeIntDec, Data: 0xffffffff, Value: "-1"},
+			{Namespace: AndroidNamespace, Name: "hex", Type: TypeIntHex, Data: 0x10, Value: "0x00000010"},
+			{Namespace: AndroidNamespace, Name: "bool", ResourceID: 0x01010002, Type: TypeIntBool, Data: 0xffffffff, Value: "true"},
+			{Namespace: AndroidNamespace, Name: "ref", Type: TypeReference, Data: 0x7f010000, Value: "@0x7f010000"},
+			{Namespace: AndroidNamespace, Name: "color", Type: 0x1d, Data: 0xff00ff00, Value: "#ff00ff00"},
+		}, fixStringData(root.Attrs))
+		require.Len(t, root.Children, 2)
+		assert.Equal(t, "text", root.Children[0].Text)
+		assert.Len(t, root.ChildrenNamed("child"), 2)
+
+		n, ok := root.Attr(AndroidNamespace, "int", 0).Int()
+		assert.True(t, ok)
+		assert.Equal(t, int64(-1), n)
+		assert.Equal(t, "true", root.Attr("", "", 0x01010002).Value)
+		assert.Nil(t, root.Attr(AndroidNamespace, "missing", 0))
+	}
+}
+
+// fixStringData zeroes the data of string attributes, which is an index in the string pool.
+func fixStringData(attrs []Attr) []Attr {
+	for i := range attrs {
+		if attrs[i].Type == TypeString {
+			attrs[i].Data = 0
+		}
+	}
+	return attrs
+}
+
+func TestDecodeXMLInvalid(t *testing.T) {
+	valid := encodeTestXML(testElement{name: "root", attrs: []testAttr{androidAttr("a", 1, TypeIntDec, 1)}}, false)
+
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"truncated": valid[:len(valid)-10],
+		"not xml":   append([]byte{1, 0}, valid[2:]...),
+		"no root":   valid[:8],
+	} {
+		if name == "no root" {
+			data = append([]byte{}, data...)
+			binary.LittleEndian.PutUint32(data[4:], 8)
+		}
+		_, err := DecodeXML(data)
+		assert.True(t, stderrors.Is(err, ErrInvalidXML), "%s: %v", name, err)
+	}
+}
@@ -81,7 +81,7 @@ func (a *AsyncWriter) darinProgress() {
 		a.wg.Wait()
 		a.Done <- true
 	}()
-	var lastSize int32
+	var lastSize int64
 	for {
 		select {
 		case <-t.C:
@@ -100,7 +100,7 @@ func (a *AsyncWriter) darinProgress() {
 				default:
 				}
 			}
-			a.bytesCompleted = int64(finfo.Size)
+			a.bytesCompleted = finfo.Size
 			if a.TotalSize != 0 && a.bytesCompleted >= a.TotalSize {
 				return
 			}
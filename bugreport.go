@@ -0,0 +1,186 @@
+package adb
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+// BugreportFormat is the format of a bug report written by Device.Bugreport.
+type BugreportFormat int
+
+const (
+	// BugreportZip is the zip file generated by bugreportz on Android 7 and later.
+	BugreportZip BugreportFormat = iota
+
+	// BugreportText is the plain text report generated by bugreport on older devices.
+	BugreportText
+)
+
+// BugreportOptions configures Device.BugreportWithOptions.
+type BugreportOptions struct {
+	// Progress, if not nil, is called for each progress report of bugreportz while the bug
+	// report is generated. It's called from the goroutine that called Bugreport.
+	Progress func(BugreportProgress)
+}
+
+// BugreportProgress reports the progress of generating a bug report.
+type BugreportProgress struct {
+	// Path of the zip file on the device, empty until bugreportz reports it.
+	Path string
+
+	// Progress out of Total, in arbitrary units. Total is 0 until bugreportz reports it, which
+	// it never does before version 1.1.
+	Progress, Total int
+}
+
+/*
+Bugreport generates a bug report and writes it to w, which takes a few minutes.
+
+On Android 7 and later the report is a zip file generated by bugreportz, which is left on the
+device once it's been copied to w. Older devices don't have bugreportz, and the report is the
+plain text output of bugreport instead. The returned format tells which one was written:
+	f, err := os.Create("bugreport.zip")
+	format, err := device.Bugreport(f)
+*/
+func (c *Device) Bugreport(w io.Writer) (BugreportFormat, error) {
+	return c.BugreportContext(context.Background(), w)
+}
+
+// BugreportContext is like Bugreport, but gives up as soon as ctx is done. The report may keep
+// being generated on the device.
+func (c *Device) BugreportContext(ctx context.Context, w io.Writer) (BugreportFormat, error) {
+	return c.BugreportWithOptions(ctx, w, BugreportOptions{})
+}
+
+// BugreportWithOptions is like BugreportContext, but allows getting notified of the progress of
+// generating the report.
+func (c *Device) BugreportWithOptions(ctx context.Context, w io.Writer, opts BugreportOptions) (BugreportFormat, error) {
+	result, err := c.RunShellCommandContext(ctx, "bugreportz", "-v")
+	if err != nil {
+		return BugreportZip, err
+	}
+	if result.ExitCode != 0 {
+		return BugreportText, c.legacyBugreport(ctx, w)
+	}
+
+	// bugreportz only reports its progress since version 1.1.
+	var args []string
+	if major, minor, ok := parseBugreportzVersion(string(result.Stdout) + string(result.Stderr)); ok &&
+		(major > 1 || major == 1 && minor >= 1) {
+		args = append(args, "-p")
+	}
+	conn, err := c.OpenCommandContext(ctx, "bugreportz", args...)
+	if err != nil {
+		return BugreportZip, err
+	}
+	path, err := readBugreportzOutput(&contextReadCloser{ctx, conn}, opts.Progress)
+	conn.Close()
+	if err != nil {
+		return BugreportZip, wrapClientError(err, c, "Bugreport")
+	}
+
+	r, err := c.OpenReadContext(ctx, path)
+	if err != nil {
+		return BugreportZip, err
+	}
+	defer r.Close()
+	return BugreportZip, wrapClientError(copyOutput(w, r, "bug report"), c, "Bugreport")
+}
+
+func (c *Device) legacyBugreport(ctx context.Context, w io.Writer) error {
+	conn, err := c.OpenCommandContext(ctx, "bugreport")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return wrapClientError(copyOutput(w, &contextReadCloser{ctx, conn}, "bug report"), c, "Bugreport")
+}
+
+// copyOutput copies r, the output of a device, to w. Errors writing to w are reported as
+// LocalFileErrors, what names the output in their message.
+func copyOutput(w io.Writer, r io.Reader, what string) error {
+	_, err := io.Copy(w, r)
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*errors.Err); ok {
+		return err
+	}
+	return wrapLocalErr(err, "error writing %s", what)
+}
+
+// parseBugreportzVersion parses the output of bugreportz -v, e.g. "bugreportz 1.1".
+func parseBugreportzVersion(output string) (major, minor int, ok bool) {
+	fields := strings.Fields(output)
+	if len(fields) != 2 || fields[0] != "bugreportz" {
+		return 0, 0, false
+	}
+	parts := strings.SplitN(fields[1], ".", 2)
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	if len(parts) == 2 {
+		if minor, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, false
+		}
+	}
+	return major, minor, true
+}
+
+/*
+readBugreportzOutput reads the output of bugreportz, which is a line per event:
+	BEGIN:/data/user_de/0/com.android.shell/files/bugreports/bugreport-walleye-2020-04-01.zip
+	PROGRESS:42/100
+	OK:/data/user_de/0/com.android.shell/files/bugreports/bugreport-walleye-2020-04-01.zip
+or FAIL:reason if the report couldn't be generated. Only OK and FAIL are written without -p.
+Returns the path of the zip file.
+*/
+func readBugreportzOutput(r io.Reader, report func(BugreportProgress)) (string, error) {
+	var progress BugreportProgress
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		value := line[i+1:]
+		switch line[:i] {
+		case "BEGIN":
+			progress.Path = value
+		case "PROGRESS":
+			parts := strings.SplitN(value, "/", 2)
+			if len(parts) != 2 {
+				return "", errors.Errorf(errors.ParseError, "invalid bugreportz progress: %s", line)
+			}
+			current, err1 := strconv.Atoi(parts[0])
+			total, err2 := strconv.Atoi(parts[1])
+			if err1 != nil || err2 != nil {
+				return "", errors.Errorf(errors.ParseError, "invalid bugreportz progress: %s", line)
+			}
+			progress.Progress, progress.Total = current, total
+		case "OK":
+			return value, nil
+		case "FAIL":
+			return "", errors.Errorf(errors.AdbError, "bugreportz failed: %s", value)
+		default:
+			continue
+		}
+		if report != nil {
+			report(progress)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if _, ok := err.(*errors.Err); ok {
+			return "", err
+		}
+		return "", errors.WrapErrorf(err, errors.NetworkError, "error reading bugreportz output")
+	}
+	return "", errors.Errorf(errors.AdbError, "bugreportz exited without generating a bug report")
+}
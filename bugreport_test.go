@@ -0,0 +1,62 @@
+package adb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBugreportzVersion(t *testing.T) {
+	major, minor, ok := parseBugreportzVersion("bugreportz 1.1\n")
+	assert.True(t, ok)
+	assert.Equal(t, 1, major)
+	assert.Equal(t, 1, minor)
+
+	major, minor, ok = parseBugreportzVersion("bugreportz 2")
+	assert.True(t, ok)
+	assert.Equal(t, 2, major)
+	assert.Equal(t, 0, minor)
+
+	_, _, ok = parseBugreportzVersion("/system/bin/sh: bugreportz: not found")
+	assert.False(t, ok)
+}
+
+func TestReadBugreportzOutput(t *testing.T) {
+	const path = "/data/user_de/0/com.android.shell/files/bugreports/bugreport.zip"
+	output := "BEGIN:" + path + "\n" +
+		"PROGRESS:10/100\n" +
+		"unexpected\n" +
+		"PROGRESS:100/100\n" +
+		"OK:" + path + "\n"
+
+	var reports []BugreportProgress
+	result, err := readBugreportzOutput(strings.NewReader(output), func(p BugreportProgress) {
+		reports = append(reports, p)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, path, result)
+	assert.Equal(t, []BugreportProgress{
+		{Path: path},
+		{Path: path, Progress: 10, Total: 100},
+		{Path: path, Progress: 100, Total: 100},
+	}, reports)
+
+	// Without -p there's no progress.
+	result, err = readBugreportzOutput(strings.NewReader("OK:"+path+"\n"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, path, result)
+}
+
+func TestReadBugreportzOutputErrors(t *testing.T) {
+	_, err := readBugreportzOutput(strings.NewReader("PROGRESS:1/100\nFAIL:Could not take bugreport\n"), nil)
+	assert.Equal(t, errors.AdbError, err.(*errors.Err).Code)
+	assert.Contains(t, err.Error(), "Could not take bugreport")
+
+	_, err = readBugreportzOutput(strings.NewReader("PROGRESS:1\n"), nil)
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+
+	_, err = readBugreportzOutput(strings.NewReader("BEGIN:/data/bugreport.zip\n"), nil)
+	assert.Equal(t, errors.AdbError, err.(*errors.Err).Code)
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"image/png"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -22,7 +26,7 @@ var (
 		String()
 
 	shellCommand = kingpin.Command("shell",
-		"Run a shell command on the device.")
+		"Run a shell command on the device, or start an interactive shell if no command is given.")
 	shellCommandArg = shellCommand.Arg("command",
 		"Command to run on device.").
 		Strings()
@@ -42,13 +46,13 @@ var (
 		Bool()
 
 	pullCommand = kingpin.Command("pull",
-		"Pull a file from the device.")
+		"Pull a file or directory from the device.")
 	pullProgressFlag = pullCommand.Flag("progress",
 		"Show progress.").
 		Short('p').
 		Bool()
 	pullRemoteArg = pullCommand.Arg("remote",
-		"Path of source file on device.").
+		"Path of source file or directory on device.").
 		Required().
 		String()
 	pullLocalArg = pullCommand.Arg("local",
@@ -56,19 +60,97 @@ var (
 		String()
 
 	pushCommand = kingpin.Command("push",
-		"Push a file to the device.")
+		"Push a file or directory to the device.")
 	pushProgressFlag = pushCommand.Flag("progress",
 		"Show progress.").
 		Short('p').
 		Bool()
 	pushLocalArg = pushCommand.Arg("local",
-		"Path of source file. If -, will read from stdin.").
+		"Path of source file or directory. If -, will read from stdin.").
 		Required().
 		String()
 	pushRemoteArg = pushCommand.Arg("remote",
-		"Path of destination file on device.").
+		"Path of destination file or directory on device.").
 		Required().
 		String()
+
+	syncCommand = kingpin.Command("sync",
+		"Push only the new and changed files of a directory to the device.")
+	syncDryRunFlag = syncCommand.Flag("dry-run",
+		"Only list the changes, don't make them.").
+		Short('n').
+		Bool()
+	syncDeleteFlag = syncCommand.Flag("delete",
+		"Delete remote files that don't exist locally.").
+		Short('d').
+		Bool()
+	syncChecksumFlag = syncCommand.Flag("checksum",
+		"Compare files with different modification times by checksum.").
+		Short('c').
+		Bool()
+	syncProgressFlag = syncCommand.Flag("progress",
+		"Show progress.").
+		Short('p').
+		Bool()
+	syncLocalArg = syncCommand.Arg("local",
+		"Path of source directory.").
+		Required().
+		String()
+	syncRemoteArg = syncCommand.Arg("remote",
+		"Path of destination directory on device.").
+		Required().
+		String()
+
+	logcatCommand = kingpin.Command("logcat",
+		"Print the log of the device, or reformat a text log captured with logcat.")
+	logcatFormatFlag = logcatCommand.Flag("format",
+		"Output format and modifiers, e.g. threadtime or year,uid. Can be repeated.").
+		Short('v').
+		Strings()
+	logcatBufferFlag = logcatCommand.Flag("buffer",
+		"Buffer to read, e.g. main, system, crash or events. Can be repeated.").
+		Short('b').
+		Strings()
+	logcatDumpFlag = logcatCommand.Flag("dump",
+		"Print the entries already in the buffers and exit.").
+		Short('d').
+		Bool()
+	logcatTailFlag = logcatCommand.Flag("tail",
+		"Start with the given number of most recent entries.").
+		Short('T').
+		Int()
+	logcatInputFlag = logcatCommand.Flag("input",
+		"Read a text log from this file instead of the device. If -, reads from stdin.").
+		Short('i').
+		String()
+	logcatInputFormatFlag = logcatCommand.Flag("input-format",
+		"Format of the text log read with --input.").
+		Default("threadtime").
+		Strings()
+	logcatFilterArg = logcatCommand.Arg("filter",
+		"Filter specs, e.g. ActivityManager:I *:S.").
+		Strings()
+
+	bugreportCommand = kingpin.Command("bugreport",
+		"Generate a bug report, a zip file on Android 7 and later or plain text on older devices.")
+	bugreportPathArg = bugreportCommand.Arg("path",
+		"Path of the report, or directory to write it to. If -, will write to stdout.").
+		String()
+
+	screencapCommand = kingpin.Command("screencap",
+		"Capture the screen of the device as a PNG.")
+	screencapDisplayFlag = screencapCommand.Flag("display",
+		"Id of the display to capture.").
+		Short('d').
+		String()
+	screencapPNGFlag = screencapCommand.Flag("png",
+		"Encode the image on the device rather than reading the framebuffer.").
+		Short('p').
+		Bool()
+	screencapPathArg = screencapCommand.Arg("path",
+		"Path of the PNG. If -, will write to stdout.").
+		Default(StdIoFilename).
+		String()
 )
 
 var client *adb.Adb
@@ -92,6 +174,25 @@ func main() {
 		exitCode = pull(*pullProgressFlag, *pullRemoteArg, *pullLocalArg, parseDevice())
 	case "push":
 		exitCode = push(*pushProgressFlag, *pushLocalArg, *pushRemoteArg, parseDevice())
+	case "sync":
+		exitCode = syncDir(adb.SyncOptions{
+			Delete:   *syncDeleteFlag,
+			Checksum: *syncChecksumFlag,
+			DryRun:   *syncDryRunFlag,
+		}, *syncProgressFlag, *syncLocalArg, *syncRemoteArg, parseDevice())
+	case "logcat":
+		exitCode = logcat(adb.LogcatOptions{
+			Filters: *logcatFilterArg,
+			Tail:    *logcatTailFlag,
+			Dump:    *logcatDumpFlag,
+		}, *logcatBufferFlag, *logcatFormatFlag, *logcatInputFlag, *logcatInputFormatFlag, parseDevice())
+	case "bugreport":
+		exitCode = bugreport(*bugreportPathArg, parseDevice())
+	case "screencap":
+		exitCode = screencap(adb.ScreenshotOptions{
+			Display: *screencapDisplayFlag,
+			PNG:     *screencapPNGFlag,
+		}, *screencapPathArg, parseDevice())
 	case "forward":
 		exitCode = forward(*forwardListFlag, parseDevice())
 	}
@@ -132,28 +233,73 @@ func listDevices(long bool) int {
 }
 
 func runShellCommand(commandAndArgs []string, device adb.DeviceDescriptor) int {
-	if len(commandAndArgs) == 0 {
-		fmt.Fprintln(os.Stderr, "error: no command")
-		kingpin.Usage()
-		return 1
+	var (
+		command string
+		args    []string
+	)
+	if len(commandAndArgs) > 0 {
+		command = commandAndArgs[0]
+		args = commandAndArgs[1:]
 	}
 
-	command := commandAndArgs[0]
-	var args []string
+	// Like adb, only allocate a PTY when starting an interactive shell from a terminal.
+	usePty := command == "" && isTerminal(os.Stdin)
 
-	if len(commandAndArgs) > 1 {
-		args = commandAndArgs[1:]
+	client := client.Device(device)
+	session, err := client.OpenShell(adb.ShellOptions{
+		Raw:  !usePty,
+		Term: os.Getenv("TERM"),
+	}, command, args...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
 	}
+	defer session.Close()
 
-	client := client.Device(device)
-	output, err := client.RunCommandAsString(command, args...)
+	if usePty {
+		restore, err := makeRaw(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return 1
+		}
+		defer restore()
+		go forwardWindowSize(session)
+	}
+
+	go func() {
+		io.Copy(session.Stdin(), os.Stdin)
+		session.Stdin().Close()
+	}()
+
+	stderrDone := make(chan struct{})
+	go func() {
+		io.Copy(os.Stderr, session.Stderr())
+		close(stderrDone)
+	}()
+	io.Copy(os.Stdout, session.Stdout())
+	<-stderrDone
+
+	exitCode, err := session.Wait()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err)
 		return 1
 	}
+	return exitCode
+}
 
-	fmt.Print(output)
-	return 0
+// forwardWindowSize sends the size of the local terminal to session, and again every time
+// it's resized.
+func forwardWindowSize(session *adb.ShellSession) {
+	resized := make(chan os.Signal, 1)
+	notifyWindowSizeChanges(resized)
+	for {
+		if rows, cols, xPixels, yPixels, err := getWindowSize(os.Stdin); err == nil {
+			if err := session.SetWindowSize(rows, cols, xPixels, yPixels); err != nil {
+				return
+			}
+		}
+		<-resized
+	}
 }
 
 func forward(listForwards bool, device adb.DeviceDescriptor) int {
@@ -190,6 +336,9 @@ func pull(showProgress bool, remotePath, localPath string, device adb.DeviceDesc
 		fmt.Fprintf(os.Stderr, "error reading remote file %s: %s\n", remotePath, err)
 		return 1
 	}
+	if localPath != StdIoFilename && (info.Mode.IsDir() || info.Mode&os.ModeSymlink != 0) {
+		return copyTree(showProgress, "pull", remotePath, localPath, client.PullWithOptions)
+	}
 
 	remoteFile, err := client.OpenRead(remotePath)
 	if err != nil {
@@ -247,6 +396,10 @@ func push(showProgress bool, localPath, remotePath string, device adb.DeviceDesc
 			fmt.Fprintf(os.Stderr, "error reading local file %s: %s\n", localPath, err)
 			return 1
 		}
+		if info.IsDir() {
+			localFile.Close()
+			return copyTree(showProgress, "push", localPath, remotePath, client.Device(device).PushWithOptions)
+		}
 		size = int(info.Size())
 		perms = info.Mode().Perm()
 		mtime = info.ModTime()
@@ -268,6 +421,234 @@ func push(showProgress bool, localPath, remotePath string, device adb.DeviceDesc
 	return 0
 }
 
+func syncDir(opts adb.SyncOptions, showProgress bool, localDir, remoteDir string, device adb.DeviceDescriptor) int {
+	opts.Progress = func(p adb.FileProgress) {
+		if p.Done && p.Err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s: %s\n", p.Dst, p.Err)
+		} else if p.Done && showProgress {
+			fmt.Fprintf(os.Stderr, "%s -> %s (%d bytes)\n", p.Src, p.Dst, p.Transferred)
+		}
+	}
+
+	client := client.Device(device)
+	startTime := time.Now()
+	plan, err := client.SyncDirWithOptions(context.Background(), localDir, remoteDir, opts)
+	if plan != nil && opts.DryRun {
+		fmt.Print(plan)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to sync %s: %s\n", localDir, err)
+		return 1
+	}
+
+	if opts.DryRun {
+		fmt.Fprintf(os.Stderr, "would change %d files (%d bytes to push), %d unchanged\n",
+			len(plan.Actions), plan.Size(), plan.Unchanged)
+	} else {
+		duration := time.Now().Sub(startTime)
+		rate := int64(float64(plan.Size()) / duration.Seconds())
+		fmt.Fprintf(os.Stderr, "%d files changed, %d unchanged, %d B/s (%d bytes in %s)\n",
+			len(plan.Actions), plan.Unchanged, rate, plan.Size(), duration)
+	}
+	return 0
+}
+
+// logcat prints the log of device, or of the text log at input if it's set, in the format of
+// formatSpecs.
+func logcat(opts adb.LogcatOptions, buffers, formatSpecs []string, input string, inputFormatSpecs []string,
+	device adb.DeviceDescriptor) int {
+	format, err := adb.ParseLogTextFormat(formatSpecs...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+
+	var next func() (*adb.LogEntry, error)
+	if input != "" {
+		inputFormat, err := adb.ParseLogTextFormat(inputFormatSpecs...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return 1
+		}
+		r := io.Reader(os.Stdin)
+		if input != StdIoFilename {
+			file, err := os.Open(input)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error opening %s: %s\n", input, err)
+				return 1
+			}
+			defer file.Close()
+			r = file
+		}
+		next = adb.NewLogTextReader(r, inputFormat).Next
+	} else {
+		client := client.Device(device)
+		var tags adb.EventLogTags
+		for _, name := range buffers {
+			buffer, err := adb.ParseLogBuffer(name)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				return 1
+			}
+			opts.Buffers = append(opts.Buffers, buffer)
+			if buffer == adb.LogBufferEvents && tags == nil {
+				if tags, err = client.EventLogTags(); err != nil {
+					fmt.Fprintln(os.Stderr, "warning: events won't have names:", err)
+				}
+			}
+		}
+
+		logs, err := client.Logcat(opts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return 1
+		}
+		defer logs.Close()
+		next = func() (*adb.LogEntry, error) {
+			entry, err := logs.Next()
+			if err == nil && entry.Payload != nil {
+				// Print events like logcat, with the tag name and the values as message.
+				if event, err := adb.DecodeEvent(entry, tags); err == nil {
+					entry.Tag = strconv.Itoa(event.TagNumber)
+					if event.Tag != "" {
+						entry.Tag = event.Tag
+					}
+					entry.Message = strings.TrimPrefix(event.String(), entry.Tag+": ")
+				}
+			}
+			return entry, err
+		}
+	}
+
+	for {
+		entry, err := next()
+		if err == io.EOF {
+			return 0
+		} else if adb.HasErrCode(err, adb.ParseError) {
+			fmt.Fprintln(os.Stderr, "warning:", err)
+			continue
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return 1
+		}
+		fmt.Print(format.FormatEntry(entry))
+	}
+}
+
+// bugreport writes a bug report of device to path. If path is empty or a directory, the report
+// is named after the current time, with an extension that depends on its format.
+// Progress is printed to stderr.
+func bugreport(path string, device adb.DeviceDescriptor) int {
+	defaultName := path == ""
+	if info, err := os.Stat(path); defaultName || err == nil && info.IsDir() {
+		path = filepath.Join(path, "bugreport-"+time.Now().Format("2006-01-02-15-04-05")+".zip")
+		defaultName = true
+	}
+
+	var out io.WriteCloser = os.Stdout
+	if path != StdIoFilename {
+		file, err := os.Create(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return 1
+		}
+		out = file
+	}
+
+	opts := adb.BugreportOptions{
+		Progress: func(p adb.BugreportProgress) {
+			if p.Total > 0 {
+				fmt.Fprintf(os.Stderr, "\r[%3d%%] generating bug report", p.Progress*100/p.Total)
+			}
+		},
+	}
+	format, err := client.Device(device).BugreportWithOptions(context.Background(), out, opts)
+	fmt.Fprintln(os.Stderr)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+
+	if defaultName && format == adb.BugreportText {
+		textPath := strings.TrimSuffix(path, ".zip") + ".txt"
+		if err := os.Rename(path, textPath); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return 1
+		}
+		path = textPath
+	}
+	if path != StdIoFilename {
+		fmt.Fprintln(os.Stderr, "bug report written to", path)
+	}
+	return 0
+}
+
+// screencap writes a screenshot of device to path as a PNG.
+func screencap(opts adb.ScreenshotOptions, path string, device adb.DeviceDescriptor) int {
+	img, err := client.Device(device).ScreenshotWithOptions(context.Background(), opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+
+	var out io.WriteCloser = os.Stdout
+	if path != StdIoFilename {
+		if out, err = os.Create(path); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return 1
+		}
+	}
+	err = png.Encode(out, img)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+	return 0
+}
+
+// copyTree copies the tree at src to dst with copy, which is either Device.PushWithOptions or
+// Device.PullWithOptions. Like adb, symlinks are copied as symlinks.
+// If showProgress is true, every file is printed once it's copied.
+// Errors and final stats are printed to stderr.
+func copyTree(showProgress bool, verb, src, dst string,
+	copy func(context.Context, string, string, adb.TreeOptions) error) int {
+	var files, copied int64
+	opts := adb.TreeOptions{
+		Symlinks: adb.SymlinkPreserve,
+		Progress: func(p adb.FileProgress) {
+			if !p.Done {
+				return
+			}
+			if p.Err != nil {
+				fmt.Fprintf(os.Stderr, "error: %s: %s\n", p.Src, p.Err)
+				return
+			}
+			files++
+			copied += p.Transferred
+			if showProgress {
+				fmt.Fprintf(os.Stderr, "%s -> %s (%d bytes)\n", p.Src, p.Dst, p.Transferred)
+			}
+		},
+	}
+
+	startTime := time.Now()
+	if err := copy(context.Background(), src, dst, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to %s %s: %s\n", verb, src, err)
+		return 1
+	}
+
+	duration := time.Now().Sub(startTime)
+	rate := int64(float64(copied) / duration.Seconds())
+	fmt.Fprintf(os.Stderr, "%d files %sed, %d B/s (%d bytes in %s)\n", files, verb, rate, copied, duration)
+	return 0
+}
+
 // copyWithProgressAndStats copies src to dst.
 // If showProgress is true and size is positive, a progress bar is shown.
 // After copying, final stats about the transfer speed and size are shown.
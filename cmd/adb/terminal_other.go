@@ -0,0 +1,26 @@
+// +build !darwin,!freebsd,!linux,!netbsd,!openbsd
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoTerminal = errors.New("terminal control not supported on this platform")
+
+// Raw mode isn't supported here, so interactive shells behave like a line-buffered pipe.
+func isTerminal(f *os.File) bool {
+	return false
+}
+
+func makeRaw(f *os.File) (restore func(), err error) {
+	return nil, errNoTerminal
+}
+
+func getWindowSize(f *os.File) (rows, cols, xPixels, yPixels int, err error) {
+	err = errNoTerminal
+	return
+}
+
+func notifyWindowSizeChanges(c chan<- os.Signal) {}
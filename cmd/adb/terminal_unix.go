@@ -0,0 +1,58 @@
+// +build darwin freebsd linux netbsd openbsd
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// isTerminal returns true if f is connected to a terminal.
+func isTerminal(f *os.File) bool {
+	_, err := unix.IoctlGetTermios(int(f.Fd()), ioctlReadTermios)
+	return err == nil
+}
+
+// makeRaw puts the terminal connected to f into raw mode and returns a function that
+// restores its previous state.
+func makeRaw(f *os.File) (restore func(), err error) {
+	fd := int(f.Fd())
+	oldState, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	if err != nil {
+		return nil, err
+	}
+
+	// Same flags as cfmakeraw(3).
+	newState := *oldState
+	newState.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	newState.Oflag &^= unix.OPOST
+	newState.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	newState.Cflag &^= unix.CSIZE | unix.PARENB
+	newState.Cflag |= unix.CS8
+	newState.Cc[unix.VMIN] = 1
+	newState.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, ioctlWriteTermios, &newState); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		unix.IoctlSetTermios(fd, ioctlWriteTermios, oldState)
+	}, nil
+}
+
+// getWindowSize returns the size of the terminal connected to f.
+func getWindowSize(f *os.File) (rows, cols, xPixels, yPixels int, err error) {
+	ws, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return
+	}
+	return int(ws.Row), int(ws.Col), int(ws.Xpixel), int(ws.Ypixel), nil
+}
+
+// notifyWindowSizeChanges sends on c every time the terminal is resized.
+func notifyWindowSizeChanges(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGWINCH)
+}
@@ -1,11 +1,13 @@
 package adb
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/kvnxiao/go-adb/internal/errors"
@@ -23,7 +25,11 @@ type Device struct {
 	descriptor DeviceDescriptor
 
 	// Used to get device info.
-	deviceListFunc func() ([]*DeviceInfo, error)
+	deviceListFunc func(ctx context.Context) ([]*DeviceInfo, error)
+
+	// Cached by FeaturesContext, nil until it succeeds.
+	featuresMu sync.Mutex
+	features   []string
 }
 
 func (c *Device) String() string {
@@ -33,26 +39,109 @@ func (c *Device) String() string {
 // get-product is documented, but not implemented, in the server.
 // TODO(z): Make product exported if get-product is ever implemented in adb.
 func (c *Device) product() (string, error) {
-	attr, err := c.getAttribute("get-product")
+	attr, err := c.getAttribute(context.Background(), "get-product")
 	return attr, wrapClientError(err, c, "Product")
 }
 
 func (c *Device) Serial() (string, error) {
-	attr, err := c.getAttribute("get-serialno")
+	return c.SerialContext(context.Background())
+}
+
+// SerialContext is like Serial, but gives up as soon as ctx is done.
+func (c *Device) SerialContext(ctx context.Context) (string, error) {
+	attr, err := c.getAttribute(ctx, "get-serialno")
 	return attr, wrapClientError(err, c, "Serial")
 }
 
+/*
+Features returns the list of features supported by the device, e.g. "shell_v2" or "cmd".
+
+The features are only requested the first time, since many methods check them before every
+request. Use a new Device to request them again, e.g. once the device was updated, or if its
+descriptor may now designate another device.
+*/
+func (c *Device) Features() ([]string, error) {
+	return c.FeaturesContext(context.Background())
+}
+
+// FeaturesContext is like Features, but gives up as soon as ctx is done.
+func (c *Device) FeaturesContext(ctx context.Context) ([]string, error) {
+	c.featuresMu.Lock()
+	defer c.featuresMu.Unlock()
+	if c.features == nil {
+		attr, err := c.getAttribute(ctx, "features")
+		if err != nil {
+			return nil, wrapClientError(err, c, "Features")
+		}
+		c.features = []string{}
+		if attr != "" {
+			c.features = strings.Split(strings.TrimSpace(attr), ",")
+		}
+	}
+	return append([]string{}, c.features...), nil
+}
+
+// HasFeature returns true if feature is in the list returned by Features.
+func (c *Device) HasFeature(feature string) (bool, error) {
+	return c.HasFeatureContext(context.Background(), feature)
+}
+
+// HasFeatureContext is like HasFeature, but gives up as soon as ctx is done.
+func (c *Device) HasFeatureContext(ctx context.Context, feature string) (bool, error) {
+	features, err := c.FeaturesContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, f := range features {
+		if f == feature {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (c *Device) DevicePath() (string, error) {
-	attr, err := c.getAttribute("get-devpath")
+	return c.DevicePathContext(context.Background())
+}
+
+// DevicePathContext is like DevicePath, but gives up as soon as ctx is done.
+func (c *Device) DevicePathContext(ctx context.Context) (string, error) {
+	attr, err := c.getAttribute(ctx, "get-devpath")
 	return attr, wrapClientError(err, c, "DevicePath")
 }
 
 func (c *Device) State() (DeviceState, error) {
-	attr, err := c.getAttribute("get-state")
+	return c.StateContext(context.Background())
+}
+
+// StateContext is like State, but gives up as soon as ctx is done.
+func (c *Device) StateContext(ctx context.Context) (DeviceState, error) {
+	attr, err := c.getAttribute(ctx, "get-state")
+	if err != nil {
+		return StateInvalid, wrapClientError(err, c, "State")
+	}
 	state, err := parseDeviceState(attr)
 	return state, wrapClientError(err, c, "State")
 }
 
+// Device features checked by this package, as returned by Features.
+const (
+	FeatureShell2 = "shell_v2"
+	FeatureStat2  = "stat_v2"
+	FeatureLs2    = "ls_v2"
+
+	FeatureSendRecv2       = "sendrecv_v2"
+	FeatureSendRecv2Brotli = "sendrecv_v2_brotli"
+	FeatureSendRecv2LZ4    = "sendrecv_v2_lz4"
+	FeatureSendRecv2Zstd   = "sendrecv_v2_zstd"
+
+	// FeatureCmd means the device has the cmd command, which talks to system services directly.
+	FeatureCmd = "cmd"
+	// FeatureAbbExec means the device supports the abb_exec service, which is like running cmd
+	// without a shell.
+	FeatureAbbExec = "abb_exec"
+)
+
 var (
 	FProtocolTcp        = "tcp"
 	FProtocolAbstract   = "localabstract"
@@ -95,7 +184,12 @@ type ForwardPair struct {
 // ForwardList returns list with struct ForwardPair
 // If no device serial specified all devices's forward list will returned
 func (c *Device) ForwardList() (fs []ForwardPair, err error) {
-	attr, err := c.getAttribute("list-forward")
+	return c.ForwardListContext(context.Background())
+}
+
+// ForwardListContext is like ForwardList, but gives up as soon as ctx is done.
+func (c *Device) ForwardListContext(ctx context.Context) (fs []ForwardPair, err error) {
+	attr, err := c.getAttribute(ctx, "list-forward")
 	if err != nil {
 		return nil, err
 	}
@@ -124,21 +218,36 @@ func (c *Device) ForwardList() (fs []ForwardPair, err error) {
 
 // ForwardRemove specified forward
 func (c *Device) ForwardRemove(local ForwardSpec) error {
-	err := roundTripSingleNoResponse(c.server,
+	return c.ForwardRemoveContext(context.Background(), local)
+}
+
+// ForwardRemoveContext is like ForwardRemove, but gives up as soon as ctx is done.
+func (c *Device) ForwardRemoveContext(ctx context.Context, local ForwardSpec) error {
+	err := roundTripSingleNoResponse(ctx, c.server,
 		fmt.Sprintf("%s:killforward:%v", c.descriptor.getHostPrefix(), local))
 	return wrapClientError(err, c, "ForwardRemove")
 }
 
 // ForwardRemoveAll cancel all exists forwards
 func (c *Device) ForwardRemoveAll() error {
-	err := roundTripSingleNoResponse(c.server,
+	return c.ForwardRemoveAllContext(context.Background())
+}
+
+// ForwardRemoveAllContext is like ForwardRemoveAll, but gives up as soon as ctx is done.
+func (c *Device) ForwardRemoveAllContext(ctx context.Context) error {
+	err := roundTripSingleNoResponse(ctx, c.server,
 		fmt.Sprintf("%s:killforward-all", c.descriptor.getHostPrefix()))
 	return wrapClientError(err, c, "ForwardRemoveAll")
 }
 
 // Forward remote connection to local
 func (c *Device) Forward(local, remote ForwardSpec) error {
-	err := roundTripSingleNoResponse(c.server,
+	return c.ForwardContext(context.Background(), local, remote)
+}
+
+// ForwardContext is like Forward, but gives up as soon as ctx is done.
+func (c *Device) ForwardContext(ctx context.Context, local, remote ForwardSpec) error {
+	err := roundTripSingleNoResponse(ctx, c.server,
 		fmt.Sprintf("%s:forward:%v;%v", c.descriptor.getHostPrefix(), local, remote))
 	return wrapClientError(err, c, "Forward")
 }
@@ -146,7 +255,12 @@ func (c *Device) Forward(local, remote ForwardSpec) error {
 // ForwardToFreePort return random generated port
 // If forward already exists, just return current forworded port
 func (c *Device) ForwardToFreePort(remote ForwardSpec) (port int, err error) {
-	fws, err := c.ForwardList()
+	return c.ForwardToFreePortContext(context.Background(), remote)
+}
+
+// ForwardToFreePortContext is like ForwardToFreePort, but gives up as soon as ctx is done.
+func (c *Device) ForwardToFreePortContext(ctx context.Context, remote ForwardSpec) (port int, err error) {
+	fws, err := c.ForwardListContext(ctx)
 	if err != nil {
 		return
 	}
@@ -159,20 +273,25 @@ func (c *Device) ForwardToFreePort(remote ForwardSpec) (port int, err error) {
 	if err != nil {
 		return
 	}
-	err = c.Forward(ForwardSpec{FProtocolTcp, strconv.Itoa(port)}, remote)
+	err = c.ForwardContext(ctx, ForwardSpec{FProtocolTcp, strconv.Itoa(port)}, remote)
 	return
 }
 
 func (c *Device) DeviceInfo() (*DeviceInfo, error) {
+	return c.DeviceInfoContext(context.Background())
+}
+
+// DeviceInfoContext is like DeviceInfo, but gives up as soon as ctx is done.
+func (c *Device) DeviceInfoContext(ctx context.Context) (*DeviceInfo, error) {
 	// Adb doesn't actually provide a way to get this for an individual device,
 	// so we have to just list devices and find ourselves.
 
-	serial, err := c.Serial()
+	serial, err := c.SerialContext(ctx)
 	if err != nil {
 		return nil, wrapClientError(err, c, "GetDeviceInfo(GetSerial)")
 	}
 
-	devices, err := c.deviceListFunc()
+	devices, err := c.deviceListFunc(ctx)
 	if err != nil {
 		return nil, wrapClientError(err, c, "DeviceInfo(ListDevices)")
 	}
@@ -206,15 +325,14 @@ contain double quotes.
 This function explicitly returns the result as a string, rather than a byte slice (from RunCommand)
 */
 func (c *Device) RunCommandAsString(cmd string, args ...string) (string, error) {
-	conn, err := c.OpenCommand(cmd, args...)
-	if err != nil {
-		return "", err
-	}
-	resp, err := conn.ReadUntilEof()
-	if err != nil {
-		return "", wrapClientError(err, c, "RunCommandAsString")
-	}
-	return string(resp), nil
+	return c.RunCommandAsStringContext(context.Background(), cmd, args...)
+}
+
+// RunCommandAsStringContext is like RunCommandAsString, but the command is abandoned as soon as
+// ctx is done.
+func (c *Device) RunCommandAsStringContext(ctx context.Context, cmd string, args ...string) (string, error) {
+	resp, err := c.runCommand(ctx, "RunCommandAsString", cmd, args...)
+	return string(resp), err
 }
 
 /*
@@ -234,23 +352,41 @@ This method quotes the arguments for you, and will return an error if any of the
 contain double quotes.
 */
 func (c *Device) RunCommand(cmd string, args ...string) ([]byte, error) {
-	conn, err := c.OpenCommand(cmd, args...)
+	return c.RunCommandContext(context.Background(), cmd, args...)
+}
+
+// RunCommandContext is like RunCommand, but the command is abandoned as soon as ctx is done.
+// The command may keep running on the device.
+func (c *Device) RunCommandContext(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+	return c.runCommand(ctx, "RunCommand", cmd, args...)
+}
+
+func (c *Device) runCommand(ctx context.Context, operation string, cmd string, args ...string) ([]byte, error) {
+	conn, err := c.OpenCommandContext(ctx, cmd, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer conn.Close()
+
 	resp, err := conn.ReadUntilEof()
 	if err != nil {
-		return nil, wrapClientError(err, c, "RunCommand")
+		return nil, wrapClientError(wrapContextErr(ctx, err), c, operation)
 	}
 	return resp, nil
 }
 
 func (c *Device) OpenCommand(cmd string, args ...string) (conn *wire.Conn, err error) {
+	return c.OpenCommandContext(context.Background(), cmd, args...)
+}
+
+// OpenCommandContext is like OpenCommand, but the returned connection is closed as soon as
+// ctx is done, so any pending reads will fail.
+func (c *Device) OpenCommandContext(ctx context.Context, cmd string, args ...string) (conn *wire.Conn, err error) {
 	cmd, err = prepareCommandLine(cmd, args...)
 	if err != nil {
 		return nil, wrapClientError(err, c, "OpenCommand")
 	}
-	conn, err = c.dialDevice()
+	conn, err = c.dialDevice(ctx)
 	if err != nil {
 		return nil, wrapClientError(err, c, "OpenCommand")
 	}
@@ -266,14 +402,82 @@ func (c *Device) OpenCommand(cmd string, args ...string) (conn *wire.Conn, err e
 	// We read until the stream is closed.
 	// So, we can't use conn.RoundTripSingleResponse.
 	if err = conn.SendMessage([]byte(req)); err != nil {
-		return nil, wrapClientError(err, c, "Command")
+		return nil, wrapClientError(wrapContextErr(ctx, err), c, "Command")
 	}
 	if _, err = conn.ReadStatus(req); err != nil {
-		return nil, wrapClientError(err, c, "Command")
+		return nil, wrapClientError(wrapContextErr(ctx, err), c, "Command")
 	}
 	return conn, nil
 }
 
+/*
+RunShellCommand runs the specified command on the device and returns its stdout, stderr and
+exit status.
+
+If the device advertises the shell_v2 feature, the command is run with the shell protocol,
+which keeps stdout and stderr separate and reports the real exit status. Otherwise the
+command is run with exec: and the exit status is echoed after the command completes, in which
+case stderr is merged into stdout.
+
+A non-zero exit status is not treated as an error, check ShellResult.ExitCode.
+*/
+func (c *Device) RunShellCommand(cmd string, args ...string) (*ShellResult, error) {
+	return c.RunShellCommandContext(context.Background(), cmd, args...)
+}
+
+// RunShellCommandContext is like RunShellCommand, but the command is abandoned as soon as ctx
+// is done.
+func (c *Device) RunShellCommandContext(ctx context.Context, cmd string, args ...string) (*ShellResult, error) {
+	hasShellV2, err := c.HasFeatureContext(ctx, FeatureShell2)
+	if err != nil {
+		return nil, wrapClientError(err, c, "RunShellCommand")
+	}
+	if !hasShellV2 {
+		output, err := c.RunCommandAsStringContext(ctx, cmd, append(args, ";", "echo", ":$?")...)
+		if err != nil {
+			return nil, err
+		}
+		result, err := parseEchoedExitCode(output)
+		return result, wrapClientError(err, c, "RunShellCommand")
+	}
+
+	conn, err := c.openShellV2(ctx, "raw", cmd, args...)
+	if err != nil {
+		return nil, wrapClientError(err, c, "RunShellCommand")
+	}
+	defer conn.Close()
+
+	// Nothing will be written to stdin, let the command know so it doesn't block reading it.
+	if err = conn.SendPacket(wire.ShellIDCloseStdin, nil); err != nil {
+		return nil, wrapClientError(wrapContextErr(ctx, err), c, "RunShellCommand")
+	}
+
+	result, err := readShellResult(conn)
+	return result, wrapClientError(wrapContextErr(ctx, err), c, "RunShellCommand")
+}
+
+// openShellV2 runs cmd with the shell protocol. mode is either "raw" or "pty".
+func (c *Device) openShellV2(ctx context.Context, mode string, cmd string, args ...string) (*wire.ShellConn, error) {
+	var err error
+	if cmd != "" {
+		cmd, err = prepareCommandLine(cmd, args...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	conn, err := c.dialDevice(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	shellConn, err := openShellProtocol(conn, fmt.Sprintf("shell,v2,%s:%s", mode, cmd))
+	if err != nil {
+		conn.Close()
+		return nil, wrapContextErr(ctx, err)
+	}
+	return shellConn, nil
+}
+
 /*
 Remount, from the official adb command’s docs:
 	Ask adb to remount the device's filesystem in read-write mode,
@@ -284,65 +488,168 @@ Remount, from the official adb command’s docs:
 Source: https://android.googlesource.com/platform/system/core/+/master/adb/SERVICES.TXT
 */
 func (c *Device) Remount() (string, error) {
-	conn, err := c.dialDevice()
+	return c.RemountContext(context.Background())
+}
+
+// RemountContext is like Remount, but gives up as soon as ctx is done.
+func (c *Device) RemountContext(ctx context.Context) (string, error) {
+	conn, err := c.dialDevice(ctx)
 	if err != nil {
 		return "", wrapClientError(err, c, "Remount")
 	}
 	defer conn.Close()
 
 	resp, err := conn.RoundTripSingleResponse([]byte("remount"))
-	return string(resp), wrapClientError(err, c, "Remount")
+	return string(resp), wrapClientError(wrapContextErr(ctx, err), c, "Remount")
 }
 
+// ListDirEntries lists the entries of the directory at path.
+// If the device supports FeatureLs2, all the fields of the entries are set.
 func (c *Device) ListDirEntries(path string) (*DirEntries, error) {
-	conn, err := c.getSyncConn()
+	return c.ListDirEntriesContext(context.Background(), path)
+}
+
+// ListDirEntriesContext is like ListDirEntries, but listing stops as soon as ctx is done.
+func (c *Device) ListDirEntriesContext(ctx context.Context, path string) (*DirEntries, error) {
+	hasLs2, err := c.HasFeatureContext(ctx, FeatureLs2)
 	if err != nil {
 		return nil, wrapClientError(err, c, "ListDirEntries(%s)", path)
 	}
 
-	entries, err := listDirEntries(conn, path)
-	return entries, wrapClientError(err, c, "ListDirEntries(%s)", path)
+	conn, err := c.getSyncConn(ctx)
+	if err != nil {
+		return nil, wrapClientError(err, c, "ListDirEntries(%s)", path)
+	}
+
+	list := listDirEntries
+	if hasLs2 {
+		list = listDirEntriesV2
+	}
+	entries, err := list(conn, path)
+	return entries, wrapClientError(wrapContextErr(ctx, err), c, "ListDirEntries(%s)", path)
 }
 
+// Stat returns information about the file at path, without following symlinks.
+// If the device supports FeatureStat2, all the fields of the entry are set.
 func (c *Device) Stat(path string) (*DirEntry, error) {
-	conn, err := c.getSyncConn()
+	return c.StatContext(context.Background(), path)
+}
+
+// StatContext is like Stat, but gives up as soon as ctx is done.
+func (c *Device) StatContext(ctx context.Context, path string) (*DirEntry, error) {
+	hasStat2, err := c.HasFeatureContext(ctx, FeatureStat2)
+	if err != nil {
+		return nil, wrapClientError(err, c, "Stat(%s)", path)
+	}
+
+	conn, err := c.getSyncConn(ctx)
 	if err != nil {
 		return nil, wrapClientError(err, c, "Stat(%s)", path)
 	}
 	defer conn.Close()
 
-	entry, err := stat(conn, path)
-	return entry, wrapClientError(err, c, "Stat(%s)", path)
+	var entry *DirEntry
+	if hasStat2 {
+		entry, err = statV2(conn, "LST2", path)
+	} else {
+		entry, err = stat(conn, path)
+	}
+	return entry, wrapClientError(wrapContextErr(ctx, err), c, "Stat(%s)", path)
 }
 
 func (c *Device) OpenRead(path string) (io.ReadCloser, error) {
-	conn, err := c.getSyncConn()
+	return c.OpenReadContext(context.Background(), path)
+}
+
+// OpenReadContext is like OpenRead, but the file is closed as soon as ctx is done, after
+// which reads return a ContextCanceled error.
+func (c *Device) OpenReadContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	return c.OpenReadWithOptions(ctx, path, TransferOptions{})
+}
+
+// OpenReadWithOptions is like OpenReadContext, but allows compressing the file while it's
+// transferred if the device supports it.
+func (c *Device) OpenReadWithOptions(ctx context.Context, path string, opts TransferOptions) (io.ReadCloser, error) {
+	compression, err := c.resolveCompression(ctx, opts.Compression)
+	if err != nil {
+		return nil, wrapClientError(err, c, "OpenRead(%s)", path)
+	}
+
+	conn, err := c.getSyncConn(ctx)
 	if err != nil {
 		return nil, wrapClientError(err, c, "OpenRead(%s)", path)
 	}
 
-	reader, err := receiveFile(conn, path)
-	return reader, wrapClientError(err, c, "OpenRead(%s)", path)
+	var reader io.ReadCloser
+	if compression == CompressionNone {
+		reader, err = receiveFile(conn, path)
+	} else {
+		reader, err = receiveFileV2(conn, path, compression)
+	}
+	if err != nil {
+		return nil, wrapClientError(wrapContextErr(ctx, err), c, "OpenRead(%s)", path)
+	}
+	return &contextReadCloser{ctx, reader}, nil
 }
 
 // OpenWrite opens the file at path on the device, creating it with the permissions specified
 // by perms if necessary, and returns a writer that writes to the file.
 // The files modification time will be set to mtime when the WriterCloser is closed. The zero value
 // is TimeOfClose, which will use the time the Close method is called as the modification time.
+// Close waits for the device to write the file, and returns an error if it couldn't.
 func (c *Device) OpenWrite(path string, perms os.FileMode, mtime time.Time) (io.WriteCloser, error) {
-	conn, err := c.getSyncConn()
+	return c.OpenWriteContext(context.Background(), path, perms, mtime)
+}
+
+// OpenWriteContext is like OpenWrite, but the file is closed as soon as ctx is done, after
+// which writes return a ContextCanceled error.
+func (c *Device) OpenWriteContext(ctx context.Context, path string, perms os.FileMode, mtime time.Time) (io.WriteCloser, error) {
+	return c.OpenWriteWithOptions(ctx, path, perms, mtime, TransferOptions{})
+}
+
+// OpenWriteWithOptions is like OpenWriteContext, but allows compressing the file while it's
+// transferred if the device supports it.
+func (c *Device) OpenWriteWithOptions(ctx context.Context, path string, perms os.FileMode, mtime time.Time, opts TransferOptions) (io.WriteCloser, error) {
+	compression, err := c.resolveCompression(ctx, opts.Compression)
+	if err != nil {
+		return nil, wrapClientError(err, c, "OpenWrite(%s)", path)
+	}
+
+	conn, err := c.getSyncConn(ctx)
 	if err != nil {
 		return nil, wrapClientError(err, c, "OpenWrite(%s)", path)
 	}
 
-	writer, err := sendFile(conn, path, perms, mtime)
-	return writer, wrapClientError(err, c, "OpenWrite(%s)", path)
+	var writer io.WriteCloser
+	if compression == CompressionNone {
+		writer, err = sendFile(conn, path, perms, mtime)
+	} else {
+		writer, err = sendFileV2(conn, path, perms, mtime, compression)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, wrapClientError(wrapContextErr(ctx, err), c, "OpenWrite(%s)", path)
+	}
+	return &contextWriteCloser{ctx, writer}, nil
+}
+
+// resolveCompression returns the compression to use for a transfer that requested compression,
+// only asking for the features of the device if necessary.
+func (c *Device) resolveCompression(ctx context.Context, compression Compression) (Compression, error) {
+	if compression == CompressionNone {
+		return CompressionNone, nil
+	}
+	features, err := c.FeaturesContext(ctx)
+	if err != nil {
+		return CompressionNone, err
+	}
+	return resolveCompression(compression, features), nil
 }
 
 // getAttribute returns the first message returned by the server by running
 // <host-prefix>:<attr>, where host-prefix is determined from the DeviceDescriptor.
-func (c *Device) getAttribute(attr string) (string, error) {
-	resp, err := roundTripSingleResponse(c.server,
+func (c *Device) getAttribute(ctx context.Context, attr string) (string, error) {
+	resp, err := roundTripSingleResponse(ctx, c.server,
 		fmt.Sprintf("%s:%s", c.descriptor.getHostPrefix(), attr))
 	if err != nil {
 		return "", err
@@ -350,18 +657,20 @@ func (c *Device) getAttribute(attr string) (string, error) {
 	return string(resp), nil
 }
 
-func (c *Device) getSyncConn() (*wire.SyncConn, error) {
-	conn, err := c.dialDevice()
+func (c *Device) getSyncConn(ctx context.Context) (*wire.SyncConn, error) {
+	conn, err := c.dialDevice(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	// Switch the connection to sync mode.
 	if err := wire.SendMessageString(conn, "sync:"); err != nil {
-		return nil, err
+		conn.Close()
+		return nil, wrapContextErr(ctx, err)
 	}
 	if _, err := conn.ReadStatus("sync"); err != nil {
-		return nil, err
+		conn.Close()
+		return nil, wrapContextErr(ctx, err)
 	}
 
 	return conn.NewSyncConn(), nil
@@ -369,8 +678,9 @@ func (c *Device) getSyncConn() (*wire.SyncConn, error) {
 
 // dialDevice switches the connection to communicate directly with the device
 // by requesting the transport defined by the DeviceDescriptor.
-func (c *Device) dialDevice() (*wire.Conn, error) {
-	conn, err := c.server.Dial()
+// The connection is closed as soon as ctx is done.
+func (c *Device) dialDevice(ctx context.Context) (*wire.Conn, error) {
+	conn, err := c.server.DialContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -378,12 +688,12 @@ func (c *Device) dialDevice() (*wire.Conn, error) {
 	req := fmt.Sprintf("host:%s", c.descriptor.getTransportDescriptor())
 	if err = wire.SendMessageString(conn, req); err != nil {
 		conn.Close()
-		return nil, errors.WrapErrf(err, "error connecting to device '%s'", c.descriptor)
+		return nil, errors.WrapErrf(wrapContextErr(ctx, err), "error connecting to device '%s'", c.descriptor)
 	}
 
 	if _, err = conn.ReadStatus(req); err != nil {
 		conn.Close()
-		return nil, err
+		return nil, wrapContextErr(ctx, err)
 	}
 
 	return conn, nil
@@ -396,18 +706,21 @@ func prepareCommandLine(cmd string, args ...string) (string, error) {
 		return "", errors.AssertionErrorf("command cannot be empty")
 	}
 
+	// Don't modify args in place, callers may prepare the same arguments more than once.
+	quotedArgs := make([]string, len(args))
 	for i, arg := range args {
 		if strings.ContainsRune(arg, '"') {
 			return "", errors.Errorf(errors.ParseError, "arg at index %d contains an invalid double quote: %s", i, arg)
 		}
 		if containsWhitespace(arg) {
-			args[i] = fmt.Sprintf("\"%s\"", arg)
+			arg = fmt.Sprintf("\"%s\"", arg)
 		}
+		quotedArgs[i] = arg
 	}
 
 	// Prepend the command to the args array.
-	if len(args) > 0 {
-		cmd = fmt.Sprintf("%s %s", cmd, strings.Join(args, " "))
+	if len(quotedArgs) > 0 {
+		cmd = fmt.Sprintf("%s %s", cmd, strings.Join(quotedArgs, " "))
 	}
 
 	return cmd, nil
@@ -91,53 +91,6 @@ func (c *Device) KillProcessByName(name string, sig syscall.Signal) error {
 	return nil
 }
 
-type PackageInfo struct {
-	Name    string
-	Path    string
-	Version struct {
-		Code int
-		Name string
-	}
-}
-
-var (
-	rePkgPath = regexp.MustCompile(`codePath=([^\s]+)`)
-	reVerCode = regexp.MustCompile(`versionCode=(\d+)`)
-	reVerName = regexp.MustCompile(`versionName=([^\s]+)`)
-)
-
-// StatPackage returns PackageInfo
-// If package not found, err will be ErrPackageNotExist
-func (c *Device) StatPackage(packageName string) (pi PackageInfo, err error) {
-	pi.Name = packageName
-	out, err := c.RunCommandAsString("dumpsys", "package", packageName)
-	if err != nil {
-		return
-	}
-
-	matches := rePkgPath.FindStringSubmatch(out)
-	if len(matches) == 0 {
-		err = ErrPackageNotExist
-		return
-	}
-	pi.Path = matches[1]
-
-	matches = reVerCode.FindStringSubmatch(out)
-	if len(matches) == 0 {
-		err = ErrPackageNotExist
-		return
-	}
-	pi.Version.Code, _ = strconv.Atoi(matches[1])
-
-	matches = reVerName.FindStringSubmatch(out)
-	if len(matches) == 0 {
-		err = ErrPackageNotExist
-		return
-	}
-	pi.Version.Name = matches[1]
-	return
-}
-
 // Properties extract info from $ adb shell getprop
 func (c *Device) Properties() (props map[string]string, err error) {
 	propOutput, err := c.RunCommandAsString("getprop")
@@ -156,27 +109,22 @@ func (c *Device) Properties() (props map[string]string, err error) {
 }
 
 /*
-RunCommandWithExitCode use a little tricky to get exit code
+RunCommandWithExitCode runs the command and returns its output and exit code.
 
-The tricky is append "; echo :$?" to the command,
-and parse out the exit code from output
+If the exit code is not 0, err will be a ShellExitError.
+The output contains stdout followed by stderr, see RunShellCommand to get them separately.
 */
 func (c *Device) RunCommandWithExitCode(cmd string, args ...string) (string, int, error) {
-	exArgs := append(args, ";", "echo", ":$?")
-	outStr, err := c.RunCommandAsString(cmd, exArgs...)
+	result, err := c.RunShellCommand(cmd, args...)
 	if err != nil {
-		return outStr, 0, err
-	}
-	idx := strings.LastIndexByte(outStr, ':')
-	if idx == -1 {
-		return outStr, 0, fmt.Errorf("adb shell aborted, can not parse exit code")
+		return "", 0, err
 	}
-	exitCode, _ := strconv.Atoi(strings.TrimSpace(outStr[idx+1:]))
-	if exitCode != 0 {
+	outStr := string(result.Stdout) + string(result.Stderr)
+	if result.ExitCode != 0 {
 		commandLine, _ := prepareCommandLine(cmd, args...)
-		err = ShellExitError{commandLine, exitCode}
+		err = ShellExitError{commandLine, result.ExitCode}
 	}
-	return outStr, exitCode, err
+	return outStr, result.ExitCode, err
 }
 
 type ShellExitError struct {
@@ -256,7 +204,7 @@ func (c *Device) WriteToFile(path string, rd io.Reader, perms os.FileMode) (writ
 				err = fmt.Errorf("target file %s not created", strconv.Quote(path))
 				return
 			}
-			if finfo.Size == int32(written) {
+			if finfo.Size == written {
 				break
 			}
 			time.Sleep(time.Duration(200+rand.Intn(100)) * time.Millisecond)
@@ -0,0 +1,270 @@
+package adb
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"syscall"
+	"time"
+)
+
+/*
+DeviceFS is a read-only view of a directory on a device that implements fs.FS, fs.StatFS,
+fs.ReadDirFS and fs.ReadFileFS, so it can be used with fs.WalkDir, fs.Glob, http.FS, etc.
+
+Every call makes at least one request to the device, nothing is cached.
+Errors are returned as *fs.PathError. Files that don't exist on the device are reported
+with fs.ErrNotExist, other errors are the *errors.Err returned by the device.
+*/
+type DeviceFS struct {
+	ctx    context.Context
+	device *Device
+	root   string
+}
+
+var (
+	_ fs.StatFS     = &DeviceFS{}
+	_ fs.ReadDirFS  = &DeviceFS{}
+	_ fs.ReadFileFS = &DeviceFS{}
+)
+
+// FS returns a filesystem of the directory at root on the device.
+func (c *Device) FS(root string) *DeviceFS {
+	return c.FSContext(context.Background(), root)
+}
+
+// FSContext is like FS, but all the operations on the filesystem and its files are abandoned
+// as soon as ctx is done.
+func (c *Device) FSContext(ctx context.Context, root string) *DeviceFS {
+	return &DeviceFS{ctx: ctx, device: c, root: root}
+}
+
+// Open opens the file or directory at name. The file is only read from the device once Read
+// is called.
+func (f *DeviceFS) Open(name string) (fs.File, error) {
+	info, err := f.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &deviceDir{fsys: f, name: name, info: info}, nil
+	}
+	return &deviceFile{fsys: f, name: name, info: info}, nil
+}
+
+// Stat returns information about the file at name, following symlinks.
+func (f *DeviceFS) Stat(name string) (fs.FileInfo, error) {
+	return f.stat("stat", name)
+}
+
+// ReadDir reads the directory at name, and returns its entries sorted by name.
+func (f *DeviceFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	children, err := f.device.listDir(f.ctx, f.path(name))
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fsError(err)}
+	}
+
+	entries := make([]fs.DirEntry, len(children))
+	for i, child := range children {
+		entries[i] = &dirEntryInfo{name: child.Name, entry: child}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// ReadFile reads the whole file at name.
+func (f *DeviceFS) ReadFile(name string) ([]byte, error) {
+	file, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, ok := file.(*deviceDir); ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errIsDir}
+	}
+	return ioutil.ReadAll(file)
+}
+
+// path returns the path on the device of name, which must be valid.
+func (f *DeviceFS) path(name string) string {
+	return path.Join(f.root, name)
+}
+
+func (f *DeviceFS) stat(op, name string) (*dirEntryInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	entry, err := f.device.StatContext(f.ctx, f.path(name))
+	if err == nil && entry.Mode&os.ModeSymlink != 0 {
+		entry, err = f.device.statFollow(f.ctx, f.path(name))
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fsError(err)}
+	}
+	return &dirEntryInfo{name: path.Base(name), entry: entry}, nil
+}
+
+// errIsDir is returned when reading a directory as a file, like os.File does.
+var errIsDir error = syscall.EISDIR
+
+// fsError converts errors for files that don't exist to fs.ErrNotExist.
+func fsError(err error) error {
+	if HasErrCode(err, FileNoExistError) {
+		return fs.ErrNotExist
+	}
+	return err
+}
+
+// dirEntryInfo adapts a DirEntry to fs.FileInfo and fs.DirEntry.
+type dirEntryInfo struct {
+	name  string
+	entry *DirEntry
+}
+
+func (i *dirEntryInfo) Name() string               { return i.name }
+func (i *dirEntryInfo) Size() int64                { return i.entry.Size }
+func (i *dirEntryInfo) Mode() fs.FileMode          { return i.entry.Mode }
+func (i *dirEntryInfo) ModTime() time.Time         { return i.entry.ModifiedAt }
+func (i *dirEntryInfo) IsDir() bool                { return i.entry.Mode.IsDir() }
+func (i *dirEntryInfo) Type() fs.FileMode          { return i.entry.Mode.Type() }
+func (i *dirEntryInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+// Sys returns the *DirEntry.
+func (i *dirEntryInfo) Sys() interface{} { return i.entry }
+
+/*
+deviceFile is a regular file opened by DeviceFS.
+
+It implements io.Seeker so it can be served by http.FileServer, but seeking anywhere other than
+the current offset or the end of the file reopens the file and reads up to the new offset.
+*/
+type deviceFile struct {
+	fsys *DeviceFS
+	name string
+	info *dirEntryInfo
+
+	// Opened by the first Read after opening or seeking.
+	reader io.ReadCloser
+	offset int64
+	closed bool
+}
+
+func (f *deviceFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *deviceFile) Read(buf []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.reader == nil {
+		reader, err := f.fsys.device.OpenReadContext(f.fsys.ctx, f.fsys.path(f.name))
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.name, Err: fsError(err)}
+		}
+		if _, err := io.CopyN(ioutil.Discard, reader, f.offset); err != nil && err != io.EOF {
+			reader.Close()
+			return 0, &fs.PathError{Op: "seek", Path: f.name, Err: err}
+		}
+		f.reader = reader
+	}
+
+	n, err := f.reader.Read(buf)
+	f.offset += int64(n)
+	if err != nil && err != io.EOF {
+		err = &fs.PathError{Op: "read", Path: f.name, Err: err}
+	}
+	return n, err
+}
+
+func (f *deviceFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrClosed}
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.Size()
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+
+	if offset != f.offset && f.reader != nil {
+		f.reader.Close()
+		f.reader = nil
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *deviceFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	if f.reader != nil {
+		return f.reader.Close()
+	}
+	return nil
+}
+
+// deviceDir is a directory opened by DeviceFS, its entries are listed by the first ReadDir.
+type deviceDir struct {
+	fsys *DeviceFS
+	name string
+	info *dirEntryInfo
+
+	entries []fs.DirEntry
+	listed  bool
+}
+
+var _ fs.ReadDirFile = &deviceDir{}
+
+func (d *deviceDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *deviceDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errIsDir}
+}
+
+func (d *deviceDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+		d.listed = true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	entries := d.entries
+	if n < len(entries) {
+		entries = entries[:n]
+	}
+	d.entries = d.entries[len(entries):]
+	return entries, nil
+}
+
+func (d *deviceDir) Close() error {
+	return nil
+}
@@ -0,0 +1,130 @@
+package adb
+
+import (
+	stderrors "errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceFSInvalidPath(t *testing.T) {
+	s := &MockServer{}
+	fsys := (&Device{server: s, descriptor: AnyDevice()}).FS("/sdcard")
+
+	for _, name := range []string{"/abs", "../up", "a/./b", ""} {
+		_, err := fsys.Open(name)
+		assert.Equal(t, fs.ErrInvalid, err.(*fs.PathError).Err, name)
+		_, err = fsys.ReadDir(name)
+		assert.Equal(t, fs.ErrInvalid, err.(*fs.PathError).Err, name)
+	}
+	assert.Empty(t, s.Trace, "the device shouldn't be contacted")
+}
+
+func TestDeviceFSPath(t *testing.T) {
+	fsys := (&Device{}).FS("/sdcard/")
+	assert.Equal(t, "/sdcard", fsys.path("."))
+	assert.Equal(t, "/sdcard/a/b", fsys.path("a/b"))
+}
+
+func TestFSError(t *testing.T) {
+	noExist := errors.Errorf(errors.FileNoExistError, "no such file")
+	assert.Equal(t, fs.ErrNotExist, fsError(noExist))
+	other := errors.Errorf(errors.NetworkError, "boom")
+	assert.Equal(t, other, fsError(other))
+}
+
+func TestDirEntryInfo(t *testing.T) {
+	entry := &DirEntry{Name: "ignored", Mode: os.ModeDir | 0755, Size: 4096, ModifiedAt: someTime}
+	info := &dirEntryInfo{name: "dir", entry: entry}
+
+	assert.Equal(t, "dir", info.Name())
+	assert.Equal(t, int64(4096), info.Size())
+	assert.Equal(t, os.ModeDir|0755, info.Mode())
+	assert.Equal(t, fs.ModeDir, info.Type())
+	assert.Equal(t, someTime, info.ModTime())
+	assert.True(t, info.IsDir())
+	assert.Equal(t, entry, info.Sys())
+	fileInfo, err := info.Info()
+	assert.NoError(t, err)
+	assert.Equal(t, info, fileInfo)
+}
+
+func TestDeviceDirReadDir(t *testing.T) {
+	var entries []fs.DirEntry
+	for _, name := range []string{"a", "b", "c"} {
+		entries = append(entries, &dirEntryInfo{name: name, entry: &DirEntry{}})
+	}
+	dir := &deviceDir{name: "dir", entries: entries, listed: true}
+
+	page, err := dir.ReadDir(2)
+	assert.NoError(t, err)
+	assert.Len(t, page, 2)
+	page, err = dir.ReadDir(2)
+	assert.NoError(t, err)
+	assert.Len(t, page, 1)
+	page, err = dir.ReadDir(2)
+	assert.Equal(t, io.EOF, err)
+	assert.Empty(t, page)
+	page, err = dir.ReadDir(-1)
+	assert.NoError(t, err)
+	assert.Empty(t, page)
+
+	_, err = dir.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
+
+type fakeReadCloser struct {
+	closed bool
+}
+
+func (r *fakeReadCloser) Read([]byte) (int, error) { return 0, io.EOF }
+func (r *fakeReadCloser) Close() error             { r.closed = true; return nil }
+
+func TestDeviceFileSeek(t *testing.T) {
+	reader := &fakeReadCloser{}
+	file := &deviceFile{name: "file", info: &dirEntryInfo{entry: &DirEntry{Size: 100}}, reader: reader, offset: 10}
+
+	// Seeking to the current offset keeps the file open.
+	offset, err := file.Seek(0, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), offset)
+	assert.False(t, reader.closed)
+
+	offset, err = file.Seek(-20, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(80), offset)
+	assert.True(t, reader.closed)
+	assert.Nil(t, file.reader)
+
+	_, err = file.Seek(-1, io.SeekStart)
+	assert.Equal(t, fs.ErrInvalid, err.(*fs.PathError).Err)
+
+	require.NoError(t, file.Close())
+	_, err = file.Read(make([]byte, 1))
+	assert.Equal(t, fs.ErrClosed, err.(*fs.PathError).Err)
+	assert.Equal(t, fs.ErrClosed, file.Close().(*fs.PathError).Err)
+}
+
+func TestDeviceFSOnDevice(t *testing.T) {
+	device := newFakeDevice()
+	device.addFile("/sdcard/root/hello.txt", 0644, someTime, "hello world\n")
+	device.addFile("/sdcard/root/dir/big", 0600, someTime, strings.Repeat("0123456789", 20000))
+	device.addFile("/sdcard/root/dir/empty", 0644, someTime, "")
+	device.addFile("/sdcard/root/dir/sub", os.ModeDir|0755, someTime, "")
+	fsys := (&Adb{device}).Device(AnyDevice()).FS("/sdcard/root")
+
+	require.NoError(t, fstest.TestFS(fsys, "hello.txt", "dir/big", "dir/empty", "dir/sub"))
+
+	data, err := fs.ReadFile(fsys, "hello.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hello world\n", string(data))
+	_, err = fsys.Open("missing")
+	assert.True(t, stderrors.Is(err, fs.ErrNotExist), "%v", err)
+}
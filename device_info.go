@@ -2,6 +2,7 @@ package adb
 
 import (
 	"bufio"
+	"strconv"
 	"strings"
 
 	"github.com/kvnxiao/go-adb/internal/errors"
@@ -18,6 +19,10 @@ type DeviceInfo struct {
 
 	// Only set for devices connected via USB.
 	Usb string
+
+	// Identifies the connection to the device, changes whenever it reconnects.
+	// Only set in the long form, by servers that support it.
+	TransportID int
 }
 
 // IsUsb returns true if the device is connected via USB.
@@ -30,12 +35,21 @@ func newDevice(serial string, attrs map[string]string) (*DeviceInfo, error) {
 		return nil, errors.AssertionErrorf("device serial cannot be blank")
 	}
 
+	var transportID int
+	if id, ok := attrs["transport_id"]; ok {
+		var err error
+		if transportID, err = strconv.Atoi(id); err != nil {
+			return nil, errors.WrapErrorf(err, errors.ParseError, "invalid transport_id: %q", id)
+		}
+	}
+
 	return &DeviceInfo{
-		Serial:     serial,
-		Product:    attrs["product"],
-		Model:      attrs["model"],
-		DeviceInfo: attrs["device"],
-		Usb:        attrs["usb"],
+		Serial:      serial,
+		Product:     attrs["product"],
+		Model:       attrs["model"],
+		DeviceInfo:  attrs["device"],
+		Usb:         attrs["usb"],
+		TransportID: transportID,
 	}, nil
 }
 
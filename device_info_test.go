@@ -43,3 +43,18 @@ func TestParseDeviceLongUsb(t *testing.T) {
 		DeviceInfo: "DEVICE",
 		Usb:        "1234"}, dev)
 }
+
+func TestParseDeviceLongTransportID(t *testing.T) {
+	dev, err := parseDeviceLong("SERIAL    device usb:1-1 product:PRODUCT model:MODEL device:DEVICE transport_id:3\n")
+	assert.NoError(t, err)
+	assert.Equal(t, &DeviceInfo{
+		Serial:      "SERIAL",
+		Product:     "PRODUCT",
+		Model:       "MODEL",
+		DeviceInfo:  "DEVICE",
+		Usb:         "1-1",
+		TransportID: 3}, dev)
+
+	_, err = parseDeviceLong("SERIAL    device product:PRODUCT model:MODEL device:DEVICE transport_id:x\n")
+	assert.True(t, HasErrCode(err, ParseError))
+}
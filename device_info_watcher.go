@@ -0,0 +1,221 @@
+package adb
+
+import (
+	"context"
+	"runtime"
+	"strings"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+/*
+DeviceInfoWatcher publishes device status and attribute change events.
+It's like DeviceWatcher, but tracks the long form of the device list, so its events carry
+the full DeviceInfo of devices and it also reports changes that don't affect the device
+state, e.g. a device being plugged into another USB port.
+*/
+type DeviceInfoWatcher struct {
+	*deviceWatcherImpl
+
+	eventChan chan DeviceInfoChangedEvent
+}
+
+// DeviceInfoChangedEvent represents a change of a device's state or attributes.
+type DeviceInfoChangedEvent struct {
+	Serial   string
+	OldState DeviceState
+	NewState DeviceState
+
+	// OldRawState and NewRawState are the states as reported by adb, e.g. "device" or
+	// "recovery", which tell apart the states that DeviceState doesn't have and are all
+	// StateInvalid. Empty if the device was disconnected.
+	OldRawState string
+	NewRawState string
+
+	// OldInfo is nil if the device was just connected, NewInfo is nil if it was disconnected.
+	OldInfo *DeviceInfo
+	NewInfo *DeviceInfo
+}
+
+// CameOnline returns true if this event represents a device coming online.
+func (s DeviceInfoChangedEvent) CameOnline() bool {
+	return s.OldState != StateOnline && s.NewState == StateOnline
+}
+
+// WentOffline returns true if this event represents a device going offline.
+func (s DeviceInfoChangedEvent) WentOffline() bool {
+	return s.OldState == StateOnline && s.NewState != StateOnline
+}
+
+// StateChanged returns false if only the attributes of the device changed.
+func (s DeviceInfoChangedEvent) StateChanged() bool {
+	return s.OldState != s.NewState || s.OldRawState != s.NewRawState
+}
+
+// trackedDevice is an entry of the long form of the device list.
+type trackedDevice struct {
+	State    DeviceState
+	RawState string
+	Info     *DeviceInfo
+}
+
+// Attributes that may appear in the long form of the device list, see newDevice.
+var longDeviceAttributes = map[string]bool{
+	"product":      true,
+	"model":        true,
+	"device":       true,
+	"usb":          true,
+	"transport_id": true,
+}
+
+func newDeviceInfoWatcher(ctx context.Context, server server, config DeviceWatcherConfig) *DeviceInfoWatcher {
+	eventChan := make(chan DeviceInfoChangedEvent)
+	watcher := &DeviceInfoWatcher{
+		deviceWatcherImpl: newDeviceWatcherImpl(ctx, server, config, "host:track-devices-l"),
+		eventChan:         eventChan,
+	}
+	watcher.publish = newDeviceInfoPublisher(eventChan)
+	watcher.closeEvents = func() { close(eventChan) }
+
+	runtime.SetFinalizer(watcher, func(watcher *DeviceInfoWatcher) {
+		watcher.Shutdown()
+	})
+
+	go publishDevices(watcher.deviceWatcherImpl)
+
+	return watcher
+}
+
+/*
+C returns a channel than can be received on to get events.
+If an unrecoverable error occurs, or Shutdown is called, the channel will be closed.
+*/
+func (w *DeviceInfoWatcher) C() <-chan DeviceInfoChangedEvent {
+	return w.eventChan
+}
+
+// newDeviceInfoPublisher returns a publish function for DeviceInfoWatcher.
+func newDeviceInfoPublisher(eventChan chan<- DeviceInfoChangedEvent) func(context.Context, string) error {
+	var lastKnownDevices map[string]trackedDevice
+
+	return func(ctx context.Context, msg string) error {
+		devices, err := parseTrackedDevices(msg)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range calculateInfoDiffs(lastKnownDevices, devices) {
+			select {
+			case eventChan <- event:
+			case <-ctx.Done():
+				return wrapContextErr(ctx, ctx.Err())
+			}
+		}
+		lastKnownDevices = devices
+		return nil
+	}
+}
+
+// parseTrackedDevices parses a message sent by host:track-devices-l.
+func parseTrackedDevices(msg string) (map[string]trackedDevice, error) {
+	devices := make(map[string]trackedDevice)
+
+	for lineNum, line := range strings.Split(msg, "\n") {
+		if len(line) == 0 {
+			continue
+		}
+
+		device, err := parseTrackedDevice(line)
+		if err != nil {
+			return nil, errors.WrapErrorf(err, errors.ParseError, "invalid device line %d: %s", lineNum, line)
+		}
+		devices[device.Info.Serial] = device
+	}
+
+	return devices, nil
+}
+
+/*
+parseTrackedDevice parses a line of the long form of the device list:
+	SERIAL    device usb:1-1 product:PRODUCT model:MODEL device:DEVICE transport_id:1
+The state may contain spaces (e.g. "no permissions (...)"), so everything that isn't a known
+attribute is considered part of it. States that DeviceState doesn't have are StateInvalid, but
+are kept in RawState.
+*/
+func parseTrackedDevice(line string) (trackedDevice, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return trackedDevice{}, errors.Errorf(errors.ParseError,
+			"malformed device line, expected at least 2 fields but found %d", len(fields))
+	}
+
+	var stateFields, attrFields []string
+	for _, field := range fields[1:] {
+		if i := strings.IndexByte(field, ':'); i > 0 && longDeviceAttributes[field[:i]] {
+			attrFields = append(attrFields, field)
+		} else {
+			stateFields = append(stateFields, field)
+		}
+	}
+
+	stateString := strings.Join(stateFields, " ")
+	if strings.HasPrefix(stateString, "no permissions") {
+		// Followed by a hint about how to fix it.
+		stateString = "no permissions"
+	}
+	// adb reports more states than DeviceState has, e.g. recovery, sideload or bootloader.
+	// Those devices are still tracked, so their attributes and state changes are reported.
+	state, err := parseDeviceState(stateString)
+	if err != nil {
+		state = StateInvalid
+	}
+
+	info, err := newDevice(fields[0], parseDeviceAttributes(attrFields))
+	if err != nil {
+		return trackedDevice{}, err
+	}
+	return trackedDevice{state, stateString, info}, nil
+}
+
+func calculateInfoDiffs(oldDevices, newDevices map[string]trackedDevice) (events []DeviceInfoChangedEvent) {
+	for serial, oldDevice := range oldDevices {
+		device, ok := newDevices[serial]
+
+		if !ok {
+			// Device only present in old list: device removed.
+			events = append(events, DeviceInfoChangedEvent{
+				Serial:      serial,
+				OldState:    oldDevice.State,
+				NewState:    StateDisconnected,
+				OldRawState: oldDevice.RawState,
+				OldInfo:     oldDevice.Info,
+			})
+		} else if oldDevice.State != device.State || oldDevice.RawState != device.RawState || *oldDevice.Info != *device.Info {
+			// Device present in both lists: state or attributes changed.
+			events = append(events, DeviceInfoChangedEvent{
+				Serial:      serial,
+				OldState:    oldDevice.State,
+				NewState:    device.State,
+				OldRawState: oldDevice.RawState,
+				NewRawState: device.RawState,
+				OldInfo:     oldDevice.Info,
+				NewInfo:     device.Info,
+			})
+		}
+	}
+
+	for serial, device := range newDevices {
+		if _, ok := oldDevices[serial]; !ok {
+			// Device only present in new list: device added.
+			events = append(events, DeviceInfoChangedEvent{
+				Serial:      serial,
+				OldState:    StateDisconnected,
+				NewState:    device.State,
+				NewRawState: device.RawState,
+				NewInfo:     device.Info,
+			})
+		}
+	}
+
+	return events
+}
@@ -0,0 +1,106 @@
+package adb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTrackedDevices(t *testing.T) {
+	devices, err := parseTrackedDevices(`192.168.56.101:5555    device product:vbox86p model:Nexus_5 device:vbox86p transport_id:2
+05856558               offline usb:1-1 transport_id:1
+ZX1G22                 no permissions (user in plugdev group; are your udev rules wrong?); see [http://developer.android.com/tools/device.html] usb:1-2 transport_id:3
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]trackedDevice{
+		"192.168.56.101:5555": {StateOnline, "device", &DeviceInfo{
+			Serial:      "192.168.56.101:5555",
+			Product:     "vbox86p",
+			Model:       "Nexus_5",
+			DeviceInfo:  "vbox86p",
+			TransportID: 2,
+		}},
+		"05856558": {StateOffline, "offline", &DeviceInfo{
+			Serial:      "05856558",
+			Usb:         "1-1",
+			TransportID: 1,
+		}},
+		"ZX1G22": {StateNoPermissions, "no permissions", &DeviceInfo{
+			Serial:      "ZX1G22",
+			Usb:         "1-2",
+			TransportID: 3,
+		}},
+	}, devices)
+}
+
+func TestParseTrackedDevicesUnknownState(t *testing.T) {
+	devices, err := parseTrackedDevices("05856558    recovery usb:1-1 product:sargo transport_id:4\n" +
+		"192.168.1.2:5555    device transport_id:5\n")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]trackedDevice{
+		"05856558": {StateInvalid, "recovery", &DeviceInfo{
+			Serial:      "05856558",
+			Usb:         "1-1",
+			Product:     "sargo",
+			TransportID: 4,
+		}},
+		"192.168.1.2:5555": {StateOnline, "device", &DeviceInfo{
+			Serial:      "192.168.1.2:5555",
+			TransportID: 5,
+		}},
+	}, devices)
+}
+
+func TestCalculateInfoDiffsAttributesChanged(t *testing.T) {
+	oldInfo := &DeviceInfo{Serial: "1", Usb: "1-1", TransportID: 1}
+	newInfo := &DeviceInfo{Serial: "1", Usb: "1-2", TransportID: 1}
+	oldDevices := map[string]trackedDevice{"1": {StateOnline, "device", oldInfo}}
+	newDevices := map[string]trackedDevice{"1": {StateOnline, "device", newInfo}}
+
+	diffs := calculateInfoDiffs(oldDevices, newDevices)
+
+	assert.Equal(t, []DeviceInfoChangedEvent{
+		{Serial: "1", OldState: StateOnline, NewState: StateOnline, OldRawState: "device", NewRawState: "device",
+			OldInfo: oldInfo, NewInfo: newInfo},
+	}, diffs)
+	assert.False(t, diffs[0].StateChanged())
+}
+
+func TestCalculateInfoDiffsUnchanged(t *testing.T) {
+	oldDevices := map[string]trackedDevice{"1": {StateOnline, "device", &DeviceInfo{Serial: "1", Usb: "1-1"}}}
+	newDevices := map[string]trackedDevice{"1": {StateOnline, "device", &DeviceInfo{Serial: "1", Usb: "1-1"}}}
+
+	diffs := calculateInfoDiffs(oldDevices, newDevices)
+
+	assert.Empty(t, diffs)
+}
+
+func TestCalculateInfoDiffsAddedAndRemoved(t *testing.T) {
+	removed := &DeviceInfo{Serial: "1"}
+	added := &DeviceInfo{Serial: "2"}
+	oldDevices := map[string]trackedDevice{"1": {StateOnline, "device", removed}}
+	newDevices := map[string]trackedDevice{"2": {StateOffline, "offline", added}}
+
+	diffs := calculateInfoDiffs(oldDevices, newDevices)
+
+	assert.Len(t, diffs, 2)
+	assert.Contains(t, diffs, DeviceInfoChangedEvent{Serial: "1", OldState: StateOnline, NewState: StateDisconnected,
+		OldRawState: "device", OldInfo: removed})
+	assert.Contains(t, diffs, DeviceInfoChangedEvent{Serial: "2", OldState: StateDisconnected, NewState: StateOffline,
+		NewRawState: "offline", NewInfo: added})
+	assert.True(t, diffs[0].StateChanged())
+}
+
+func TestCalculateInfoDiffsUnknownStateChanged(t *testing.T) {
+	info := &DeviceInfo{Serial: "1", Usb: "1-1"}
+	oldDevices := map[string]trackedDevice{"1": {StateInvalid, "recovery", info}}
+	newDevices := map[string]trackedDevice{"1": {StateInvalid, "sideload", info}}
+
+	diffs := calculateInfoDiffs(oldDevices, newDevices)
+
+	assert.Equal(t, []DeviceInfoChangedEvent{
+		{Serial: "1", OldState: StateInvalid, NewState: StateInvalid, OldRawState: "recovery", NewRawState: "sideload",
+			OldInfo: info, NewInfo: info},
+	}, diffs)
+	assert.True(t, diffs[0].StateChanged())
+}
@@ -1,6 +1,7 @@
 package adb
 
 import (
+	"context"
 	"testing"
 
 	"github.com/kvnxiao/go-adb/internal/errors"
@@ -15,14 +16,14 @@ func TestGetAttribute(t *testing.T) {
 	}
 	client := (&Adb{s}).Device(DeviceWithSerial("serial"))
 
-	v, err := client.getAttribute("attr")
+	v, err := client.getAttribute(context.Background(), "attr")
 	assert.Equal(t, "host-serial:serial:attr", s.Requests[0])
 	assert.NoError(t, err)
 	assert.Equal(t, "value", v)
 }
 
 func TestGetDeviceInfo(t *testing.T) {
-	deviceLister := func() ([]*DeviceInfo, error) {
+	deviceLister := func(context.Context) ([]*DeviceInfo, error) {
 		return []*DeviceInfo{
 			{
 				Serial:  "abc",
@@ -53,7 +54,7 @@ func TestGetDeviceInfo(t *testing.T) {
 	assert.Nil(t, device)
 }
 
-func newDeviceClientWithDeviceLister(serial string, deviceLister func() ([]*DeviceInfo, error)) *Device {
+func newDeviceClientWithDeviceLister(serial string, deviceLister func(context.Context) ([]*DeviceInfo, error)) *Device {
 	client := (&Adb{&MockServer{
 		Status:   wire.StatusSuccess,
 		Messages: []string{serial},
@@ -71,11 +72,30 @@ func TestRunCommandNoArgs(t *testing.T) {
 
 	v, err := client.RunCommandAsString("cmd")
 	assert.Equal(t, "host:transport-any", s.Requests[0])
-	assert.Equal(t, "shell:cmd", s.Requests[1])
+	assert.Equal(t, "exec:cmd", s.Requests[1])
 	assert.NoError(t, err)
 	assert.Equal(t, "output", v)
 }
 
+func TestFeatures(t *testing.T) {
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"shell_v2,cmd,stat_v2"},
+	}
+	client := (&Adb{s}).Device(DeviceWithSerial("abc"))
+	features, err := client.Features()
+	assert.NoError(t, err)
+	assert.Equal(t, "host-serial:abc:features", s.Requests[0])
+	assert.Equal(t, []string{"shell_v2", "cmd", "stat_v2"}, features)
+
+	// The features are only requested once.
+	features[0] = "changed"
+	features, err = client.Features()
+	assert.NoError(t, err)
+	assert.Len(t, s.Requests, 1)
+	assert.Equal(t, []string{"shell_v2", "cmd", "stat_v2"}, features)
+}
+
 func TestForward(t *testing.T) {
 	s := &MockServer{
 		Status:   wire.StatusSuccess,
@@ -176,6 +196,13 @@ func TestPrepareCommandLineArgWithDoubleQuoteFails(t *testing.T) {
 	assert.Equal(t, "arg at index 0 contains an invalid double quote: quoted\"arg", message(err))
 }
 
+func TestPrepareCommandLineDoesNotModifyArgs(t *testing.T) {
+	args := []string{"arg with spaces"}
+	_, err := prepareCommandLine("cmd", args...)
+	assert.NoError(t, err)
+	assert.Equal(t, "arg with spaces", args[0])
+}
+
 func code(err error) errors.ErrCode {
 	return err.(*errors.Err).Code
 }
@@ -1,7 +1,9 @@
 package adb
 
 import (
+	"context"
 	"log"
+	"math"
 	"math/rand"
 	"runtime"
 	"strings"
@@ -18,6 +20,8 @@ If the server dies while listening for events, it restarts the server.
 */
 type DeviceWatcher struct {
 	*deviceWatcherImpl
+
+	eventChan chan DeviceStateChangedEvent
 }
 
 // DeviceStateChangedEvent represents a device state transition.
@@ -39,20 +43,125 @@ func (s DeviceStateChangedEvent) WentOffline() bool {
 	return s.OldState == StateOnline && s.NewState != StateOnline
 }
 
+// DeviceWatcherConfig configures a DeviceWatcher or DeviceInfoWatcher, see
+// Adb.NewDeviceWatcherWithConfig.
+type DeviceWatcherConfig struct {
+	// Backoff controls how the watcher reconnects after the server dies.
+	Backoff Backoff
+}
+
+/*
+Backoff configures the delays between attempts to restart the server and reconnect to it.
+
+The delay before the first attempt is InitialDelay, and is multiplied by Multiplier after
+every failed attempt, up to MaxDelay. Each delay is randomly reduced by up to half in case
+multiple DeviceWatchers are trying to start the same server.
+The zero value uses the defaults documented on each field.
+*/
+type Backoff struct {
+	// Delay before the first attempt. Defaults to 100ms.
+	InitialDelay time.Duration
+
+	// Upper bound for the delay between attempts. Defaults to 10s.
+	MaxDelay time.Duration
+
+	// Factor the delay is multiplied by after each failed attempt. Defaults to 2.
+	Multiplier float64
+
+	// Number of consecutive failed attempts after which the watcher gives up, in which case
+	// the last error is reported by Err. Defaults to 5. If negative, the watcher never gives up.
+	MaxAttempts int
+}
+
+const (
+	defaultBackoffInitialDelay = 100 * time.Millisecond
+	defaultBackoffMaxDelay     = 10 * time.Second
+	defaultBackoffMultiplier   = 2
+	defaultBackoffMaxAttempts  = 5
+)
+
+func (b Backoff) withDefaults() Backoff {
+	if b.InitialDelay <= 0 {
+		b.InitialDelay = defaultBackoffInitialDelay
+	}
+	if b.MaxDelay <= 0 {
+		b.MaxDelay = defaultBackoffMaxDelay
+	}
+	if b.Multiplier < 1 {
+		b.Multiplier = defaultBackoffMultiplier
+	}
+	if b.MaxAttempts == 0 {
+		b.MaxAttempts = defaultBackoffMaxAttempts
+	}
+	return b
+}
+
+// delay returns how long to wait before the attempt with the given 0-based index.
+func (b Backoff) delay(attempt int) time.Duration {
+	delay := float64(b.InitialDelay) * math.Pow(b.Multiplier, float64(attempt))
+	if delay > float64(b.MaxDelay) {
+		delay = float64(b.MaxDelay)
+	}
+	// Randomize to [delay/2, delay).
+	return time.Duration(delay/2 + rand.Float64()*delay/2)
+}
+
+// exhausted returns true if no more attempts should be made after attempts failed ones.
+func (b Backoff) exhausted(attempts int) bool {
+	return b.MaxAttempts >= 0 && attempts >= b.MaxAttempts
+}
+
 type deviceWatcherImpl struct {
 	server server
 
-	// If an error occurs, it is stored here and eventChan is close immediately after.
+	backoff Backoff
+
+	// Service requested from the server, e.g. "host:track-devices".
+	service string
+
+	// Parses a device list sent by the server and publishes the resulting events.
+	// Must return as soon as ctx is done.
+	publish func(ctx context.Context, msg string) error
+
+	// Closes the event channel, called when publishDevices returns.
+	closeEvents func()
+
+	// When done, the watcher stops and the event channel is closed.
+	ctx context.Context
+
+	// Cancels ctx, called by Shutdown.
+	cancel context.CancelFunc
+
+	// Set to 1 by Shutdown, so stopping isn't reported as an error.
+	shutdown int32
+
+	// Closed when publishDevices returns.
+	done chan struct{}
+
+	// If an error occurs, it is stored here and the event channel is closed immediately after.
 	err atomic.Value
+}
 
-	eventChan chan DeviceStateChangedEvent
+func newDeviceWatcherImpl(ctx context.Context, server server, config DeviceWatcherConfig, service string) *deviceWatcherImpl {
+	ctx, cancel := context.WithCancel(ctx)
+	return &deviceWatcherImpl{
+		server:  server,
+		backoff: config.Backoff.withDefaults(),
+		service: service,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
 }
 
-func newDeviceWatcher(server server) *DeviceWatcher {
-	watcher := &DeviceWatcher{&deviceWatcherImpl{
-		server:    server,
-		eventChan: make(chan DeviceStateChangedEvent),
-	}}
+func newDeviceWatcher(ctx context.Context, server server, config DeviceWatcherConfig) *DeviceWatcher {
+	eventChan := make(chan DeviceStateChangedEvent)
+	watcher := &DeviceWatcher{
+		deviceWatcherImpl: newDeviceWatcherImpl(ctx, server, config, "host:track-devices"),
+		eventChan:         eventChan,
+	}
+	watcher.publish = newDeviceStatePublisher(eventChan)
+	watcher.closeEvents = func() { close(eventChan) }
 
 	runtime.SetFinalizer(watcher, func(watcher *DeviceWatcher) {
 		watcher.Shutdown()
@@ -73,7 +182,8 @@ func (w *DeviceWatcher) C() <-chan DeviceStateChangedEvent {
 
 // Err returns the error that caused the channel returned by C to be closed, if C is closed.
 // If C is not closed, its return value is undefined.
-func (w *DeviceWatcher) Err() error {
+// If C was closed by Shutdown, returns nil.
+func (w *deviceWatcherImpl) Err() error {
 	if err, ok := w.err.Load().(error); ok {
 		return err
 	}
@@ -81,106 +191,140 @@ func (w *DeviceWatcher) Err() error {
 }
 
 // Shutdown stops the watcher from listening for events and closes the channel returned
-// from C.
-func (w *DeviceWatcher) Shutdown() {
-	// TODO(z): Implement.
+// from C. The channel is closed by the time Shutdown returns.
+// It's safe to call Shutdown more than once.
+func (w *deviceWatcherImpl) Shutdown() {
+	atomic.StoreInt32(&w.shutdown, 1)
+	w.cancel()
+	<-w.done
 }
 
 func (w *deviceWatcherImpl) reportErr(err error) {
 	w.err.Store(err)
 }
 
+// reportStopped reports err as the reason the watcher stopped, unless it was stopped by Shutdown.
+func (w *deviceWatcherImpl) reportStopped(err error) {
+	if atomic.LoadInt32(&w.shutdown) == 0 {
+		w.reportErr(wrapContextErr(w.ctx, err))
+	}
+}
+
 /*
-publishDevices reads device lists from scanner, calculates diffs, and publishes events on
-eventChan.
-Returns when scanner returns an error.
+publishDevices reads device lists from the server and passes them to the watcher's publish
+function.
+
+If the server dies, restarts it and reconnects according to the watcher's Backoff.
+Returns when an unrecoverable error occurs, or the watcher's context is done.
 Doesn't refer directly to a *DeviceWatcher so it can be GCed (which will,
-in turn, close Scanner and stop this goroutine).
-
-TODO: to support shutdown, spawn a new goroutine each time a server connection is established.
-This goroutine should read messages and send them to a message channel. Can write errors directly
-to errVal. publishDevicesUntilError should take the msg chan and the scanner and select on the msg chan and stop chan, and if the stop
-chan sends, close the scanner and return true. If the msg chan closes, just return false.
-publishDevices can look at ret val: if false and err == EOF, reconnect. If false and other error, report err
-and abort. If true, report no error and stop.
+in turn, call Shutdown and stop this goroutine).
 */
 func publishDevices(watcher *deviceWatcherImpl) {
-	defer close(watcher.eventChan)
+	defer close(watcher.done)
+	defer watcher.closeEvents()
 
-	var lastKnownStates map[string]DeviceState
-	finished := false
+	// Number of consecutive failed attempts to reconnect.
+	failedAttempts := 0
+	reconnecting := false
 
 	for {
-		scanner, err := connectToTrackDevices(watcher.server)
-		if err != nil {
-			watcher.reportErr(err)
-			return
+		scanner, err := connectToTrackDevices(watcher.ctx, watcher.server, watcher.service)
+		if err == nil {
+			failedAttempts = 0
+			reconnecting = false
+			err = publishDevicesUntilError(watcher.ctx, scanner, watcher.publish)
+			scanner.Close()
 		}
 
-		finished, err = publishDevicesUntilError(scanner, watcher.eventChan, &lastKnownStates)
-
-		if finished {
-			scanner.Close()
+		if watcher.ctx.Err() != nil {
+			watcher.reportStopped(err)
 			return
 		}
 
-		if HasErrCode(err, ConnectionResetError) {
-			// The server died, restart and reconnect.
-
-			// Delay by a random [0ms, 500ms) in case multiple DeviceWatchers are trying to
-			// start the same server.
-			delay := time.Duration(rand.Intn(500)) * time.Millisecond
-
-			log.Printf("[DeviceWatcher] server died, restarting in %s…", delay)
-			time.Sleep(delay)
-			if err := watcher.server.Start(); err != nil {
-				log.Println("[DeviceWatcher] error restarting server, giving up")
-				watcher.reportErr(err)
-				return
-			} // Else server should be running, continue listening.
-		} else {
+		if reconnecting {
+			failedAttempts++
+		} else if !HasErrCode(err, ConnectionResetError) {
 			// Unknown error, don't retry.
 			watcher.reportErr(err)
 			return
 		}
+
+		// The server died, or couldn't be restarted yet.
+		if watcher.backoff.exhausted(failedAttempts) {
+			log.Println("[DeviceWatcher] error restarting server, giving up")
+			watcher.reportErr(err)
+			return
+		}
+		reconnecting = true
+
+		delay := watcher.backoff.delay(failedAttempts)
+		log.Printf("[DeviceWatcher] server died, restarting in %s…", delay)
+		select {
+		case <-time.After(delay):
+		case <-watcher.ctx.Done():
+			watcher.reportStopped(err)
+			return
+		}
+
+		if err := watcher.server.Start(); err != nil {
+			log.Printf("[DeviceWatcher] error restarting server: %v", err)
+		} // Else server should be running, reconnect.
 	}
 }
 
-func connectToTrackDevices(server server) (wire.Scanner, error) {
-	conn, err := server.Dial()
+// connectToTrackDevices requests service, one of the host:track-devices variants.
+func connectToTrackDevices(ctx context.Context, server server, service string) (wire.Scanner, error) {
+	conn, err := server.DialContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := wire.SendMessageString(conn, "host:track-devices"); err != nil {
+	if err := wire.SendMessageString(conn, service); err != nil {
 		conn.Close()
-		return nil, err
+		return nil, wrapContextErr(ctx, err)
 	}
 
-	if _, err := conn.ReadStatus("host:track-devices"); err != nil {
+	if _, err := conn.ReadStatus(service); err != nil {
 		conn.Close()
-		return nil, err
+		return nil, wrapContextErr(ctx, err)
 	}
 
 	return conn, nil
 }
 
-func publishDevicesUntilError(scanner wire.Scanner, eventChan chan<- DeviceStateChangedEvent, lastKnownStates *map[string]DeviceState) (finished bool, err error) {
+func publishDevicesUntilError(ctx context.Context, scanner wire.Scanner, publish func(context.Context, string) error) error {
 	for {
 		msg, err := scanner.ReadMessage()
 		if err != nil {
-			return false, err
+			return err
+		}
+
+		if err := publish(ctx, string(msg)); err != nil {
+			return err
 		}
+	}
+}
+
+// newDeviceStatePublisher returns a publish function for DeviceWatcher.
+// The last known states are kept across reconnects, so only actual changes are published.
+func newDeviceStatePublisher(eventChan chan<- DeviceStateChangedEvent) func(context.Context, string) error {
+	var lastKnownStates map[string]DeviceState
 
-		deviceStates, err := parseDeviceStates(string(msg))
+	return func(ctx context.Context, msg string) error {
+		deviceStates, err := parseDeviceStates(msg)
 		if err != nil {
-			return false, err
+			return err
 		}
 
-		for _, event := range calculateStateDiffs(*lastKnownStates, deviceStates) {
-			eventChan <- event
+		for _, event := range calculateStateDiffs(lastKnownStates, deviceStates) {
+			select {
+			case eventChan <- event:
+			case <-ctx.Done():
+				return wrapContextErr(ctx, ctx.Err())
+			}
 		}
-		*lastKnownStates = deviceStates
+		lastKnownStates = deviceStates
+		return nil
 	}
 }
 
@@ -1,7 +1,11 @@
 package adb
 
 import (
+	"context"
+	"io"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/kvnxiao/go-adb/internal/errors"
 	"github.com/kvnxiao/go-adb/wire"
@@ -211,23 +215,141 @@ func TestPublishDevicesRestartsServer(t *testing.T) {
 		Errs: []error{
 			nil, nil, nil, // Successful dial.
 			errors.Errorf(errors.ConnectionResetError, "failed first read"),
+			nil, nil, // Close sender and scanner.
 			errors.Errorf(errors.ServerNotAvailable, "failed redial"),
 		},
 	}
 	watcher := deviceWatcherImpl{
-		server:    server,
-		eventChan: make(chan DeviceStateChangedEvent),
+		server:      server,
+		backoff:     Backoff{InitialDelay: time.Millisecond, MaxAttempts: 1}.withDefaults(),
+		service:     "host:track-devices",
+		publish:     newDeviceStatePublisher(make(chan DeviceStateChangedEvent)),
+		closeEvents: func() {},
+		ctx:         context.Background(),
+		done:        make(chan struct{}),
 	}
 
 	publishDevices(&watcher)
 
 	assert.Empty(t, server.Errs)
 	assert.Equal(t, []string{"host:track-devices"}, server.Requests)
-	assert.Equal(t, []string{"Dial", "SendMessage", "ReadStatus", "ReadMessage", "Start", "Dial"}, server.Trace)
+	assert.Equal(t, []string{"Dial", "SendMessage", "ReadStatus", "ReadMessage", "Close", "Close", "Start", "Dial"}, server.Trace)
 	err := watcher.err.Load().(*errors.Err)
 	assert.Equal(t, errors.ServerNotAvailable, err.Code)
 }
 
+func TestPublishDevicesRetriesWithBackoff(t *testing.T) {
+	server := &MockServer{
+		Status: wire.StatusSuccess,
+		Errs: []error{
+			nil, nil, nil, // Successful dial.
+			errors.Errorf(errors.ConnectionResetError, "failed first read"),
+			nil, nil, // Close sender and scanner.
+			errors.Errorf(errors.ServerNotAvailable, "failed redial 1"),
+			errors.Errorf(errors.ServerNotAvailable, "failed redial 2"),
+			errors.Errorf(errors.ServerNotAvailable, "failed redial 3"),
+		},
+	}
+	watcher := deviceWatcherImpl{
+		server:      server,
+		backoff:     Backoff{InitialDelay: time.Millisecond, MaxAttempts: 3}.withDefaults(),
+		service:     "host:track-devices",
+		publish:     newDeviceStatePublisher(make(chan DeviceStateChangedEvent)),
+		closeEvents: func() {},
+		ctx:         context.Background(),
+		done:        make(chan struct{}),
+	}
+
+	publishDevices(&watcher)
+
+	assert.Empty(t, server.Errs)
+	assert.Equal(t, []string{"Dial", "SendMessage", "ReadStatus", "ReadMessage", "Close", "Close",
+		"Start", "Dial", "Start", "Dial", "Start", "Dial"}, server.Trace)
+	err := watcher.err.Load().(*errors.Err)
+	assert.Equal(t, "failed redial 3", err.Message)
+}
+
+func TestBackoffDelay(t *testing.T) {
+	backoff := Backoff{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second}.withDefaults()
+
+	for attempt, max := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		delay := backoff.delay(attempt)
+		assert.True(t, delay >= max/2 && delay < max, "attempt %d: %s not in [%s, %s)", attempt, delay, max/2, max)
+	}
+
+	assert.False(t, backoff.exhausted(4))
+	assert.True(t, backoff.exhausted(5))
+	assert.False(t, Backoff{MaxAttempts: -1}.exhausted(1000))
+}
+
+func TestDeviceWatcherShutdown(t *testing.T) {
+	watcher := newDeviceWatcher(context.Background(), &blockingServer{}, DeviceWatcherConfig{})
+
+	shutdown := make(chan struct{})
+	go func() {
+		watcher.Shutdown()
+		close(shutdown)
+	}()
+
+	select {
+	case <-shutdown:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown didn't return")
+	}
+	_, ok := <-watcher.C()
+	assert.False(t, ok)
+	assert.NoError(t, watcher.Err())
+
+	// Shutting down again is a no-op.
+	watcher.Shutdown()
+}
+
+func TestDeviceWatcherContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	watcher := newDeviceWatcher(ctx, &blockingServer{}, DeviceWatcherConfig{})
+	cancel()
+
+	select {
+	case _, ok := <-watcher.C():
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel wasn't closed")
+	}
+	assert.True(t, HasErrCode(watcher.Err(), ContextCanceled))
+}
+
+// blockingServer accepts track-devices requests and then never sends any device lists.
+type blockingServer struct{}
+
+func (s *blockingServer) Start() error {
+	return nil
+}
+
+func (s *blockingServer) Dial() (*wire.Conn, error) {
+	return s.DialContext(context.Background())
+}
+
+func (s *blockingServer) DialContext(ctx context.Context) (*wire.Conn, error) {
+	client, server := net.Pipe()
+	go func() {
+		// Read the request and accept it, then just keep the connection open.
+		buf := make([]byte, 4+len("host:track-devices"))
+		io.ReadFull(server, buf)
+		server.Write([]byte(wire.StatusSuccess))
+	}()
+
+	conn := wire.NewConn(wire.NewScanner(client), wire.NewSender(client))
+	conn.WatchContext(ctx)
+	return conn, nil
+}
+
 func assertContainsOnly(t *testing.T, expected, actual []DeviceStateChangedEvent) {
 	assert.Len(t, actual, len(expected))
 	for _, expectedEntry := range expected {
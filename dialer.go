@@ -1,6 +1,7 @@
 package adb
 
 import (
+	"context"
 	"io"
 	"net"
 	"runtime"
@@ -14,12 +15,24 @@ type Dialer interface {
 	Dial(address string) (*wire.Conn, error)
 }
 
+// ContextDialer is implemented by Dialers that can stop connecting when a context is done.
+// Dialers that don't implement it are only checked for cancellation once they return.
+type ContextDialer interface {
+	DialContext(ctx context.Context, address string) (*wire.Conn, error)
+}
+
 type tcpDialer struct{}
 
 // Dial connects to the adb server on the host and port set on the netDialer.
 // The zero-value will connect to the default, localhost:5037.
-func (tcpDialer) Dial(address string) (*wire.Conn, error) {
-	netConn, err := net.Dial("tcp", address)
+func (d tcpDialer) Dial(address string) (*wire.Conn, error) {
+	return d.DialContext(context.Background(), address)
+}
+
+// DialContext is like Dial, but gives up when ctx is done or its deadline is reached.
+func (tcpDialer) DialContext(ctx context.Context, address string) (*wire.Conn, error) {
+	var dialer net.Dialer
+	netConn, err := dialer.DialContext(ctx, "tcp", address)
 	if err != nil {
 		return nil, errors.WrapErrorf(err, errors.ServerNotAvailable, "error dialing %s", address)
 	}
@@ -36,8 +49,5 @@ func (tcpDialer) Dial(address string) (*wire.Conn, error) {
 		conn.Close()
 	})
 
-	return &wire.Conn{
-		Scanner: wire.NewScanner(safeConn),
-		Sender:  wire.NewSender(safeConn),
-	}, nil
+	return wire.NewConn(wire.NewScanner(safeConn), wire.NewSender(safeConn)), nil
 }
@@ -12,14 +12,31 @@ import (
 type DirEntry struct {
 	Name       string
 	Mode       os.FileMode
-	Size       int32
+	Size       int64
 	ModifiedAt time.Time
+
+	// The following fields are only set if the device supports FeatureStat2 (for Stat) or
+	// FeatureLs2 (for ListDirEntries).
+	Dev        uint64
+	Inode      uint64
+	Nlink      uint32
+	UID        uint32
+	GID        uint32
+	AccessedAt time.Time
+	ChangedAt  time.Time
+
+	// Errno is the error number reported by the device if it couldn't lstat the entry while
+	// listing its directory, in which case only Name is set.
+	Errno uint32
 }
 
 // DirEntries iterates over directory entries.
 type DirEntries struct {
 	scanner wire.SyncScanner
 
+	// True if the entries were requested with LIS2.
+	v2 bool
+
 	currentEntry *DirEntry
 	err          error
 }
@@ -43,7 +60,11 @@ func (entries *DirEntries) Next() bool {
 		return false
 	}
 
-	entry, done, err := readNextDirListEntry(entries.scanner)
+	readEntry := readNextDirListEntry
+	if entries.v2 {
+		readEntry = readNextDirListEntryV2
+	}
+	entry, done, err := readEntry(entries.scanner)
 	if err != nil {
 		entries.err = err
 		entries.Close()
@@ -112,8 +133,36 @@ func readNextDirListEntry(s wire.SyncScanner) (entry *DirEntry, done bool, err e
 	entry = &DirEntry{
 		Name:       name,
 		Mode:       mode,
-		Size:       size,
+		Size:       int64(uint32(size)),
 		ModifiedAt: mtime,
 	}
 	return
 }
+
+func readNextDirListEntryV2(s wire.SyncScanner) (entry *DirEntry, done bool, err error) {
+	status, err := s.ReadStatus("dir-entry")
+	if err != nil {
+		return
+	}
+
+	if status == "DONE" {
+		done = true
+		return
+	} else if status != "DNT2" {
+		err = fmt.Errorf("error reading dir entries: expected dir entry ID 'DNT2', but got '%s'", status)
+		return
+	}
+
+	entry, err = readStatV2(s)
+	if err != nil {
+		err = fmt.Errorf("error reading dir entries: %v", err)
+		return
+	}
+	name, err := s.ReadString()
+	if err != nil {
+		err = fmt.Errorf("error reading dir entries: error reading file name: %v", err)
+		return
+	}
+	entry.Name = name
+	return
+}
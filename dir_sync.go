@@ -0,0 +1,452 @@
+package adb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+// SyncOptions configures Device.SyncDirWithOptions.
+type SyncOptions struct {
+	// Delete removes remote files and directories that don't exist locally.
+	Delete bool
+
+	// Checksum compares files that have the same size but different modification times by
+	// their MD5 checksum, so they're only pushed if their contents changed. The remote checksums
+	// are computed with the md5sum command on the device. Remote files whose contents didn't
+	// change are given the local modification time, so they aren't compared again.
+	Checksum bool
+
+	// DryRun only computes the plan, without changing anything on the device.
+	DryRun bool
+
+	// Transfer configures the transfer of each file.
+	Transfer TransferOptions
+
+	// Progress, if not nil, is called while each file is pushed, like TreeOptions.Progress.
+	Progress func(FileProgress)
+}
+
+// SyncActionType is the kind of change a SyncAction makes to the device.
+type SyncActionType int
+
+const (
+	// SyncPush pushes a file that's new or changed.
+	SyncPush SyncActionType = iota
+
+	// SyncMkdir creates an empty directory. Non-empty directories are created by pushing
+	// their files.
+	SyncMkdir
+
+	// SyncDelete removes a file or directory from the device.
+	SyncDelete
+
+	// SyncTouch sets the modification time of a file whose contents didn't change, see
+	// SyncOptions.Checksum.
+	SyncTouch
+)
+
+func (t SyncActionType) String() string {
+	switch t {
+	case SyncPush:
+		return "push"
+	case SyncMkdir:
+		return "mkdir"
+	case SyncDelete:
+		return "delete"
+	case SyncTouch:
+		return "touch"
+	default:
+		return fmt.Sprintf("SyncActionType(%d)", int(t))
+	}
+}
+
+// SyncAction is a single change to the device needed to mirror the local tree.
+type SyncAction struct {
+	Type SyncActionType
+
+	// Local is the path of the local file, empty for SyncDelete.
+	Local string
+
+	// Remote is the path on the device.
+	Remote string
+
+	// Size is the number of bytes to push, for SyncPush.
+	Size int64
+
+	// Reason explains why the action is needed, e.g. "new" or "size changed".
+	Reason string
+}
+
+func (a SyncAction) String() string {
+	if a.Type == SyncPush {
+		return fmt.Sprintf("push %s -> %s (%s, %d bytes)", a.Local, a.Remote, a.Reason, a.Size)
+	}
+	return fmt.Sprintf("%s %s (%s)", a.Type, a.Remote, a.Reason)
+}
+
+// SyncPlan lists the changes made, or to be made for a dry run, by Device.SyncDirWithOptions.
+// Deletions come first, then directories to create, then files to push, then files to touch.
+type SyncPlan struct {
+	Actions []SyncAction
+
+	// Unchanged is the number of local files that didn't need to be pushed, including the ones
+	// that are touched.
+	Unchanged int
+}
+
+// Size returns the number of bytes the plan pushes.
+func (p *SyncPlan) Size() (size int64) {
+	for _, action := range p.Actions {
+		size += action.Size
+	}
+	return
+}
+
+// String returns one line per action.
+func (p *SyncPlan) String() string {
+	var buf bytes.Buffer
+	for _, action := range p.Actions {
+		fmt.Fprintln(&buf, action)
+	}
+	return buf.String()
+}
+
+/*
+SyncDir mirrors the local directory localDir to remoteDir on the device, like adb sync.
+
+Files are compared by size and modification time, and only new or changed files are pushed.
+Empty directories are created, but symlinks and special files are skipped. Remote files that
+don't exist locally are kept, use SyncDirWithOptions to delete them.
+
+Returns what was changed on the device. Like Push, SyncDir doesn't stop at the first change it
+fails to make, and the returned error combines the errors of all of them.
+*/
+func (c *Device) SyncDir(localDir, remoteDir string) (*SyncPlan, error) {
+	return c.SyncDirContext(context.Background(), localDir, remoteDir)
+}
+
+// SyncDirContext is like SyncDir, but stops as soon as ctx is done.
+func (c *Device) SyncDirContext(ctx context.Context, localDir, remoteDir string) (*SyncPlan, error) {
+	return c.SyncDirWithOptions(ctx, localDir, remoteDir, SyncOptions{})
+}
+
+// SyncDirWithOptions is like SyncDirContext, but allows deleting extraneous remote files,
+// comparing files by checksum, and computing the plan without executing it.
+func (c *Device) SyncDirWithOptions(ctx context.Context, localDir, remoteDir string, opts SyncOptions) (*SyncPlan, error) {
+	plan, err := c.planSync(ctx, localDir, remoteDir, opts)
+	if err != nil || opts.DryRun {
+		return plan, wrapClientError(err, c, "SyncDir(%s, %s)", localDir, remoteDir)
+	}
+
+	t := &treeCopier{ctx: ctx, device: c, opts: TreeOptions{Transfer: opts.Transfer, Progress: opts.Progress}}
+	for _, action := range plan.Actions {
+		if ctx.Err() != nil {
+			break
+		}
+		switch action.Type {
+		case SyncDelete:
+			if err := t.runShell("rm", "-rf", action.Remote); err != nil {
+				t.fail("", action.Remote, err)
+			}
+		case SyncMkdir:
+			if err := t.runShell("mkdir", "-p", action.Remote); err != nil {
+				t.fail(action.Local, action.Remote, err)
+			}
+		case SyncPush:
+			info, err := os.Stat(action.Local)
+			if err != nil {
+				t.fail(action.Local, action.Remote, wrapLocalErr(err, "error reading %s", action.Local))
+				continue
+			}
+			t.pushFile(action.Local, action.Remote, info)
+		case SyncTouch:
+			info, err := os.Stat(action.Local)
+			if err != nil {
+				t.fail(action.Local, action.Remote, wrapLocalErr(err, "error reading %s", action.Local))
+				continue
+			}
+			mtime := "@" + strconv.FormatInt(info.ModTime().Unix(), 10)
+			if err := t.runShell("touch", "-m", "-d", mtime, action.Remote); err != nil {
+				t.fail(action.Local, action.Remote, err)
+			}
+		}
+	}
+	return plan, wrapClientError(t.err("error syncing "+localDir), c, "SyncDir(%s, %s)", localDir, remoteDir)
+}
+
+// syncTreeEntry is either a local or a remote entry of a tree being synced.
+type syncTreeEntry struct {
+	mode  os.FileMode
+	size  int64
+	mtime int64
+
+	// True for directories that have no entries.
+	empty bool
+}
+
+// planSync compares the trees at localDir and remoteDir and returns the actions needed to make
+// the remote tree like the local one.
+func (c *Device) planSync(ctx context.Context, localDir, remoteDir string, opts SyncOptions) (*SyncPlan, error) {
+	local, err := walkLocalTree(localDir)
+	if err != nil {
+		return nil, err
+	}
+
+	remote := map[string]*syncTreeEntry{}
+	root, err := c.statFollow(ctx, remoteDir)
+	if err == nil {
+		if !root.Mode.IsDir() {
+			return nil, errors.Errorf(errors.AssertionError, "%s is not a directory on the device", remoteDir)
+		}
+		if err := c.walkRemoteTree(ctx, remoteDir, "", remote); err != nil {
+			return nil, err
+		}
+	} else if !HasErrCode(err, FileNoExistError) {
+		return nil, err
+	}
+
+	plan, toChecksum := diffSyncTrees(localDir, remoteDir, local, remote, opts)
+	if len(toChecksum) > 0 {
+		pushes, touches, err := c.compareChecksums(ctx, toChecksum)
+		if err != nil {
+			return nil, err
+		}
+		plan.Actions = append(append(plan.Actions, pushes...), touches...)
+		plan.Unchanged += len(toChecksum) - len(pushes)
+	}
+	return plan, nil
+}
+
+/*
+diffSyncTrees compares the local and remote trees, both keyed by slash-separated paths
+relative to their roots, and returns a plan of the actions needed.
+
+Files that need their checksums compared to decide whether to push them are returned separately
+as pushes, without being added to the plan.
+*/
+func diffSyncTrees(localDir, remoteDir string, local, remote map[string]*syncTreeEntry, opts SyncOptions) (plan *SyncPlan, toChecksum []SyncAction) {
+	plan = &SyncPlan{}
+	var deletes, mkdirs, pushes []SyncAction
+	deleted := map[string]bool{}
+
+	for _, rel := range sortedKeys(local) {
+		localEntry, remoteEntry := local[rel], remote[rel]
+		action := SyncAction{
+			Local:  filepath.Join(localDir, filepath.FromSlash(rel)),
+			Remote: path.Join(remoteDir, rel),
+		}
+
+		if remoteEntry != nil && remoteEntry.mode.Type() != localEntry.mode.Type() {
+			// The new file or directory can't be created until the old one is gone.
+			deletes = append(deletes, SyncAction{Type: SyncDelete, Remote: action.Remote, Reason: "type changed"})
+			deleted[rel] = true
+			remoteEntry = nil
+		}
+
+		if localEntry.mode.IsDir() {
+			if remoteEntry == nil && localEntry.empty {
+				action.Type = SyncMkdir
+				action.Reason = "new"
+				mkdirs = append(mkdirs, action)
+			}
+			continue
+		}
+
+		action.Type = SyncPush
+		action.Size = localEntry.size
+		switch {
+		case remoteEntry == nil:
+			action.Reason = "new"
+		case remoteEntry.size != localEntry.size:
+			action.Reason = "size changed"
+		case remoteEntry.mtime != localEntry.mtime:
+			if opts.Checksum {
+				action.Reason = "content changed"
+				toChecksum = append(toChecksum, action)
+				continue
+			}
+			action.Reason = "mtime changed"
+		default:
+			plan.Unchanged++
+			continue
+		}
+		pushes = append(pushes, action)
+	}
+
+	if opts.Delete {
+		for _, rel := range sortedKeys(remote) {
+			if local[rel] != nil || hasDeletedAncestor(rel, deleted) {
+				continue
+			}
+			deletes = append(deletes, SyncAction{Type: SyncDelete, Remote: path.Join(remoteDir, rel), Reason: "extraneous"})
+			deleted[rel] = true
+		}
+	}
+
+	plan.Actions = append(append(deletes, mkdirs...), pushes...)
+	return plan, toChecksum
+}
+
+// hasDeletedAncestor returns true if one of the directories containing rel is in deleted.
+func hasDeletedAncestor(rel string, deleted map[string]bool) bool {
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		if deleted[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(entries map[string]*syncTreeEntry) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// walkLocalTree returns the regular files and directories under root, keyed by their
+// slash-separated path relative to root.
+func walkLocalTree(root string) (map[string]*syncTreeEntry, error) {
+	entries := map[string]*syncTreeEntry{}
+	err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if file == root {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", root)
+			}
+			return nil
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		entries[rel] = &syncTreeEntry{
+			mode:  info.Mode(),
+			size:  info.Size(),
+			mtime: info.ModTime().Unix(),
+			empty: info.IsDir(),
+		}
+		if parent := entries[path.Dir(rel)]; parent != nil {
+			parent.empty = false
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, wrapLocalErr(err, "error reading %s", root)
+	}
+	return entries, nil
+}
+
+// walkRemoteTree adds the entries under dir on the device to entries, keyed by their path
+// relative to the root being walked. prefix is the relative path of dir.
+func (c *Device) walkRemoteTree(ctx context.Context, dir, prefix string, entries map[string]*syncTreeEntry) error {
+	children, err := c.listDir(ctx, dir)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		rel := path.Join(prefix, child.Name)
+		entries[rel] = &syncTreeEntry{
+			mode:  child.Mode,
+			size:  child.Size,
+			mtime: child.ModifiedAt.Unix(),
+		}
+		if child.Mode.IsDir() {
+			if err := c.walkRemoteTree(ctx, path.Join(dir, child.Name), rel, entries); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// maxChecksumArgs limits how many files are passed to a single md5sum command, to keep the
+// command line short enough for the device.
+const maxChecksumArgs = 64
+
+// compareChecksums returns the pushes in candidates whose local and remote files have different
+// MD5 checksums, and touches for the others. Files whose remote checksum can't be computed are
+// pushed.
+func (c *Device) compareChecksums(ctx context.Context, candidates []SyncAction) (pushes, touches []SyncAction, err error) {
+	for len(candidates) > 0 {
+		batch := candidates
+		if len(batch) > maxChecksumArgs {
+			batch = batch[:maxChecksumArgs]
+		}
+		candidates = candidates[len(batch):]
+
+		paths := make([]string, len(batch))
+		for i, action := range batch {
+			paths[i] = action.Remote
+		}
+		// md5sum exits with a non-zero status if any file couldn't be read, the others are
+		// still printed.
+		result, err := c.RunShellCommandContext(ctx, "md5sum", paths...)
+		if err != nil {
+			return nil, nil, err
+		}
+		remoteSums := parseChecksums(result.Stdout)
+
+		for _, action := range batch {
+			localSum, err := localChecksum(action.Local)
+			if err != nil {
+				return nil, nil, err
+			}
+			if remoteSums[action.Remote] != localSum {
+				pushes = append(pushes, action)
+			} else {
+				touches = append(touches, SyncAction{Type: SyncTouch, Local: action.Local,
+					Remote: action.Remote, Reason: "content unchanged"})
+			}
+		}
+	}
+	return pushes, touches, nil
+}
+
+// parseChecksums parses the output of md5sum into a map of path to checksum.
+func parseChecksums(output []byte) map[string]string {
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if fields := strings.SplitN(scanner.Text(), "  ", 2); len(fields) == 2 {
+			sums[fields[1]] = fields[0]
+		}
+	}
+	return sums
+}
+
+func localChecksum(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", wrapLocalErr(err, "error opening %s", file)
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", wrapLocalErr(err, "error reading %s", file)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,157 @@
+package adb
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func syncFile(size, mtime int64) *syncTreeEntry {
+	return &syncTreeEntry{mode: 0644, size: size, mtime: mtime}
+}
+
+func syncDir(empty bool) *syncTreeEntry {
+	return &syncTreeEntry{mode: os.ModeDir | 0755, empty: empty}
+}
+
+func TestDiffSyncTrees(t *testing.T) {
+	local := map[string]*syncTreeEntry{
+		"same":         syncFile(10, 100),
+		"new":          syncFile(20, 100),
+		"resized":      syncFile(30, 100),
+		"touched":      syncFile(40, 200),
+		"empty":        syncDir(true),
+		"sub":          syncDir(false),
+		"sub/was-dir":  syncFile(50, 100),
+		"sub/existing": syncDir(true),
+	}
+	remote := map[string]*syncTreeEntry{
+		"same":           syncFile(10, 100),
+		"resized":        syncFile(31, 100),
+		"touched":        syncFile(40, 100),
+		"sub":            syncDir(false),
+		"sub/was-dir":    syncDir(false),
+		"sub/was-dir/a":  syncFile(1, 100),
+		"sub/existing":   syncDir(false),
+		"sub/existing/a": syncFile(1, 100),
+		"old":            syncDir(false),
+		"old/a":          syncFile(1, 100),
+	}
+
+	plan, toChecksum := diffSyncTrees("local", "/sdcard/dst", local, remote, SyncOptions{})
+	assert.Empty(t, toChecksum)
+	assert.Equal(t, 1, plan.Unchanged)
+	assert.Equal(t, []SyncAction{
+		{Type: SyncDelete, Remote: "/sdcard/dst/sub/was-dir", Reason: "type changed"},
+		{Type: SyncMkdir, Local: filepath.Join("local", "empty"), Remote: "/sdcard/dst/empty", Reason: "new"},
+		{Type: SyncPush, Local: filepath.Join("local", "new"), Remote: "/sdcard/dst/new", Size: 20, Reason: "new"},
+		{Type: SyncPush, Local: filepath.Join("local", "resized"), Remote: "/sdcard/dst/resized", Size: 30, Reason: "size changed"},
+		{Type: SyncPush, Local: filepath.Join("local", "sub", "was-dir"), Remote: "/sdcard/dst/sub/was-dir", Size: 50, Reason: "new"},
+		{Type: SyncPush, Local: filepath.Join("local", "touched"), Remote: "/sdcard/dst/touched", Size: 40, Reason: "mtime changed"},
+	}, plan.Actions)
+	assert.Equal(t, int64(140), plan.Size())
+
+	plan, toChecksum = diffSyncTrees("local", "/sdcard/dst", local, remote, SyncOptions{Delete: true, Checksum: true})
+	assert.Equal(t, []SyncAction{
+		{Type: SyncPush, Local: filepath.Join("local", "touched"), Remote: "/sdcard/dst/touched", Size: 40, Reason: "content changed"},
+	}, toChecksum)
+	require.Len(t, plan.Actions, 7)
+	assert.Equal(t, []SyncAction{
+		{Type: SyncDelete, Remote: "/sdcard/dst/sub/was-dir", Reason: "type changed"},
+		{Type: SyncDelete, Remote: "/sdcard/dst/old", Reason: "extraneous"},
+		{Type: SyncDelete, Remote: "/sdcard/dst/sub/existing/a", Reason: "extraneous"},
+	}, plan.Actions[:3])
+}
+
+func TestDiffSyncTreesNoRemote(t *testing.T) {
+	plan, _ := diffSyncTrees("local", "/sdcard/dst", map[string]*syncTreeEntry{}, map[string]*syncTreeEntry{}, SyncOptions{})
+	assert.Empty(t, plan.Actions)
+	assert.Equal(t, "", plan.String())
+}
+
+func TestSyncPlanString(t *testing.T) {
+	plan := &SyncPlan{Actions: []SyncAction{
+		{Type: SyncDelete, Remote: "/sdcard/old", Reason: "extraneous"},
+		{Type: SyncPush, Local: "new", Remote: "/sdcard/new", Size: 3, Reason: "new"},
+	}}
+	assert.Equal(t, "delete /sdcard/old (extraneous)\npush new -> /sdcard/new (new, 3 bytes)\n", plan.String())
+}
+
+func TestWalkLocalTree(t *testing.T) {
+	root, err := ioutil.TempDir("", "adb")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "a", "empty"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "a", "file"), []byte("hello"), 0644))
+	require.NoError(t, os.Symlink("file", filepath.Join(root, "a", "link")))
+
+	entries, err := walkLocalTree(root)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.False(t, entries["a"].empty)
+	assert.True(t, entries["a/empty"].empty)
+	assert.Equal(t, int64(5), entries["a/file"].size)
+
+	_, err = walkLocalTree(filepath.Join(root, "a", "file"))
+	assert.Error(t, err)
+}
+
+func TestParseChecksums(t *testing.T) {
+	sums := parseChecksums([]byte("d41d8cd98f00b204e9800998ecf8427e  /sdcard/empty\n" +
+		"5d41402abc4b2a76b9719d911017c592  /sdcard/with space\n" +
+		"md5sum: /sdcard/gone: No such file or directory\n"))
+	assert.Equal(t, map[string]string{
+		"/sdcard/empty":      "d41d8cd98f00b204e9800998ecf8427e",
+		"/sdcard/with space": "5d41402abc4b2a76b9719d911017c592",
+	}, sums)
+}
+
+func TestHasDeletedAncestor(t *testing.T) {
+	deleted := map[string]bool{"a/b": true}
+	assert.True(t, hasDeletedAncestor("a/b/c", deleted))
+	assert.True(t, hasDeletedAncestor("a/b/c/d", deleted))
+	assert.False(t, hasDeletedAncestor("a/b", deleted))
+	assert.False(t, hasDeletedAncestor("a/bc", deleted))
+}
+
+func TestSyncDirChecksum(t *testing.T) {
+	root, err := ioutil.TempDir("", "adb")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	localTime := someTime.Add(time.Hour)
+	for name, data := range map[string]string{"same": "abc", "changed": "xy1"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(root, name), []byte(data), 0644))
+		require.NoError(t, os.Chtimes(filepath.Join(root, name), localTime, localTime))
+	}
+
+	device := newFakeDevice()
+	device.addFile("/sdcard/dst/same", 0644, someTime, "abc")
+	device.addFile("/sdcard/dst/changed", 0644, someTime, "xyz")
+	client := (&Adb{device}).Device(AnyDevice())
+
+	plan, err := client.SyncDirWithOptions(context.Background(), root, "/sdcard/dst", SyncOptions{Checksum: true})
+	require.NoError(t, err)
+	assert.Equal(t, []SyncAction{
+		{Type: SyncPush, Local: filepath.Join(root, "changed"), Remote: "/sdcard/dst/changed", Size: 3, Reason: "content changed"},
+		{Type: SyncTouch, Local: filepath.Join(root, "same"), Remote: "/sdcard/dst/same", Reason: "content unchanged"},
+	}, plan.Actions)
+	assert.Equal(t, 1, plan.Unchanged)
+	assert.Equal(t, "xy1", string(device.file("/sdcard/dst/changed").data))
+	assert.True(t, localTime.Equal(device.file("/sdcard/dst/same").mtime))
+
+	// The touched file isn't compared again.
+	commands := len(device.Commands)
+	plan, err = client.SyncDirWithOptions(context.Background(), root, "/sdcard/dst", SyncOptions{Checksum: true})
+	require.NoError(t, err)
+	assert.Empty(t, plan.Actions)
+	assert.Equal(t, 2, plan.Unchanged)
+	for _, cmd := range device.Commands[commands:] {
+		assert.NotContains(t, cmd, "md5sum")
+	}
+}
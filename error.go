@@ -22,6 +22,10 @@ const (
 	DeviceNotFound = ErrCode(errors.DeviceNotFound)
 	// Tried to perform an operation on a path that doesn't exist on the device.
 	FileNoExistError = ErrCode(errors.FileNoExistError)
+	// The context passed to the operation was canceled or its deadline exceeded.
+	ContextCanceled = ErrCode(errors.ContextCanceled)
+	// An operation on a file on the host failed, e.g. while pulling a file.
+	LocalFileError = ErrCode(errors.LocalFileError)
 )
 
 // HasErrCode returns true if err is an *errors.Err and err.Code == code.
@@ -0,0 +1,308 @@
+package adb
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+// EventLogTagsPath is the path of the file that describes the tags of the events log buffer.
+const EventLogTagsPath = "/system/etc/event-log-tags"
+
+// EventValueType is the type of a field of an event, as numbered in event-log-tags files.
+type EventValueType int
+
+const (
+	EventInt    EventValueType = 1
+	EventLong   EventValueType = 2
+	EventString EventValueType = 3
+	EventList   EventValueType = 4
+	EventFloat  EventValueType = 5
+)
+
+// EventLogTag describes the events logged with a tag.
+type EventLogTag struct {
+	Number int
+	Name   string
+	Fields []EventLogField
+}
+
+// EventLogField describes a field of the events of a tag.
+type EventLogField struct {
+	Name string
+	Type EventValueType
+
+	// Unit is the unit of the value, e.g. 3 for milliseconds, 0 if unspecified.
+	Unit int
+}
+
+// EventLogTags are the tags of the events log buffer, by number.
+type EventLogTags map[int]*EventLogTag
+
+var (
+	reEventLogTag   = regexp.MustCompile(`^(\d+)\s+(\S+)\s*(.*)$`)
+	reEventLogField = regexp.MustCompile(`\(([^|()]*)\|(\d+)(?:\|(\d+))?\)`)
+)
+
+/*
+ParseEventLogTags parses an event-log-tags file, which has a line per tag:
+	30015 am_proc_start (User|1|5),(PID|1|5),(UID|1|5),(Process Name|3),(Type|3),(Component|3)
+	2718 e
+The fields are described by their name, type and optional unit.
+*/
+func ParseEventLogTags(r io.Reader) (EventLogTags, error) {
+	tags := EventLogTags{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		matches := reEventLogTag.FindStringSubmatch(line)
+		if matches == nil {
+			return nil, errors.Errorf(errors.ParseError, "invalid event log tag: %s", line)
+		}
+		number, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return nil, errors.WrapErrorf(err, errors.ParseError, "invalid event log tag number: %s", line)
+		}
+
+		tag := &EventLogTag{Number: number, Name: matches[2]}
+		for _, field := range reEventLogField.FindAllStringSubmatch(matches[3], -1) {
+			typ, _ := strconv.Atoi(field[2])
+			unit, _ := strconv.Atoi(field[3])
+			tag.Fields = append(tag.Fields, EventLogField{Name: field[1], Type: EventValueType(typ), Unit: unit})
+		}
+		tags[number] = tag
+	}
+	if err := scanner.Err(); err != nil {
+		if _, ok := err.(*errors.Err); ok {
+			return nil, err
+		}
+		return nil, errors.WrapErrorf(err, errors.NetworkError, "error reading event log tags")
+	}
+	return tags, nil
+}
+
+// EventLogTags reads and parses the event-log-tags file of the device.
+func (c *Device) EventLogTags() (EventLogTags, error) {
+	return c.EventLogTagsContext(context.Background())
+}
+
+// EventLogTagsContext is like EventLogTags, but gives up as soon as ctx is done.
+func (c *Device) EventLogTagsContext(ctx context.Context) (EventLogTags, error) {
+	r, err := c.OpenReadContext(ctx, EventLogTagsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	tags, err := ParseEventLogTags(r)
+	return tags, wrapClientError(err, c, "EventLogTags")
+}
+
+// Event is an entry of the events log buffer.
+type Event struct {
+	// Entry is the log entry of the event, whose Payload is decoded in the other fields.
+	Entry *LogEntry
+
+	TagNumber int
+
+	// Tag is the name of the tag, empty if the tags don't have it.
+	Tag string
+
+	// Value is the value of the event, an int32, int64, float32, string, or a []interface{}
+	// of those for lists.
+	Value interface{}
+
+	// Fields are the values of the event named by its tag: the elements of Value if it's a
+	// list, else Value itself. Names are empty if the tags don't have them.
+	Fields []EventFieldValue
+}
+
+// EventFieldValue is a named value of an event.
+type EventFieldValue struct {
+	Name  string
+	Value interface{}
+}
+
+// Field returns the value of the field called name, or false if the event has no such field.
+func (e *Event) Field(name string) (interface{}, bool) {
+	for _, field := range e.Fields {
+		if field.Name == name {
+			return field.Value, true
+		}
+	}
+	return nil, false
+}
+
+// String formats the event like logcat, e.g. "am_proc_start: [0,1234,10042,com.example.app]".
+func (e *Event) String() string {
+	tag := e.Tag
+	if tag == "" {
+		tag = strconv.Itoa(e.TagNumber)
+	}
+	return tag + ": " + formatEventValue(e.Value)
+}
+
+func formatEventValue(value interface{}) string {
+	list, ok := value.([]interface{})
+	if !ok {
+		return fmt.Sprint(value)
+	}
+	values := make([]string, len(list))
+	for i, v := range list {
+		values[i] = formatEventValue(v)
+	}
+	return "[" + strings.Join(values, ",") + "]"
+}
+
+// Types of the values in the payloads of events.
+const (
+	eventPayloadInt    = 0
+	eventPayloadLong   = 1
+	eventPayloadString = 2
+	eventPayloadList   = 3
+	eventPayloadFloat  = 4
+)
+
+/*
+DecodeEvent decodes the payload of an entry of the events log buffer, which is the tag number
+followed by a value. Values start with their type, then
+	int: 4 bytes, long: 8 bytes, float: 4 bytes,
+	string: its length on 4 bytes then the bytes
+	list: its length on 1 byte then the values
+tags may be nil, in which case the event has no tag name nor field names.
+*/
+func DecodeEvent(entry *LogEntry, tags EventLogTags) (*Event, error) {
+	payload := entry.Payload
+	if len(payload) < 4 {
+		return nil, errors.Errorf(errors.ParseError, "truncated event: %d bytes", len(payload))
+	}
+	event := &Event{Entry: entry, TagNumber: int(binary.LittleEndian.Uint32(payload))}
+	// Some writers append a newline after the value, which logcat ignores too.
+	value, _, err := decodeEventValue(payload[4:])
+	if err != nil {
+		return nil, errors.WrapErrf(err, "invalid event %d", event.TagNumber)
+	}
+	event.Value = value
+
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
+	var tag *EventLogTag
+	if tags != nil {
+		tag = tags[event.TagNumber]
+	}
+	if tag != nil {
+		event.Tag = tag.Name
+	}
+	for i, v := range values {
+		field := EventFieldValue{Value: v}
+		if tag != nil && i < len(tag.Fields) {
+			field.Name = tag.Fields[i].Name
+		}
+		event.Fields = append(event.Fields, field)
+	}
+	return event, nil
+}
+
+func decodeEventValue(data []byte) (value interface{}, rest []byte, err error) {
+	truncated := errors.Errorf(errors.ParseError, "truncated value")
+	if len(data) < 1 {
+		return nil, nil, truncated
+	}
+	typ, data := data[0], data[1:]
+
+	switch typ {
+	case eventPayloadInt:
+		if len(data) < 4 {
+			return nil, nil, truncated
+		}
+		return int32(binary.LittleEndian.Uint32(data)), data[4:], nil
+	case eventPayloadLong:
+		if len(data) < 8 {
+			return nil, nil, truncated
+		}
+		return int64(binary.LittleEndian.Uint64(data)), data[8:], nil
+	case eventPayloadFloat:
+		if len(data) < 4 {
+			return nil, nil, truncated
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(data)), data[4:], nil
+	case eventPayloadString:
+		if len(data) < 4 {
+			return nil, nil, truncated
+		}
+		n := int(binary.LittleEndian.Uint32(data))
+		if n < 0 || len(data)-4 < n {
+			return nil, nil, truncated
+		}
+		return string(data[4 : 4+n]), data[4+n:], nil
+	case eventPayloadList:
+		if len(data) < 1 {
+			return nil, nil, truncated
+		}
+		n, data := int(data[0]), data[1:]
+		list := make([]interface{}, n)
+		for i := range list {
+			if list[i], data, err = decodeEventValue(data); err != nil {
+				return nil, nil, err
+			}
+		}
+		return list, data, nil
+	}
+	return nil, nil, errors.Errorf(errors.ParseError, "unknown value type %d", typ)
+}
+
+/*
+EventReader decodes the events of the binary output of logcat -B -b events, e.g.
+	conn, err := device.OpenCommand("logcat", "-B", "-b", "events")
+	tags, err := device.EventLogTags()
+	events := adb.NewEventReader(conn, tags)
+	for {
+		event, err := events.Next()
+		...
+		if event.Tag == "am_proc_start" {
+			pid, _ := event.Field("PID")
+		}
+	}
+*/
+type EventReader struct {
+	logs *LogReader
+	tags EventLogTags
+}
+
+// NewEventReader returns an EventReader that decodes r with tags, which may be nil. If r is an
+// io.Closer, it's closed by Close.
+func NewEventReader(r io.Reader, tags EventLogTags) *EventReader {
+	logs := NewLogReader(r)
+	logs.buffer = LogBufferEvents
+	return &EventReader{logs: logs, tags: tags}
+}
+
+// Next returns the next event, or io.EOF once logcat exits. If an event can't be decoded,
+// returns a ParseError, and the next call returns the following event.
+func (r *EventReader) Next() (*Event, error) {
+	entry, err := r.logs.Next()
+	if err != nil {
+		return nil, err
+	}
+	if entry.Buffer != LogBufferEvents {
+		return nil, errors.Errorf(errors.ParseError, "entry of the %s buffer isn't an event", entry.Buffer)
+	}
+	return DecodeEvent(entry, r.tags)
+}
+
+// Close stops reading, after which Next returns an error.
+func (r *EventReader) Close() error {
+	return r.logs.Close()
+}
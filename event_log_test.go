@@ -0,0 +1,161 @@
+package adb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testEventLogTags = `# The entries in this file map a sparse set of log tag numbers to tag names.
+42 answer (to life the universe etc|3)
+2718 e
+30015 am_proc_start (User|1|5),(PID|1|5),(UID|1|5),(Process Name|3),(Type|3),(Component|3)
+1397638484 snet_event_log (subtag|3) (uid|1) (message|3|50)
+`
+
+// eventPayload encodes an event payload with values encoded by eventInt, eventString, etc.
+func eventPayload(tag uint32, value []byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, tag)
+	buf.Write(value)
+	return buf.Bytes()
+}
+
+func eventInt(v int32) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(eventPayloadInt)
+	binary.Write(&buf, binary.LittleEndian, v)
+	return buf.Bytes()
+}
+
+func eventLong(v int64) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(eventPayloadLong)
+	binary.Write(&buf, binary.LittleEndian, v)
+	return buf.Bytes()
+}
+
+func eventFloat(v float32) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(eventPayloadFloat)
+	binary.Write(&buf, binary.LittleEndian, math.Float32bits(v))
+	return buf.Bytes()
+}
+
+func eventString(s string) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(eventPayloadString)
+	binary.Write(&buf, binary.LittleEndian, uint32(len(s)))
+	buf.WriteString(s)
+	return buf.Bytes()
+}
+
+func eventList(values ...[]byte) []byte {
+	data := []byte{eventPayloadList, byte(len(values))}
+	for _, v := range values {
+		data = append(data, v...)
+	}
+	return data
+}
+
+func TestParseEventLogTags(t *testing.T) {
+	tags, err := ParseEventLogTags(strings.NewReader(testEventLogTags))
+	require.NoError(t, err)
+	assert.Len(t, tags, 4)
+	assert.Equal(t, &EventLogTag{Number: 2718, Name: "e"}, tags[2718])
+	assert.Equal(t, &EventLogTag{
+		Number: 42,
+		Name:   "answer",
+		Fields: []EventLogField{{Name: "to life the universe etc", Type: EventString}},
+	}, tags[42])
+	assert.Equal(t, EventLogField{Name: "PID", Type: EventInt, Unit: 5}, tags[30015].Fields[1])
+	assert.Len(t, tags[30015].Fields, 6)
+	assert.Equal(t, EventLogField{Name: "message", Type: EventString, Unit: 50}, tags[1397638484].Fields[2])
+
+	_, err = ParseEventLogTags(strings.NewReader("answer 42\n"))
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+}
+
+func TestDecodeEvent(t *testing.T) {
+	tags, err := ParseEventLogTags(strings.NewReader(testEventLogTags))
+	require.NoError(t, err)
+
+	entry := &LogEntry{Payload: eventPayload(30015, eventList(
+		eventInt(0), eventInt(1234), eventInt(10042),
+		eventString("com.example.app"), eventString("activity"), eventString("com.example.app/.Main"),
+	))}
+	event, err := DecodeEvent(entry, tags)
+	require.NoError(t, err)
+	assert.Equal(t, "am_proc_start", event.Tag)
+	assert.Equal(t, 30015, event.TagNumber)
+	assert.Equal(t, entry, event.Entry)
+	pid, ok := event.Field("PID")
+	assert.True(t, ok)
+	assert.Equal(t, int32(1234), pid)
+	name, _ := event.Field("Process Name")
+	assert.Equal(t, "com.example.app", name)
+	_, ok = event.Field("Missing")
+	assert.False(t, ok)
+	assert.Equal(t, "am_proc_start: [0,1234,10042,com.example.app,activity,com.example.app/.Main]", event.String())
+
+	event, err = DecodeEvent(&LogEntry{Payload: eventPayload(42, eventString("forty-two"))}, tags)
+	require.NoError(t, err)
+	assert.Equal(t, []EventFieldValue{{Name: "to life the universe etc", Value: "forty-two"}}, event.Fields)
+
+	// Unknown tags and nested lists.
+	event, err = DecodeEvent(&LogEntry{Payload: eventPayload(7, eventList(eventLong(-1), eventFloat(1.5), eventList(eventInt(2))))}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(-1), float32(1.5), []interface{}{int32(2)}}, event.Value)
+	assert.Equal(t, "", event.Fields[0].Name)
+	assert.Equal(t, "7: [-1,1.5,[2]]", event.String())
+
+	for _, payload := range [][]byte{
+		{1, 0},
+		eventPayload(42, eventString("truncated")[:6]),
+		eventPayload(42, eventList(eventInt(1))[:3]),
+		eventPayload(42, []byte{9}),
+	} {
+		_, err := DecodeEvent(&LogEntry{Payload: payload}, tags)
+		assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+	}
+}
+
+func TestEventReader(t *testing.T) {
+	tags, err := ParseEventLogTags(strings.NewReader(testEventLogTags))
+	require.NoError(t, err)
+	t1 := time.Unix(1600000000, 0)
+
+	var data []byte
+	// v1 entries don't have a buffer id.
+	data = append(data, encodeLogEntry(20, 1, 1, t1, eventPayload(42, eventString("a")))...)
+	data = append(data, encodeLogEntry(28, 2, 2, t1, eventPayload(42, []byte{9}), uint32(LogBufferEvents), 1000)...)
+	data = append(data, encodeLogEntry(28, 3, 3, t1, eventPayload(2718, eventInt(3)), uint32(LogBufferEvents), 1000)...)
+	data = append(data, encodeLogEntry(28, 4, 4, t1, textLogPayload(LogInfo, "tag", "text"), uint32(LogBufferMain), 1000)...)
+
+	r := NewEventReader(bytes.NewReader(data), tags)
+	event, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "answer: a", event.String())
+	assert.Equal(t, 1, event.Entry.PID)
+
+	// Invalid events are skipped.
+	_, err = r.Next()
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+	event, err = r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "e", event.Tag)
+
+	_, err = r.Next()
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+	assert.NoError(t, r.Close())
+}
@@ -0,0 +1,410 @@
+package adb
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kvnxiao/go-adb/wire"
+)
+
+/*
+fakeDevice is an adb server with a single device whose files are kept in memory, so transfers
+can be tested end to end. It answers the v1 sync requests (STAT, LIST, RECV and SEND), and the
+few shell commands that Push, Pull, Sync and DumpUI run, with exec:.
+*/
+type fakeDevice struct {
+	mu    sync.Mutex
+	files map[string]*fakeFile
+
+	// uiDump is the UI hierarchy written by uiautomator dump.
+	uiDump string
+
+	// sendFailure, if set, is the error SEND requests fail with once the file is sent, like a
+	// device whose disk is full.
+	sendFailure string
+
+	// Commands are the command lines run with exec:, in order.
+	Commands []string
+}
+
+type fakeFile struct {
+	// mode has os.ModeDir set for directories.
+	mode  os.FileMode
+	mtime time.Time
+	data  []byte
+}
+
+var _ server = &fakeDevice{}
+
+func newFakeDevice() *fakeDevice {
+	return &fakeDevice{files: map[string]*fakeFile{"/": {mode: os.ModeDir | 0755}}}
+}
+
+// addFile adds a file, or a directory if mode has os.ModeDir set, creating its parents.
+func (d *fakeDevice) addFile(name string, mode os.FileMode, mtime time.Time, data string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.mkdirAll(path.Dir(name), mtime)
+	d.files[name] = &fakeFile{mode: mode, mtime: mtime.UTC(), data: []byte(data)}
+}
+
+// file returns the file at name, or nil if there's none.
+func (d *fakeDevice) file(name string) *fakeFile {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.files[name]
+}
+
+func (d *fakeDevice) mkdirAll(name string, mtime time.Time) {
+	for ; name != "/" && d.files[name] == nil; name = path.Dir(name) {
+		d.files[name] = &fakeFile{mode: os.ModeDir | 0755, mtime: mtime.UTC()}
+	}
+}
+
+// children returns the names of the children of the directory at name, sorted.
+func (d *fakeDevice) children(name string) []string {
+	var names []string
+	for child := range d.files {
+		if child != "/" && path.Dir(child) == name {
+			names = append(names, path.Base(child))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (d *fakeDevice) Start() error {
+	return nil
+}
+
+func (d *fakeDevice) Dial() (*wire.Conn, error) {
+	client, server := net.Pipe()
+	go d.serve(server)
+	conn := wire.MultiCloseable(client)
+	return wire.NewConn(wire.NewScanner(conn), wire.NewSender(conn)), nil
+}
+
+func (d *fakeDevice) DialContext(ctx context.Context) (*wire.Conn, error) {
+	conn, err := d.Dial()
+	if err == nil {
+		conn.WatchContext(ctx)
+	}
+	return conn, err
+}
+
+func (d *fakeDevice) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var length [4]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return
+		}
+		n, _ := strconv.ParseUint(string(length[:]), 16, 16)
+		req := make([]byte, n)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+
+		switch service := string(req); {
+		case strings.HasPrefix(service, "host:transport"):
+			io.WriteString(conn, "OKAY")
+		case strings.HasSuffix(service, ":features"):
+			io.WriteString(conn, "OKAY0000")
+			return
+		case service == "sync:":
+			io.WriteString(conn, "OKAY")
+			d.serveSync(conn)
+			return
+		case strings.HasPrefix(service, "exec:"):
+			io.WriteString(conn, "OKAY")
+			io.WriteString(conn, d.exec(strings.TrimPrefix(service, "exec:")))
+			return
+		default:
+			fmt.Fprintf(conn, "FAIL%04x%s", len("unknown service"), "unknown service")
+			return
+		}
+	}
+}
+
+func (d *fakeDevice) serveSync(conn net.Conn) {
+	for {
+		id, name, err := readFakeSyncRequest(conn)
+		if err != nil {
+			return
+		}
+		switch id {
+		case "STAT":
+			d.mu.Lock()
+			file := d.files[name]
+			d.mu.Unlock()
+			io.WriteString(conn, "STAT")
+			if file == nil {
+				writeFakeSync(conn, uint32(0), uint32(0), uint32(0))
+			} else {
+				writeFakeSync(conn, file.rawMode(), uint32(len(file.data)), uint32(file.mtime.Unix()))
+			}
+		case "LIST":
+			d.mu.Lock()
+			var entries []*fakeFile
+			names := d.children(name)
+			for _, child := range names {
+				entries = append(entries, d.files[path.Join(name, child)])
+			}
+			d.mu.Unlock()
+			for i, file := range entries {
+				io.WriteString(conn, "DENT")
+				writeFakeSync(conn, file.rawMode(), uint32(len(file.data)), uint32(file.mtime.Unix()),
+					uint32(len(names[i])))
+				io.WriteString(conn, names[i])
+			}
+			io.WriteString(conn, "DONE")
+			writeFakeSync(conn, uint32(0), uint32(0), uint32(0), uint32(0))
+		case "RECV":
+			d.mu.Lock()
+			file := d.files[name]
+			d.mu.Unlock()
+			if file == nil || file.mode.IsDir() {
+				io.WriteString(conn, "FAIL")
+				writeFakeSync(conn, uint32(len("No such file or directory")))
+				io.WriteString(conn, "No such file or directory")
+				return
+			}
+			for data := file.data; len(data) > 0; {
+				chunk := data
+				if len(chunk) > wire.SyncMaxChunkSize {
+					chunk = chunk[:wire.SyncMaxChunkSize]
+				}
+				io.WriteString(conn, "DATA")
+				writeFakeSync(conn, uint32(len(chunk)))
+				conn.Write(chunk)
+				data = data[len(chunk):]
+			}
+			io.WriteString(conn, "DONE")
+			writeFakeSync(conn, uint32(0))
+		case "SEND":
+			// Hold the lock until the file is stored, so requests made after the file is closed
+			// see it.
+			d.mu.Lock()
+			err := d.receive(conn, name)
+			d.mu.Unlock()
+			if err != nil {
+				return
+			}
+			if d.sendFailure != "" {
+				io.WriteString(conn, "FAIL")
+				writeFakeSync(conn, uint32(len(d.sendFailure)))
+				io.WriteString(conn, d.sendFailure)
+			} else {
+				io.WriteString(conn, "OKAY")
+				writeFakeSync(conn, uint32(0))
+			}
+		default:
+			return
+		}
+	}
+}
+
+// receive reads the chunks of a file sent to "path,mode" and stores it.
+func (d *fakeDevice) receive(conn net.Conn, pathAndMode string) error {
+	i := strings.LastIndexByte(pathAndMode, ',')
+	name := pathAndMode[:i]
+	mode, _ := strconv.ParseUint(pathAndMode[i+1:], 10, 32)
+	var data []byte
+	for {
+		id, chunk, err := readFakeSyncRequest(conn)
+		if err != nil {
+			return err
+		}
+		if id == "DONE" {
+			// The length of the DONE chunk is the modification time.
+			mtime := int64(binary.LittleEndian.Uint32([]byte(chunk)))
+			if d.sendFailure != "" {
+				return nil
+			}
+			d.mkdirAll(path.Dir(name), time.Now())
+			d.files[name] = &fakeFile{mode: os.FileMode(mode).Perm(), mtime: time.Unix(mtime, 0).UTC(), data: data}
+			return nil
+		}
+		data = append(data, chunk...)
+	}
+}
+
+// readFakeSyncRequest reads an id and its data. The data of DONE is its 4-byte length field.
+func readFakeSyncRequest(r io.Reader) (string, string, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return "", "", err
+	}
+	if string(header[:4]) == "DONE" {
+		return "DONE", string(header[4:]), nil
+	}
+	data := make([]byte, binary.LittleEndian.Uint32(header[4:]))
+	_, err := io.ReadFull(r, data)
+	return string(header[:4]), string(data), err
+}
+
+func writeFakeSync(w io.Writer, values ...uint32) {
+	for _, v := range values {
+		binary.Write(w, binary.LittleEndian, v)
+	}
+}
+
+// rawMode returns the mode of the file as a Linux st_mode.
+func (f *fakeFile) rawMode() uint32 {
+	if f.mode.IsDir() {
+		return wire.ModeDir | uint32(f.mode.Perm())
+	}
+	return 0100000 | uint32(f.mode.Perm())
+}
+
+/*
+exec runs a command line, which is split into commands at unquoted semicolons, and returns its
+output. The commands are:
+	stat -L -c '%f %s %Y' PATH
+	mkdir -p PATH...
+	chmod MODE PATH
+	touch -m -d @SECONDS PATH
+	md5sum PATH...
+	uiautomator dump PATH
+	rm -f PATH...
+	echo ARG...
+*/
+func (d *fakeDevice) exec(cmdLine string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.Commands = append(d.Commands, cmdLine)
+
+	var output string
+	status := 0
+	for _, args := range splitFakeCommandLine(cmdLine) {
+		var out string
+		out, status = d.run(args, status)
+		output += out
+	}
+	return output
+}
+
+func (d *fakeDevice) run(args []string, lastStatus int) (string, int) {
+	noFile := func(name string) (string, int) {
+		return fmt.Sprintf("%s: '%s': No such file or directory\n", args[0], name), 1
+	}
+	switch {
+	case len(args) == 0:
+		return "", lastStatus
+	case args[0] == "echo":
+		return strings.ReplaceAll(strings.Join(args[1:], " "), "$?", strconv.Itoa(lastStatus)) + "\n", 0
+	case len(args) == 5 && args[0] == "stat" && args[1] == "-L" && args[2] == "-c" && args[3] == "%f %s %Y":
+		file := d.files[args[4]]
+		if file == nil {
+			return noFile(args[4])
+		}
+		return fmt.Sprintf("%x %d %d\n", file.rawMode(), len(file.data), file.mtime.Unix()), 0
+	case len(args) >= 3 && args[0] == "mkdir" && args[1] == "-p":
+		for _, name := range args[2:] {
+			d.mkdirAll(name, time.Now())
+		}
+		return "", 0
+	case len(args) == 3 && args[0] == "chmod":
+		file := d.files[args[2]]
+		if file == nil {
+			return noFile(args[2])
+		}
+		mode, err := strconv.ParseUint(args[1], 8, 32)
+		if err != nil {
+			return "chmod: bad mode\n", 1
+		}
+		file.mode = file.mode&os.ModeType | os.FileMode(mode)
+		return "", 0
+	case len(args) == 5 && args[0] == "touch" && args[1] == "-m" && args[2] == "-d" && strings.HasPrefix(args[3], "@"):
+		file := d.files[args[4]]
+		if file == nil {
+			return noFile(args[4])
+		}
+		seconds, err := strconv.ParseInt(args[3][1:], 10, 64)
+		if err != nil {
+			return "touch: bad date\n", 1
+		}
+		file.mtime = time.Unix(seconds, 0).UTC()
+		return "", 0
+	case args[0] == "md5sum":
+		var output string
+		status := 0
+		for _, name := range args[1:] {
+			file := d.files[name]
+			if file == nil || file.mode.IsDir() {
+				out, _ := noFile(name)
+				output, status = output+out, 1
+				continue
+			}
+			output += fmt.Sprintf("%x  %s\n", md5.Sum(file.data), name)
+		}
+		return output, status
+	case len(args) == 3 && args[0] == "uiautomator" && args[1] == "dump":
+		d.mkdirAll(path.Dir(args[2]), time.Now())
+		d.files[args[2]] = &fakeFile{mode: 0644, mtime: time.Now().UTC(), data: []byte(d.uiDump)}
+		return "UI hierchary dumped to: " + args[2] + "\n", 0
+	case len(args) >= 2 && args[0] == "rm" && args[1] == "-f":
+		for _, name := range args[2:] {
+			delete(d.files, name)
+		}
+		return "", 0
+	default:
+		return fmt.Sprintf("/system/bin/sh: %s: inaccessible or not found\n", args[0]), 127
+	}
+}
+
+// splitFakeCommandLine splits a command line into commands and their arguments, like sh would
+// for the subset of its syntax used by this package: spaces, semicolons, and quotes.
+func splitFakeCommandLine(cmdLine string) [][]string {
+	var commands [][]string
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	endArg := func() {
+		if inArg {
+			args = append(args, arg.String())
+			arg.Reset()
+			inArg = false
+		}
+	}
+	for i := 0; i < len(cmdLine); i++ {
+		switch c := cmdLine[i]; c {
+		case ' ', '\t':
+			endArg()
+		case ';':
+			endArg()
+			commands = append(commands, args)
+			args = nil
+		case '\'', '"':
+			end := strings.IndexByte(cmdLine[i+1:], c)
+			if end < 0 {
+				end = len(cmdLine) - i - 1
+			}
+			arg.WriteString(cmdLine[i+1 : i+1+end])
+			inArg = true
+			i += end + 1
+		case '\\':
+			if i+1 < len(cmdLine) {
+				i++
+				arg.WriteByte(cmdLine[i])
+			}
+			inArg = true
+		default:
+			arg.WriteByte(c)
+			inArg = true
+		}
+	}
+	endArg()
+	return append(commands, args)
+}
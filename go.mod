@@ -1,17 +1,20 @@
 module github.com/kvnxiao/go-adb
 
-go 1.13
+go 1.16
 
 require (
 	github.com/alecthomas/kingpin v2.2.6+incompatible
 	github.com/alecthomas/template v0.0.0-20190718012654-fb15b899a751 // indirect
 	github.com/alecthomas/units v0.0.0-20190924025748-f65c72e2690d // indirect
+	github.com/andybalholm/brotli v1.0.4
 	github.com/cheggaaa/pb/v3 v3.0.3
 	github.com/franela/goblin v0.0.0-20181003173013-ead4ad1d2727 // indirect
 	github.com/franela/goreq v0.0.0-20171204163338-bcd34c9993f8
+	github.com/klauspost/compress v1.11.13
 	github.com/mattn/go-colorable v0.1.4 // indirect
 	github.com/mattn/go-isatty v0.0.11 // indirect
 	github.com/onsi/gomega v1.7.1 // indirect
+	github.com/pierrec/lz4/v4 v4.1.22
 	github.com/stretchr/testify v1.4.0
 	golang.org/x/sys v0.0.0-20191210023423-ac6580df4449
 )
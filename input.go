@@ -0,0 +1,200 @@
+package adb
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+// InputSource is the kind of device input events are injected from.
+type InputSource string
+
+const (
+	// InputSourceDefault lets input choose the source of each action, e.g. the touchscreen
+	// for taps and the keyboard for text.
+	InputSourceDefault     InputSource = ""
+	InputSourceTouchscreen InputSource = "touchscreen"
+	InputSourceKeyboard    InputSource = "keyboard"
+	InputSourceDpad        InputSource = "dpad"
+	InputSourceMouse       InputSource = "mouse"
+	InputSourceTouchpad    InputSource = "touchpad"
+	InputSourceStylus      InputSource = "stylus"
+	InputSourceGamepad     InputSource = "gamepad"
+	InputSourceTrackball   InputSource = "trackball"
+	InputSourceJoystick    InputSource = "joystick"
+)
+
+// InputOptions configures Device.InputWithOptions.
+type InputOptions struct {
+	Source InputSource
+
+	// Display is the id of the logical display to inject the events to, 0 for the default
+	// display. Other displays require Android 10 or later.
+	Display int
+}
+
+// defaultLongPressDuration is the duration of LongPress when it isn't specified, which is more
+// than the long press timeout of Android.
+const defaultLongPressDuration = time.Second
+
+// InputAction is an action injected by Device.Input, e.g. Tap(100, 200).
+type InputAction struct {
+	// args are the arguments of input, or nil to wait.
+	args []string
+	wait time.Duration
+	err  error
+}
+
+// Tap taps the screen at x, y, in pixels.
+func Tap(x, y int) InputAction {
+	return InputAction{args: []string{"tap", strconv.Itoa(x), strconv.Itoa(y)}}
+}
+
+// Swipe swipes from x1, y1 to x2, y2, taking duration, or input's default of 300ms if 0.
+func Swipe(x1, y1, x2, y2 int, duration time.Duration) InputAction {
+	return motionAction("swipe", x1, y1, x2, y2, duration)
+}
+
+// LongPress touches the screen at x, y for duration, or for a second if 0.
+func LongPress(x, y int, duration time.Duration) InputAction {
+	if duration == 0 {
+		duration = defaultLongPressDuration
+	}
+	return motionAction("swipe", x, y, x, y, duration)
+}
+
+// DragAndDrop long presses x1, y1, then drags to x2, y2, taking duration, or input's default if
+// 0. Requires Android 11 or later.
+func DragAndDrop(x1, y1, x2, y2 int, duration time.Duration) InputAction {
+	return motionAction("draganddrop", x1, y1, x2, y2, duration)
+}
+
+func motionAction(command string, x1, y1, x2, y2 int, duration time.Duration) InputAction {
+	action := InputAction{args: []string{command,
+		strconv.Itoa(x1), strconv.Itoa(y1), strconv.Itoa(x2), strconv.Itoa(y2)}}
+	if duration < 0 {
+		action.err = errors.AssertionErrorf("invalid %s duration: %s", command, duration)
+	} else if duration > 0 {
+		action.args = append(action.args, strconv.FormatInt(duration.Milliseconds(), 10))
+	}
+	return action
+}
+
+/*
+Text types text, which can contain any character that can be typed on the virtual keyboard of
+the device. Characters that aren't on it, like most non-ASCII ones, can't be typed. Because of
+how input works, "%s" is typed as a space.
+*/
+func Text(text string) InputAction {
+	action := InputAction{args: []string{"text", quoteShellArg(text)}}
+	if text == "" {
+		action.err = errors.AssertionErrorf("text cannot be empty")
+	}
+	return action
+}
+
+// KeyEvent presses and releases each of keys in turn.
+func KeyEvent(keys ...Keycode) InputAction {
+	return keyAction(nil, keys)
+}
+
+// KeyLongPress presses and releases each of keys in turn, holding them long enough to be long
+// presses.
+func KeyLongPress(keys ...Keycode) InputAction {
+	return keyAction([]string{"--longpress"}, keys)
+}
+
+func keyAction(flags []string, keys []Keycode) InputAction {
+	action := InputAction{args: append([]string{"keyevent"}, flags...)}
+	for _, key := range keys {
+		action.args = append(action.args, strconv.Itoa(int(key)))
+	}
+	if len(keys) == 0 {
+		action.err = errors.AssertionErrorf("no key to press")
+	}
+	return action
+}
+
+// Wait waits for duration before the next action, e.g. for an animation to finish.
+func Wait(duration time.Duration) InputAction {
+	action := InputAction{wait: duration}
+	if duration <= 0 {
+		action.err = errors.AssertionErrorf("invalid wait duration: %s", duration)
+	}
+	return action
+}
+
+// commandLine returns the shell command that performs the action.
+func (a InputAction) commandLine(opts InputOptions) string {
+	if a.args == nil {
+		return "sleep " + strconv.FormatFloat(a.wait.Seconds(), 'f', -1, 64)
+	}
+	args := []string{"input"}
+	if opts.Source != InputSourceDefault {
+		args = append(args, string(opts.Source))
+	}
+	if opts.Display != 0 {
+		args = append(args, "-d", strconv.Itoa(opts.Display))
+	}
+	return strings.Join(append(args, a.args...), " ")
+}
+
+/*
+Input injects actions, one after the other, as if they were done by the user:
+	err := device.Input(adb.Tap(540, 1200), adb.Text("it's done"), adb.KeyEvent(adb.KeycodeEnter))
+All the actions are run by a single shell command, which is much faster than running input for
+each of them. The actions after one that fails aren't run.
+*/
+func (c *Device) Input(actions ...InputAction) error {
+	return c.InputContext(context.Background(), actions...)
+}
+
+// InputContext is like Input, but gives up as soon as ctx is done. The actions may keep being
+// injected on the device.
+func (c *Device) InputContext(ctx context.Context, actions ...InputAction) error {
+	return c.InputWithOptions(ctx, InputOptions{}, actions...)
+}
+
+// InputWithOptions is like InputContext, but allows choosing the source of the events and the
+// display they're injected to.
+func (c *Device) InputWithOptions(ctx context.Context, opts InputOptions, actions ...InputAction) error {
+	cmd, err := inputCommandLine(opts, actions)
+	if err != nil {
+		return wrapClientError(err, c, "Input")
+	}
+	result, err := c.RunShellCommandContext(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	// input doesn't always exit with an error status when it fails, e.g. on SecurityExceptions.
+	output := strings.TrimSpace(string(result.Stdout) + string(result.Stderr))
+	if result.ExitCode != 0 || strings.HasPrefix(output, "Error") || strings.Contains(output, "Exception") {
+		err = errors.Errorf(errors.AdbError, "input failed with status %d: %s", result.ExitCode, output)
+		return wrapClientError(err, c, "Input")
+	}
+	return nil
+}
+
+func inputCommandLine(opts InputOptions, actions []InputAction) (string, error) {
+	if len(actions) == 0 {
+		return "", errors.AssertionErrorf("no input action")
+	}
+	if opts.Display < 0 || strings.Trim(string(opts.Source), "abcdefghijklmnopqrstuvwxyz") != "" {
+		return "", errors.AssertionErrorf("invalid input options: %+v", opts)
+	}
+	commands := make([]string, len(actions))
+	for i, action := range actions {
+		if action.err != nil {
+			return "", action.err
+		}
+		if action.args == nil && action.wait <= 0 {
+			return "", errors.AssertionErrorf("invalid input action at index %d", i)
+		}
+		commands[i] = action.commandLine(opts)
+	}
+	return strings.Join(commands, " && "), nil
+}
@@ -0,0 +1,74 @@
+package adb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/kvnxiao/go-adb/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInputCommandLine(t *testing.T) {
+	for _, test := range []struct {
+		opts     InputOptions
+		actions  []InputAction
+		expected string
+	}{
+		{InputOptions{}, []InputAction{Tap(10, 20)}, "input tap 10 20"},
+		{
+			InputOptions{Source: InputSourceTouchscreen, Display: 2},
+			[]InputAction{Swipe(1, 2, 3, 4, 250*time.Millisecond), Wait(1500 * time.Millisecond), LongPress(5, 6, 0)},
+			"input touchscreen -d 2 swipe 1 2 3 4 250 && sleep 1.5 && input touchscreen -d 2 swipe 5 6 5 6 1000",
+		},
+		{
+			InputOptions{Source: InputSourceKeyboard},
+			[]InputAction{Text(`it's "quoted" & $HOME`), KeyEvent(KeycodeEnter, KeycodeTab), KeyLongPress(KeycodePower)},
+			`input keyboard text 'it'\''s "quoted" & $HOME' && input keyboard keyevent 66 61 && ` +
+				"input keyboard keyevent --longpress 26",
+		},
+		{InputOptions{}, []InputAction{DragAndDrop(1, 2, 3, 4, 0)}, "input draganddrop 1 2 3 4"},
+	} {
+		cmd, err := inputCommandLine(test.opts, test.actions)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, cmd)
+	}
+
+	for _, actions := range [][]InputAction{
+		nil,
+		{Text("")},
+		{KeyEvent()},
+		{Swipe(1, 2, 3, 4, -time.Second)},
+		{Tap(1, 2), Wait(0)},
+		{{}},
+	} {
+		_, err := inputCommandLine(InputOptions{}, actions)
+		assert.Equal(t, errors.AssertionError, err.(*errors.Err).Code)
+	}
+	_, err := inputCommandLine(InputOptions{Source: "dpad; reboot"}, []InputAction{Tap(1, 2)})
+	assert.Equal(t, errors.AssertionError, err.(*errors.Err).Code)
+}
+
+func TestInput(t *testing.T) {
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"cmd", ":0\n"},
+	}
+	client := (&Adb{s}).Device(DeviceWithSerial("abc"))
+	err := client.InputWithOptions(context.Background(), InputOptions{Source: InputSourceDpad},
+		KeyEvent(KeycodeDpadDown), Text("hello world"))
+	assert.NoError(t, err)
+	assert.Equal(t, "exec:input dpad keyevent 20 && input dpad text 'hello world' ; echo :$?", s.Requests[2])
+}
+
+func TestInputError(t *testing.T) {
+	s := &MockServer{
+		Status: wire.StatusSuccess,
+		Messages: []string{"cmd", "java.lang.SecurityException: Injecting input events requires the caller " +
+			"(or the source of the instrumentation, if any) to have the INJECT_EVENTS permission.\n:0\n"},
+	}
+	err := (&Adb{s}).Device(DeviceWithSerial("abc")).Input(Tap(1, 2))
+	assert.True(t, HasErrCode(err, AdbError))
+	assert.Contains(t, ErrorWithCauseChain(err), "INJECT_EVENTS")
+}
@@ -0,0 +1,163 @@
+package adb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+// InstallOptions configures Device.Install.
+type InstallOptions struct {
+	// Replace an existing installation of the app, keeping its data (-r).
+	Replace bool
+
+	// AllowDowngrade allows installing an older version than the installed one (-d).
+	AllowDowngrade bool
+
+	// GrantPermissions grants all the runtime permissions requested by the app (-g).
+	GrantPermissions bool
+
+	// AllowTest allows installing apps marked as test-only (-t).
+	AllowTest bool
+
+	// User installs the app for a specific user, e.g. "0", "current" or "all".
+	// The default depends on the device, usually all users.
+	User string
+
+	// Progress, if not nil, is called as each APK is streamed to the device, and once after
+	// it's done.
+	Progress func(InstallProgress)
+}
+
+// InstallProgress reports the progress of streaming an APK to the package manager.
+type InstallProgress struct {
+	// Name of the split being streamed, empty for Install.
+	Name string
+
+	// Written bytes out of Size.
+	Written, Size int64
+
+	Done bool
+}
+
+// args returns the options as arguments of the package manager's install commands.
+func (o InstallOptions) args() []string {
+	var args []string
+	if o.Replace {
+		args = append(args, "-r")
+	}
+	if o.AllowDowngrade {
+		args = append(args, "-d")
+	}
+	if o.GrantPermissions {
+		args = append(args, "-g")
+	}
+	if o.AllowTest {
+		args = append(args, "-t")
+	}
+	if o.User != "" {
+		args = append(args, "--user", o.User)
+	}
+	return args
+}
+
+/*
+InstallError is the failure reported by the package manager, e.g.
+	Failure [INSTALL_FAILED_VERSION_DOWNGRADE: Downgrade detected]
+
+Errors returned by Device.Install wrap it, use errors.As to get it. Device.Uninstall reports
+failures the same way, with codes like DELETE_FAILED_INTERNAL_ERROR.
+*/
+type InstallError struct {
+	// Code is the INSTALL_FAILED_, INSTALL_PARSE_FAILED_ or DELETE_FAILED_ constant, e.g.
+	// INSTALL_FAILED_VERSION_DOWNGRADE.
+	Code string
+
+	// Message is the optional explanation that follows the code.
+	Message string
+}
+
+func (e *InstallError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("Failure [%s]", e.Code)
+	}
+	return fmt.Sprintf("Failure [%s: %s]", e.Code, e.Message)
+}
+
+/*
+Install installs the APK read from r, which must contain exactly size bytes.
+
+The APK is streamed to the package manager with install -S, so it doesn't need to be pushed
+to the device first. If the device supports FeatureAbbExec it's sent to the package service
+directly, otherwise through cmd, or pm on devices that don't have it.
+
+If the package manager reports a failure, the returned error wraps an *InstallError.
+*/
+func (c *Device) Install(r io.Reader, size int64, opts InstallOptions) error {
+	return c.InstallContext(context.Background(), r, size, opts)
+}
+
+// InstallContext is like Install, but gives up as soon as ctx is done. The package manager
+// fails the installation once the connection is closed.
+func (c *Device) InstallContext(ctx context.Context, r io.Reader, size int64, opts InstallOptions) error {
+	args := append([]string{"install", "-S", strconv.FormatInt(size, 10)}, opts.args()...)
+	output, err := c.runPackageCommand(ctx, newInstallProgressReader(r, "", size, opts.Progress), size, args...)
+	if err == nil {
+		err = parseInstallOutput(output)
+	}
+	return wrapClientError(err, c, "Install")
+}
+
+// runPackageCommand runs the package manager with args, streaming size bytes read from r to
+// its stdin if r isn't nil, and returns its output.
+func (c *Device) runPackageCommand(ctx context.Context, r io.Reader, size int64, args ...string) (string, error) {
+	return c.runServiceCommand(ctx, "package", r, size, args...)
+}
+
+// installProgressReader reports the bytes read from it to a progress callback.
+type installProgressReader struct {
+	r        io.Reader
+	progress InstallProgress
+	report   func(InstallProgress)
+}
+
+// newInstallProgressReader returns r itself if report is nil.
+func newInstallProgressReader(r io.Reader, name string, size int64, report func(InstallProgress)) io.Reader {
+	if report == nil {
+		return r
+	}
+	return &installProgressReader{r: r, progress: InstallProgress{Name: name, Size: size}, report: report}
+}
+
+func (r *installProgressReader) Read(buf []byte) (int, error) {
+	n, err := r.r.Read(buf)
+	r.progress.Written += int64(n)
+	r.progress.Done = r.progress.Written >= r.progress.Size
+	if n > 0 {
+		r.report(r.progress)
+	}
+	return n, err
+}
+
+var reInstallFailure = regexp.MustCompile(`Failure \[([A-Z0-9_]+)(?::\s*(.*))?\]`)
+
+// parseInstallOutput returns nil if output reports a successful installation, else an error
+// that wraps an *InstallError if the failure could be parsed.
+func parseInstallOutput(output string) error {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "Success") {
+			return nil
+		}
+	}
+
+	if matches := reInstallFailure.FindStringSubmatch(output); matches != nil {
+		installErr := &InstallError{Code: matches[1], Message: matches[2]}
+		return errors.WrapErrorf(installErr, errors.AdbError, "%s", installErr)
+	}
+	return errors.Errorf(errors.AdbError, "install failed: %s", strings.TrimSpace(output))
+}
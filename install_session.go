@@ -0,0 +1,187 @@
+package adb
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+// installAbandonTimeout bounds the abandonment of the sessions of a failed installation, which is
+// done even if the context of the installation is done.
+const installAbandonTimeout = 10 * time.Second
+
+// InstallSplit is one of the APKs of a package installed with Device.InstallMultiple, e.g. the
+// base APK or a configuration split.
+type InstallSplit struct {
+	// Name identifies the split in the session, e.g. "base.apk" or "split_config.arm64_v8a.apk".
+	// Names must be unique within a package.
+	Name string
+
+	// Reader must contain exactly Size bytes.
+	Reader io.Reader
+	Size   int64
+}
+
+// InstallPackage is one of the packages installed together by Device.InstallMultiPackage.
+type InstallPackage struct {
+	Splits []InstallSplit
+
+	// APEX is true if the package is an APEX module rather than an app.
+	APEX bool
+}
+
+/*
+InstallMultiple installs a package made of several APKs, e.g. the splits of an app bundle,
+with a package manager session:
+	install-create, install-write for each split, install-commit
+
+If any split fails to be written, the session is abandoned so nothing is installed. If the
+package manager reports a failure, the returned error wraps an *InstallError.
+opts.Progress is called for each split.
+*/
+func (c *Device) InstallMultiple(splits []InstallSplit, opts InstallOptions) error {
+	return c.InstallMultipleContext(context.Background(), splits, opts)
+}
+
+// InstallMultipleContext is like InstallMultiple, but gives up as soon as ctx is done, in which
+// case the session is abandoned.
+func (c *Device) InstallMultipleContext(ctx context.Context, splits []InstallSplit, opts InstallOptions) error {
+	err := c.installPackages(ctx, []InstallPackage{{Splits: splits}}, opts, false)
+	return wrapClientError(err, c, "InstallMultiple")
+}
+
+/*
+InstallMultiPackage installs several packages atomically, e.g. an APEX module and the APKs that
+depend on it. Each package gets its own child session, which are all committed together by a
+parent session created with --multi-package. If any package fails, all the sessions are
+abandoned.
+
+The device must be running Android 10 or later.
+*/
+func (c *Device) InstallMultiPackage(packages []InstallPackage, opts InstallOptions) error {
+	return c.InstallMultiPackageContext(context.Background(), packages, opts)
+}
+
+// InstallMultiPackageContext is like InstallMultiPackage, but gives up as soon as ctx is done,
+// in which case all the sessions are abandoned.
+func (c *Device) InstallMultiPackageContext(ctx context.Context, packages []InstallPackage, opts InstallOptions) error {
+	err := c.installPackages(ctx, packages, opts, true)
+	return wrapClientError(err, c, "InstallMultiPackage")
+}
+
+// installPackages installs packages in a single session if multiPackage is false, which then
+// must only have one package, else in child sessions of a multi-package session.
+func (c *Device) installPackages(ctx context.Context, packages []InstallPackage, opts InstallOptions, multiPackage bool) (err error) {
+	var sessions []int
+	defer func() {
+		if err == nil {
+			return
+		}
+		// The sessions must be abandoned even if ctx is done, or they'd be left on the device.
+		ctx, cancel := context.WithTimeout(context.Background(), installAbandonTimeout)
+		defer cancel()
+		for _, session := range sessions {
+			c.runPackageCommand(ctx, nil, 0, "install-abandon", strconv.Itoa(session))
+		}
+	}()
+
+	var parent int
+	if multiPackage {
+		parent, err = c.createInstallSession(ctx, append([]string{"--multi-package"}, opts.args()...))
+		if err != nil {
+			return err
+		}
+		sessions = append(sessions, parent)
+	}
+
+	var children []string
+	for _, pkg := range packages {
+		args := opts.args()
+		if pkg.APEX {
+			args = append(args, "--apex")
+		}
+		session, err := c.createInstallSession(ctx, args)
+		if err != nil {
+			return err
+		}
+		sessions = append(sessions, session)
+		children = append(children, strconv.Itoa(session))
+
+		for _, split := range pkg.Splits {
+			if err := c.writeInstallSplit(ctx, session, split, opts.Progress); err != nil {
+				return err
+			}
+		}
+	}
+
+	if multiPackage {
+		args := append([]string{"install-add-session", strconv.Itoa(parent)}, children...)
+		output, err := c.runPackageCommand(ctx, nil, 0, args...)
+		if err == nil {
+			err = parseSessionOutput("install-add-session", output)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	output, err := c.runPackageCommand(ctx, nil, 0, "install-commit", strconv.Itoa(sessions[0]))
+	if err != nil {
+		return err
+	}
+	return parseInstallOutput(output)
+}
+
+var reSessionID = regexp.MustCompile(`\[(\d+)\]`)
+
+// createInstallSession runs install-create with args, and returns the id of the new session.
+func (c *Device) createInstallSession(ctx context.Context, args []string) (int, error) {
+	output, err := c.runPackageCommand(ctx, nil, 0, append([]string{"install-create"}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+	return parseSessionID(output)
+}
+
+// parseSessionID parses the output of install-create, e.g.
+//	Success: created install session [1234]
+func parseSessionID(output string) (int, error) {
+	if err := parseSessionOutput("install-create", output); err != nil {
+		return 0, err
+	}
+	matches := reSessionID.FindStringSubmatch(output)
+	if matches == nil {
+		return 0, errors.Errorf(errors.ParseError, "no session id in install-create output: %s",
+			strings.TrimSpace(output))
+	}
+	id, err := strconv.Atoi(matches[1])
+	return id, errors.WrapErrorf(err, errors.ParseError, "invalid session id: %s", matches[1])
+}
+
+// writeInstallSplit streams split to session.
+func (c *Device) writeInstallSplit(ctx context.Context, session int, split InstallSplit, progress func(InstallProgress)) error {
+	reader := newInstallProgressReader(split.Reader, split.Name, split.Size, progress)
+	output, err := c.runPackageCommand(ctx, reader, split.Size, "install-write", "-S",
+		strconv.FormatInt(split.Size, 10), strconv.Itoa(session), split.Name, "-")
+	if err != nil {
+		return err
+	}
+	return parseSessionOutput("install-write", output)
+}
+
+// parseSessionOutput returns nil if the output of a session command starts with Success, else
+// an error that wraps an *InstallError if it reports a failure like install does.
+func parseSessionOutput(command, output string) error {
+	if strings.HasPrefix(strings.TrimSpace(output), "Success") {
+		return nil
+	}
+	if reInstallFailure.MatchString(output) {
+		return parseInstallOutput(output)
+	}
+	return errors.Errorf(errors.AdbError, "%s failed: %s", command, strings.TrimSpace(output))
+}
@@ -0,0 +1,76 @@
+package adb
+
+import (
+	stderrors "errors"
+	"strings"
+	"testing"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/kvnxiao/go-adb/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSessionID(t *testing.T) {
+	id, err := parseSessionID("Success: created install session [1234567]\n")
+	assert.NoError(t, err)
+	assert.Equal(t, 1234567, id)
+
+	_, err = parseSessionID("Success: created install session\n")
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+
+	_, err = parseSessionID("Error: java.lang.IllegalArgumentException: Unknown option --foo\n")
+	assert.Equal(t, errors.AdbError, err.(*errors.Err).Code)
+}
+
+func TestParseSessionOutput(t *testing.T) {
+	assert.NoError(t, parseSessionOutput("install-write", "Success: streamed 1024 bytes\n"))
+
+	err := parseSessionOutput("install-write", "Failure [INSTALL_FAILED_INVALID_APK: Split base.apk was defined multiple times]\n")
+	var installErr *InstallError
+	require.True(t, stderrors.As(err, &installErr))
+	assert.Equal(t, "INSTALL_FAILED_INVALID_APK", installErr.Code)
+
+	err = parseSessionOutput("install-write", "Error: Unable to open file: -\n")
+	assert.Equal(t, errors.AdbError, err.(*errors.Err).Code)
+	assert.False(t, stderrors.As(err, &installErr))
+	assert.Contains(t, err.Error(), "install-write failed")
+}
+
+func TestInstallProgressReader(t *testing.T) {
+	var reports []InstallProgress
+	reader := newInstallProgressReader(strings.NewReader("hello"), "base.apk", 5, func(p InstallProgress) {
+		reports = append(reports, p)
+	})
+	buf := make([]byte, 3)
+	reader.Read(buf)
+	reader.Read(buf)
+	assert.Equal(t, []InstallProgress{
+		{Name: "base.apk", Written: 3, Size: 5},
+		{Name: "base.apk", Written: 5, Size: 5, Done: true},
+	}, reports)
+
+	plain := strings.NewReader("")
+	assert.Equal(t, plain, newInstallProgressReader(plain, "base.apk", 0, nil))
+}
+
+func TestInstallMultipleCreateFails(t *testing.T) {
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"cmd", "Failure [INSTALL_FAILED_INVALID_APK]\n"},
+	}
+	client := (&Adb{s}).Device(DeviceWithSerial("abc"))
+
+	err := client.InstallMultiple([]InstallSplit{
+		{Name: "base.apk", Reader: strings.NewReader("apk!"), Size: 4},
+	}, InstallOptions{Replace: true})
+	var installErr *InstallError
+	require.True(t, stderrors.As(err, &installErr))
+	assert.Equal(t, "INSTALL_FAILED_INVALID_APK", installErr.Code)
+	// No session was created, so none is abandoned.
+	assert.Equal(t, []string{
+		"host-serial:abc:features",
+		"host:transport:abc",
+		"exec:cmd package install-create -r",
+	}, s.Requests)
+}
@@ -0,0 +1,77 @@
+package adb
+
+import (
+	stderrors "errors"
+	"strings"
+	"testing"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/kvnxiao/go-adb/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstallOptionsArgs(t *testing.T) {
+	assert.Empty(t, InstallOptions{}.args())
+	assert.Equal(t, []string{"-r", "-d", "-g", "-t", "--user", "current"}, InstallOptions{
+		Replace:          true,
+		AllowDowngrade:   true,
+		GrantPermissions: true,
+		AllowTest:        true,
+		User:             "current",
+	}.args())
+}
+
+func TestParseInstallOutput(t *testing.T) {
+	assert.NoError(t, parseInstallOutput("Success\n"))
+	assert.NoError(t, parseInstallOutput("Performing Streamed Install\nSuccess\n"))
+
+	err := parseInstallOutput("Performing Streamed Install\n" +
+		"Failure [INSTALL_FAILED_VERSION_DOWNGRADE: Downgrade detected: Update version code 1 is older than current 2]\n")
+	require.Error(t, err)
+	assert.Equal(t, errors.AdbError, err.(*errors.Err).Code)
+	var installErr *InstallError
+	require.True(t, stderrors.As(err, &installErr))
+	assert.Equal(t, &InstallError{
+		Code:    "INSTALL_FAILED_VERSION_DOWNGRADE",
+		Message: "Downgrade detected: Update version code 1 is older than current 2",
+	}, installErr)
+
+	err = parseInstallOutput("Failure [INSTALL_FAILED_INSUFFICIENT_STORAGE]")
+	require.True(t, stderrors.As(err, &installErr))
+	assert.Equal(t, &InstallError{Code: "INSTALL_FAILED_INSUFFICIENT_STORAGE"}, installErr)
+	assert.Equal(t, "Failure [INSTALL_FAILED_INSUFFICIENT_STORAGE]", installErr.Error())
+
+	err = parseInstallOutput("Error: APK content must be streamed\n")
+	assert.Equal(t, errors.AdbError, err.(*errors.Err).Code)
+	assert.False(t, stderrors.As(err, &installErr))
+}
+
+func TestInstall(t *testing.T) {
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"shell_v2,cmd", "Success\n"},
+	}
+	client := (&Adb{s}).Device(DeviceWithSerial("abc"))
+
+	err := client.Install(strings.NewReader("apk!"), 4, InstallOptions{Replace: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"host-serial:abc:features",
+		"host:transport:abc",
+		"exec:cmd package install -S 4 -r",
+	}, s.Requests)
+}
+
+func TestInstallFailure(t *testing.T) {
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"abb_exec", "Failure [INSTALL_FAILED_TEST_ONLY: installPackageLI]\n"},
+	}
+	client := (&Adb{s}).Device(DeviceWithSerial("abc"))
+
+	err := client.Install(strings.NewReader("apk!"), 4, InstallOptions{})
+	var installErr *InstallError
+	require.True(t, stderrors.As(err, &installErr))
+	assert.Equal(t, "INSTALL_FAILED_TEST_ONLY", installErr.Code)
+}
@@ -50,6 +50,11 @@ const (
 	DeviceNotFound
 	// Tried to perform an operation on a path that doesn't exist on the device.
 	FileNoExistError
+	// The context passed to the operation was canceled or its deadline exceeded.
+	// The cause is the error returned by the context's Err method.
+	ContextCanceled
+	// An operation on a file on the host failed, e.g. while pulling a file.
+	LocalFileError
 )
 
 func Errorf(code ErrCode, format string, args ...interface{}) error {
@@ -151,6 +156,12 @@ func (err *Err) Error() string {
 	return msg
 }
 
+// Unwrap returns the cause of err, so the standard library's errors.Is and errors.As can
+// look through the chain.
+func (err *Err) Unwrap() error {
+	return err.Cause
+}
+
 // HasErrCode returns true if err is an *Err and err.Code == code.
 func HasErrCode(err error, code ErrCode) bool {
 	switch err := err.(type) {
@@ -0,0 +1,143 @@
+package adb
+
+// Keycode is an Android key code, as defined by android.view.KeyEvent.
+type Keycode int
+
+const (
+	KeycodeUnknown          Keycode = 0
+	KeycodeSoftLeft         Keycode = 1
+	KeycodeSoftRight        Keycode = 2
+	KeycodeHome             Keycode = 3
+	KeycodeBack             Keycode = 4
+	KeycodeCall             Keycode = 5
+	KeycodeEndCall          Keycode = 6
+	Keycode0                Keycode = 7
+	Keycode1                Keycode = 8
+	Keycode2                Keycode = 9
+	Keycode3                Keycode = 10
+	Keycode4                Keycode = 11
+	Keycode5                Keycode = 12
+	Keycode6                Keycode = 13
+	Keycode7                Keycode = 14
+	Keycode8                Keycode = 15
+	Keycode9                Keycode = 16
+	KeycodeStar             Keycode = 17
+	KeycodePound            Keycode = 18
+	KeycodeDpadUp           Keycode = 19
+	KeycodeDpadDown         Keycode = 20
+	KeycodeDpadLeft         Keycode = 21
+	KeycodeDpadRight        Keycode = 22
+	KeycodeDpadCenter       Keycode = 23
+	KeycodeVolumeUp         Keycode = 24
+	KeycodeVolumeDown       Keycode = 25
+	KeycodePower            Keycode = 26
+	KeycodeCamera           Keycode = 27
+	KeycodeClear            Keycode = 28
+	KeycodeA                Keycode = 29
+	KeycodeB                Keycode = 30
+	KeycodeC                Keycode = 31
+	KeycodeD                Keycode = 32
+	KeycodeE                Keycode = 33
+	KeycodeF                Keycode = 34
+	KeycodeG                Keycode = 35
+	KeycodeH                Keycode = 36
+	KeycodeI                Keycode = 37
+	KeycodeJ                Keycode = 38
+	KeycodeK                Keycode = 39
+	KeycodeL                Keycode = 40
+	KeycodeM                Keycode = 41
+	KeycodeN                Keycode = 42
+	KeycodeO                Keycode = 43
+	KeycodeP                Keycode = 44
+	KeycodeQ                Keycode = 45
+	KeycodeR                Keycode = 46
+	KeycodeS                Keycode = 47
+	KeycodeT                Keycode = 48
+	KeycodeU                Keycode = 49
+	KeycodeV                Keycode = 50
+	KeycodeW                Keycode = 51
+	KeycodeX                Keycode = 52
+	KeycodeY                Keycode = 53
+	KeycodeZ                Keycode = 54
+	KeycodeComma            Keycode = 55
+	KeycodePeriod           Keycode = 56
+	KeycodeAltLeft          Keycode = 57
+	KeycodeAltRight         Keycode = 58
+	KeycodeShiftLeft        Keycode = 59
+	KeycodeShiftRight       Keycode = 60
+	KeycodeTab              Keycode = 61
+	KeycodeSpace            Keycode = 62
+	KeycodeSym              Keycode = 63
+	KeycodeExplorer         Keycode = 64
+	KeycodeEnvelope         Keycode = 65
+	KeycodeEnter            Keycode = 66
+	KeycodeDel              Keycode = 67
+	KeycodeGrave            Keycode = 68
+	KeycodeMinus            Keycode = 69
+	KeycodeEquals           Keycode = 70
+	KeycodeLeftBracket      Keycode = 71
+	KeycodeRightBracket     Keycode = 72
+	KeycodeBackslash        Keycode = 73
+	KeycodeSemicolon        Keycode = 74
+	KeycodeApostrophe       Keycode = 75
+	KeycodeSlash            Keycode = 76
+	KeycodeAt               Keycode = 77
+	KeycodeNum              Keycode = 78
+	KeycodeHeadsetHook      Keycode = 79
+	KeycodeFocus            Keycode = 80
+	KeycodePlus             Keycode = 81
+	KeycodeMenu             Keycode = 82
+	KeycodeNotification     Keycode = 83
+	KeycodeSearch           Keycode = 84
+	KeycodeMediaPlayPause   Keycode = 85
+	KeycodeMediaStop        Keycode = 86
+	KeycodeMediaNext        Keycode = 87
+	KeycodeMediaPrevious    Keycode = 88
+	KeycodeMediaRewind      Keycode = 89
+	KeycodeMediaFastForward Keycode = 90
+	KeycodeMute             Keycode = 91
+	KeycodePageUp           Keycode = 92
+	KeycodePageDown         Keycode = 93
+	KeycodeEscape           Keycode = 111
+	KeycodeForwardDel       Keycode = 112
+	KeycodeCtrlLeft         Keycode = 113
+	KeycodeCtrlRight        Keycode = 114
+	KeycodeCapsLock         Keycode = 115
+	KeycodeScrollLock       Keycode = 116
+	KeycodeMetaLeft         Keycode = 117
+	KeycodeMetaRight        Keycode = 118
+	KeycodeFunction         Keycode = 119
+	KeycodeSysRq            Keycode = 120
+	KeycodeBreak            Keycode = 121
+	KeycodeMoveHome         Keycode = 122
+	KeycodeMoveEnd          Keycode = 123
+	KeycodeInsert           Keycode = 124
+	KeycodeForward          Keycode = 125
+	KeycodeMediaPlay        Keycode = 126
+	KeycodeMediaPause       Keycode = 127
+	KeycodeF1               Keycode = 131
+	KeycodeF2               Keycode = 132
+	KeycodeF3               Keycode = 133
+	KeycodeF4               Keycode = 134
+	KeycodeF5               Keycode = 135
+	KeycodeF6               Keycode = 136
+	KeycodeF7               Keycode = 137
+	KeycodeF8               Keycode = 138
+	KeycodeF9               Keycode = 139
+	KeycodeF10              Keycode = 140
+	KeycodeF11              Keycode = 141
+	KeycodeF12              Keycode = 142
+	KeycodeVolumeMute       Keycode = 164
+	KeycodeSettings         Keycode = 176
+	KeycodeAppSwitch        Keycode = 187
+	KeycodeAssist           Keycode = 219
+	KeycodeBrightnessDown   Keycode = 220
+	KeycodeBrightnessUp     Keycode = 221
+	KeycodeSleep            Keycode = 223
+	KeycodeWakeup           Keycode = 224
+	KeycodeVoiceAssist      Keycode = 231
+	KeycodeCut              Keycode = 277
+	KeycodeCopy             Keycode = 278
+	KeycodePaste            Keycode = 279
+	KeycodeAllApps          Keycode = 284
+)
@@ -0,0 +1,325 @@
+package adb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+// LogPriority is the priority, or level, of a log entry.
+type LogPriority uint8
+
+const (
+	LogUnknown LogPriority = iota
+	LogDefault
+	LogVerbose
+	LogDebug
+	LogInfo
+	LogWarn
+	LogError
+	LogFatal
+	LogSilent
+)
+
+var logPriorityLetters = "??VDIWEFS"
+
+// String returns the letter of the priority used by logcat, e.g. "I" for LogInfo.
+func (p LogPriority) String() string {
+	if int(p) >= len(logPriorityLetters) {
+		return "?"
+	}
+	return logPriorityLetters[p : p+1]
+}
+
+// LogBuffer is one of the log buffers of the device.
+type LogBuffer int
+
+// The values of the buffers are their ids in the log entries.
+const (
+	// LogBufferUnknown is the buffer of entries from old versions of Android, which don't
+	// report it, when more than one buffer is read.
+	LogBufferUnknown LogBuffer = iota - 1
+	LogBufferMain
+	LogBufferRadio
+	LogBufferEvents
+	LogBufferSystem
+	LogBufferCrash
+	LogBufferStats
+	LogBufferSecurity
+	LogBufferKernel
+)
+
+var logBufferNames = []string{"main", "radio", "events", "system", "crash", "stats", "security", "kernel"}
+
+// String returns the name of the buffer used by logcat -b, e.g. "main".
+func (b LogBuffer) String() string {
+	if b < 0 || int(b) >= len(logBufferNames) {
+		return "unknown"
+	}
+	return logBufferNames[b]
+}
+
+// ParseLogBuffer returns the buffer called name, e.g. "main".
+func ParseLogBuffer(name string) (LogBuffer, error) {
+	for i, bufferName := range logBufferNames {
+		if name == bufferName {
+			return LogBuffer(i), nil
+		}
+	}
+	return LogBufferUnknown, errors.Errorf(errors.ParseError, "unknown log buffer: %s", name)
+}
+
+// binary returns true if the entries of the buffer have binary payloads rather than a tag
+// and a message.
+func (b LogBuffer) binary() bool {
+	return b == LogBufferEvents || b == LogBufferStats || b == LogBufferSecurity
+}
+
+// LogEntry is an entry of the log of a device.
+type LogEntry struct {
+	Time time.Time
+	PID  int
+	TID  int
+
+	// UID of the process, only reported by Android 7 and later.
+	UID int
+
+	Priority LogPriority
+	Tag      string
+	Message  string
+	Buffer   LogBuffer
+
+	// Payload is the binary payload of entries of the events, stats and security buffers,
+	// which have no tag or message.
+	Payload []byte
+}
+
+// LogcatOptions configures Device.Logcat.
+type LogcatOptions struct {
+	// Buffers to read, logcat reads main, system and crash by default.
+	Buffers []LogBuffer
+
+	// Filters are logcat filter specs, e.g. "ActivityManager:I" or "*:S".
+	Filters []string
+
+	// Tail starts with the Tail most recent entries rather than all the entries of the
+	// buffers, like -T or -t.
+	Tail int
+
+	// Since starts with the entries logged at or after Since rather than all the entries of the
+	// buffers. Requires Android 7 or later, and can't be used with Tail.
+	Since time.Time
+
+	// Dump makes logcat exit after reading the entries already in the buffers, rather than
+	// waiting for new ones.
+	Dump bool
+
+	// PID only reads the entries of the process with that id if not 0. Requires Android 7 or
+	// later.
+	PID int
+}
+
+func (opts LogcatOptions) args() ([]string, error) {
+	args := []string{"-B"}
+	for _, buffer := range opts.Buffers {
+		if buffer < 0 || int(buffer) >= len(logBufferNames) {
+			return nil, errors.AssertionErrorf("invalid log buffer: %d", buffer)
+		}
+		args = append(args, "-b", buffer.String())
+	}
+
+	if opts.Tail > 0 && !opts.Since.IsZero() {
+		return nil, errors.AssertionErrorf("Tail and Since can't be used together")
+	}
+	// -t implies -d, -T doesn't.
+	tailFlag := "-T"
+	if opts.Dump {
+		tailFlag = "-t"
+	}
+	switch {
+	case opts.Tail > 0:
+		args = append(args, tailFlag, strconv.Itoa(opts.Tail))
+	case !opts.Since.IsZero():
+		since := opts.Since.UnixNano()
+		args = append(args, tailFlag, fmt.Sprintf("%d.%03d", since/1e9, since%1e9/1e6))
+	case opts.Dump:
+		args = append(args, "-d")
+	}
+
+	if opts.PID != 0 {
+		args = append(args, "--pid", strconv.Itoa(opts.PID))
+	}
+	return append(args, opts.Filters...), nil
+}
+
+/*
+Logcat runs logcat on the device in binary mode, and returns a reader that decodes its entries:
+	logcat, err := device.Logcat(adb.LogcatOptions{Filters: []string{"*:W"}})
+	defer logcat.Close()
+	for {
+		entry, err := logcat.Next()
+		if err != nil {
+			break
+		}
+		fmt.Println(entry.Tag, entry.Message)
+	}
+
+Unless opts.Dump is true, the reader keeps waiting for new entries until it's closed.
+*/
+func (c *Device) Logcat(opts LogcatOptions) (*LogReader, error) {
+	return c.LogcatContext(context.Background(), opts)
+}
+
+// LogcatContext is like Logcat, but logcat is stopped as soon as ctx is done, after which Next
+// returns a ContextCanceled error.
+func (c *Device) LogcatContext(ctx context.Context, opts LogcatOptions) (*LogReader, error) {
+	args, err := opts.args()
+	if err != nil {
+		return nil, wrapClientError(err, c, "Logcat")
+	}
+	// The command is run by a shell, which mustn't expand the * of a filter spec, so every
+	// argument is quoted here rather than by OpenCommand.
+	cmdLine := "logcat"
+	for _, arg := range args {
+		cmdLine += " " + quoteShellArg(arg)
+	}
+	conn, err := c.OpenCommandContext(ctx, cmdLine)
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewLogReader(&contextReadCloser{ctx: ctx, ReadCloser: conn})
+	if len(opts.Buffers) == 1 {
+		r.buffer = opts.Buffers[0]
+	}
+	return r, nil
+}
+
+/*
+LogReader decodes the binary output of logcat -B, e.g. from Device.OpenCommand, which is a
+sequence of logger_entry structures. All the versions of the structure are supported:
+	v1: len, padding, pid, tid, sec, nsec
+	v2: len, header size, pid, tid, sec, nsec, euid
+	v3: len, header size, pid, tid, sec, nsec, buffer id
+	v4: len, header size, pid, tid, sec, nsec, buffer id, uid
+*/
+type LogReader struct {
+	r      *bufio.Reader
+	closer io.Closer
+
+	// buffer is the buffer of entries that don't report theirs.
+	buffer LogBuffer
+}
+
+// NewLogReader returns a LogReader that decodes r. If r is an io.Closer, it's closed by Close.
+func NewLogReader(r io.Reader) *LogReader {
+	closer, _ := r.(io.Closer)
+	return &LogReader{r: bufio.NewReader(r), closer: closer, buffer: LogBufferUnknown}
+}
+
+// Entry header sizes of each version of logger_entry.
+const (
+	loggerEntryV1Size  = 20
+	loggerEntryV3Size  = 24
+	loggerEntryV4Size  = 28
+	loggerEntryMaxSize = 5 * 1024
+)
+
+// Next returns the next entry, or io.EOF once logcat exits.
+func (r *LogReader) Next() (*LogEntry, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(r.r, prefix[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, wrapLogReadErr(err)
+	}
+	payloadSize := int(binary.LittleEndian.Uint16(prefix[:]))
+	headerSize := int(binary.LittleEndian.Uint16(prefix[2:]))
+	if headerSize == 0 {
+		// v1 has padding instead of the header size.
+		headerSize = loggerEntryV1Size
+	}
+	if headerSize < loggerEntryV1Size || headerSize+payloadSize > loggerEntryMaxSize {
+		return nil, errors.Errorf(errors.ParseError, "invalid log entry header: size %d, payload size %d",
+			headerSize, payloadSize)
+	}
+
+	data := make([]byte, headerSize+payloadSize)
+	copy(data, prefix[:])
+	if _, err := io.ReadFull(r.r, data[len(prefix):]); err != nil {
+		return nil, wrapLogReadErr(err)
+	}
+	return r.decodeEntry(data[:headerSize], data[headerSize:]), nil
+}
+
+func (r *LogReader) decodeEntry(header, payload []byte) *LogEntry {
+	entry := &LogEntry{
+		PID:    int(int32(binary.LittleEndian.Uint32(header[4:]))),
+		TID:    int(binary.LittleEndian.Uint32(header[8:])),
+		Time:   time.Unix(int64(binary.LittleEndian.Uint32(header[12:])), int64(binary.LittleEndian.Uint32(header[16:]))),
+		Buffer: r.buffer,
+	}
+	switch {
+	case len(header) >= loggerEntryV4Size:
+		entry.Buffer = LogBuffer(binary.LittleEndian.Uint32(header[20:]))
+		entry.UID = int(binary.LittleEndian.Uint32(header[24:]))
+	case len(header) >= loggerEntryV3Size:
+		// v2 has the effective uid where v3 has the buffer id, which are much smaller.
+		id := binary.LittleEndian.Uint32(header[20:])
+		if int(id) < len(logBufferNames) {
+			entry.Buffer = LogBuffer(id)
+		} else {
+			entry.UID = int(id)
+		}
+	}
+
+	if entry.Buffer.binary() {
+		entry.Priority = LogInfo
+		entry.Payload = payload
+		return entry
+	}
+
+	// The payload is the priority, then the tag and the message, both NUL-terminated.
+	if len(payload) == 0 {
+		return entry
+	}
+	entry.Priority = LogPriority(payload[0])
+	rest := payload[1:]
+	if i := bytes.IndexByte(rest, 0); i >= 0 {
+		entry.Tag, rest = string(rest[:i]), rest[i+1:]
+	} else {
+		entry.Tag, rest = string(rest), nil
+	}
+	if i := bytes.IndexByte(rest, 0); i >= 0 {
+		rest = rest[:i]
+	}
+	entry.Message = strings.TrimRight(string(rest), "\n")
+	return entry
+}
+
+// Close stops reading, after which Next returns an error.
+func (r *LogReader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+func wrapLogReadErr(err error) error {
+	if err == io.ErrUnexpectedEOF {
+		return errors.WrapErrorf(err, errors.ParseError, "truncated log entry")
+	}
+	if _, ok := err.(*errors.Err); ok {
+		return err
+	}
+	return errors.WrapErrorf(err, errors.NetworkError, "error reading log")
+}
@@ -0,0 +1,142 @@
+package adb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/kvnxiao/go-adb/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeLogEntry encodes a logger_entry with a header of headerSize bytes, 20 for v1, 24 for v3
+// with the buffer id in extra[0], 28 for v4 with the buffer id and uid in extra.
+func encodeLogEntry(headerSize int, pid, tid int, t time.Time, payload []byte, extra ...uint32) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint16(len(payload)))
+	if headerSize == loggerEntryV1Size {
+		binary.Write(&buf, binary.LittleEndian, uint16(0))
+	} else {
+		binary.Write(&buf, binary.LittleEndian, uint16(headerSize))
+	}
+	binary.Write(&buf, binary.LittleEndian, []int32{int32(pid), int32(tid), int32(t.Unix()), int32(t.Nanosecond())})
+	binary.Write(&buf, binary.LittleEndian, extra)
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+func textLogPayload(priority LogPriority, tag, message string) []byte {
+	return append(append([]byte{byte(priority)}, tag+"\x00"...), message+"\x00"...)
+}
+
+func TestLogReader(t *testing.T) {
+	t1 := time.Unix(1600000000, 123456789)
+	var data []byte
+	data = append(data, encodeLogEntry(20, 100, 101, t1, textLogPayload(LogInfo, "v1", "hello\n"))...)
+	data = append(data, encodeLogEntry(24, 200, 201, t1, textLogPayload(LogWarn, "v3", "world"), 3)...)
+	data = append(data, encodeLogEntry(24, 300, 301, t1, textLogPayload(LogDebug, "v2", "euid"), 10042)...)
+	data = append(data, encodeLogEntry(28, 400, 401, t1, []byte{1, 2, 3, 4}, 2, 1000)...)
+	// v4 entries whose message isn't NUL-terminated.
+	data = append(data, encodeLogEntry(28, 500, 501, t1, []byte("\x06tag\x00no terminator"), 4, 0)...)
+
+	r := NewLogReader(bytes.NewReader(data))
+	for _, expected := range []LogEntry{
+		{Time: t1, PID: 100, TID: 101, Priority: LogInfo, Tag: "v1", Message: "hello", Buffer: LogBufferUnknown},
+		{Time: t1, PID: 200, TID: 201, Priority: LogWarn, Tag: "v3", Message: "world", Buffer: LogBufferSystem},
+		{Time: t1, PID: 300, TID: 301, UID: 10042, Priority: LogDebug, Tag: "v2", Message: "euid", Buffer: LogBufferUnknown},
+		{Time: t1, PID: 400, TID: 401, UID: 1000, Priority: LogInfo, Buffer: LogBufferEvents, Payload: []byte{1, 2, 3, 4}},
+		{Time: t1, PID: 500, TID: 501, Priority: LogError, Tag: "tag", Message: "no terminator", Buffer: LogBufferCrash},
+	} {
+		entry, err := r.Next()
+		require.NoError(t, err)
+		assert.True(t, expected.Time.Equal(entry.Time))
+		entry.Time = expected.Time
+		assert.Equal(t, expected, *entry)
+	}
+	_, err := r.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestLogReaderInvalid(t *testing.T) {
+	entry := encodeLogEntry(28, 1, 1, time.Now(), textLogPayload(LogInfo, "tag", "message"), 0, 0)
+	_, err := NewLogReader(bytes.NewReader(entry[:len(entry)-1])).Next()
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+
+	entry = append([]byte{}, entry...)
+	binary.LittleEndian.PutUint16(entry[2:], 12)
+	_, err = NewLogReader(bytes.NewReader(entry)).Next()
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+}
+
+func TestLogcatOptionsArgs(t *testing.T) {
+	since := time.Unix(1600000000, 250000000)
+	for _, test := range []struct {
+		opts     LogcatOptions
+		expected []string
+	}{
+		{LogcatOptions{}, []string{"-B"}},
+		{LogcatOptions{Dump: true}, []string{"-B", "-d"}},
+		{
+			LogcatOptions{Buffers: []LogBuffer{LogBufferMain, LogBufferCrash}, Tail: 10, PID: 42},
+			[]string{"-B", "-b", "main", "-b", "crash", "-T", "10", "--pid", "42"},
+		},
+		{LogcatOptions{Tail: 10, Dump: true}, []string{"-B", "-t", "10"}},
+		{LogcatOptions{Since: since}, []string{"-B", "-T", "1600000000.250"}},
+		{
+			LogcatOptions{Filters: []string{"ActivityManager:I", "*:S", "My Tag:D"}},
+			[]string{"-B", "ActivityManager:I", "*:S", "My Tag:D"},
+		},
+	} {
+		args, err := test.opts.args()
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, args)
+	}
+
+	_, err := LogcatOptions{Tail: 1, Since: since}.args()
+	assert.Equal(t, errors.AssertionError, err.(*errors.Err).Code)
+	_, err = LogcatOptions{Buffers: []LogBuffer{LogBufferUnknown}}.args()
+	assert.Equal(t, errors.AssertionError, err.(*errors.Err).Code)
+}
+
+func TestLogcat(t *testing.T) {
+	t1 := time.Unix(1600000000, 0)
+	entry := encodeLogEntry(24, 1, 2, t1, textLogPayload(LogInfo, "tag", "message"), 10042)
+	s := &MockServer{
+		Status: wire.StatusSuccess,
+		// Entries may be split across reads.
+		Messages: []string{string(entry[:10]), string(entry[10:])},
+	}
+	client := (&Adb{s}).Device(AnyDevice())
+
+	r, err := client.Logcat(LogcatOptions{Buffers: []LogBuffer{LogBufferRadio}, Dump: true,
+		Filters: []string{"ActivityManager:I", "Bob's Tag:D", "*:S"}})
+	require.NoError(t, err)
+	assert.Equal(t, `exec:logcat -B -b radio -d ActivityManager:I 'Bob'\''s Tag:D' '*:S'`, s.Requests[1])
+
+	e, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "message", e.Message)
+	assert.Equal(t, LogBufferRadio, e.Buffer)
+	assert.Equal(t, 10042, e.UID)
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+	assert.NoError(t, r.Close())
+}
+
+func TestLogPriorityString(t *testing.T) {
+	assert.Equal(t, "V", LogVerbose.String())
+	assert.Equal(t, "F", LogFatal.String())
+	assert.Equal(t, "?", LogPriority(42).String())
+	assert.Equal(t, "events", LogBufferEvents.String())
+	assert.Equal(t, "unknown", LogBufferUnknown.String())
+
+	buffer, err := ParseLogBuffer("crash")
+	assert.NoError(t, err)
+	assert.Equal(t, LogBufferCrash, buffer)
+	_, err = ParseLogBuffer("all")
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+}
@@ -0,0 +1,377 @@
+package adb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+// LogFormat is one of the text formats of logcat -v.
+type LogFormat int
+
+const (
+	LogFormatBrief LogFormat = iota
+	LogFormatProcess
+	LogFormatTag
+	LogFormatThread
+	LogFormatRaw
+	LogFormatTime
+	LogFormatThreadTime
+	LogFormatLong
+)
+
+var logFormatNames = []string{"brief", "process", "tag", "thread", "raw", "time", "threadtime", "long"}
+
+// String returns the name of the format used by logcat -v, e.g. "threadtime".
+func (f LogFormat) String() string {
+	if f < 0 || int(f) >= len(logFormatNames) {
+		return "unknown"
+	}
+	return logFormatNames[f]
+}
+
+// LogTextFormat is a text format of logcat, with its modifiers.
+type LogTextFormat struct {
+	Format LogFormat
+
+	// Year prints the year in times, Zone the offset of their time zone, and Epoch prints the
+	// seconds since the epoch instead of the date.
+	Year  bool
+	Zone  bool
+	Epoch bool
+
+	// USec and NSec print times with microseconds or nanoseconds rather than milliseconds.
+	USec bool
+	NSec bool
+
+	// UID prints the uid of the process before its pid.
+	UID bool
+
+	// Location is the time zone of the times, time.Local if nil. When parsing, it's only used
+	// for times without a zone.
+	Location *time.Location
+}
+
+/*
+ParseLogTextFormat parses the arguments of logcat -v, which are a format and modifiers, e.g.
+	ParseLogTextFormat("threadtime", "year,uid")
+The format is threadtime if specs only have modifiers, like logcat.
+*/
+func ParseLogTextFormat(specs ...string) (LogTextFormat, error) {
+	format := LogTextFormat{Format: LogFormatThreadTime}
+	for _, spec := range specs {
+	fields:
+		for _, name := range strings.Split(spec, ",") {
+			name = strings.TrimSpace(name)
+			switch name {
+			case "":
+			case "year":
+				format.Year = true
+			case "zone":
+				format.Zone = true
+			case "epoch":
+				format.Epoch = true
+			case "usec":
+				format.USec = true
+			case "nsec":
+				format.NSec = true
+			case "uid":
+				format.UID = true
+			case "UTC":
+				format.Location = time.UTC
+			default:
+				for i, formatName := range logFormatNames {
+					if name == formatName {
+						format.Format = LogFormat(i)
+						continue fields
+					}
+				}
+				return format, errors.Errorf(errors.ParseError, "unknown log format: %s", name)
+			}
+		}
+	}
+	return format, nil
+}
+
+func (f LogTextFormat) location() *time.Location {
+	if f.Location == nil {
+		return time.Local
+	}
+	return f.Location
+}
+
+/*
+FormatEntry renders entry like logcat, e.g. in the threadtime format
+	04-01 09:00:00.123  1234  1240 I ActivityManager: message
+Every line of the message is prefixed with the metadata of the entry, except in the long format
+which prints the metadata once followed by the message and an empty line. The result always
+ends with a newline.
+*/
+func (f LogTextFormat) FormatEntry(entry *LogEntry) string {
+	var uid string
+	if f.UID {
+		uid = fmt.Sprintf("%5d:", entry.UID)
+	}
+	priority := entry.Priority.String()
+	tag := fmt.Sprintf("%-8s", entry.Tag)
+
+	var prefix, suffix string
+	switch f.Format {
+	case LogFormatProcess:
+		prefix = fmt.Sprintf("%s(%s%5d) ", priority, uid, entry.PID)
+		suffix = fmt.Sprintf("  (%s)", entry.Tag)
+	case LogFormatTag:
+		prefix = fmt.Sprintf("%s/%s: ", priority, tag)
+	case LogFormatThread:
+		prefix = fmt.Sprintf("%s(%s%5d:%5d) ", priority, uid, entry.PID, entry.TID)
+	case LogFormatRaw:
+	case LogFormatTime:
+		prefix = fmt.Sprintf("%s %s/%s(%s%5d): ", f.formatTime(entry.Time), priority, tag, uid, entry.PID)
+	case LogFormatThreadTime:
+		prefix = fmt.Sprintf("%s %s%5d %5d %s %s: ", f.formatTime(entry.Time), uid, entry.PID, entry.TID, priority, tag)
+	case LogFormatLong:
+		return fmt.Sprintf("[ %s %s%5d:%5d %s/%s ]\n%s\n\n", f.formatTime(entry.Time), uid, entry.PID, entry.TID,
+			priority, tag, entry.Message)
+	default:
+		prefix = fmt.Sprintf("%s/%s(%s%5d): ", priority, tag, uid, entry.PID)
+	}
+
+	var b strings.Builder
+	for _, line := range strings.Split(entry.Message, "\n") {
+		b.WriteString(prefix)
+		b.WriteString(line)
+		b.WriteString(suffix)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (f LogTextFormat) formatTime(t time.Time) string {
+	t = t.In(f.location())
+	var s string
+	if f.Epoch {
+		s = fmt.Sprintf("%5d", t.Unix())
+	} else if f.Year {
+		s = t.Format("2006-01-02 15:04:05")
+	} else {
+		s = t.Format("01-02 15:04:05")
+	}
+
+	switch {
+	case f.NSec:
+		s += fmt.Sprintf(".%09d", t.Nanosecond())
+	case f.USec:
+		s += fmt.Sprintf(".%06d", t.Nanosecond()/1e3)
+	default:
+		s += fmt.Sprintf(".%03d", t.Nanosecond()/1e6)
+	}
+
+	if f.Zone && !f.Epoch {
+		s += t.Format(" -0700")
+	}
+	return s
+}
+
+// Fragments of the expressions of the text formats, the modifiers of the format are detected
+// from the text.
+const (
+	reLogTimeText     = `\s*(?P<time>\d+\.\d+|(?:\d{4}-)?\d\d-\d\d \d\d:\d\d:\d\d\.\d+(?: [+-]\d{4})?)`
+	reLogPriorityText = `(?P<priority>[VDIWEFS])`
+	reLogPIDText      = `\s*(?:(?P<uid>\S+?):)?\s*(?P<pid>\d+)`
+	reLogTIDText      = `\s*(?P<tid>\d+)`
+	reLogTagText      = `(?P<tag>.*?)\s*`
+	reLogMessageText  = `(?: (?P<message>.*))?$`
+)
+
+var (
+	reLogLines = map[LogFormat]*regexp.Regexp{
+		LogFormatBrief:      regexp.MustCompile(`^` + reLogPriorityText + `/` + reLogTagText + `\(` + reLogPIDText + `\):` + reLogMessageText),
+		LogFormatProcess:    regexp.MustCompile(`^` + reLogPriorityText + `\(` + reLogPIDText + `\) (?P<message>.*)  \((?P<tag>.*)\)$`),
+		LogFormatTag:        regexp.MustCompile(`^` + reLogPriorityText + `/` + reLogTagText + `:` + reLogMessageText),
+		LogFormatThread:     regexp.MustCompile(`^` + reLogPriorityText + `\(` + reLogPIDText + `:` + reLogTIDText + `\)` + reLogMessageText),
+		LogFormatTime:       regexp.MustCompile(`^` + reLogTimeText + ` ` + reLogPriorityText + `/` + reLogTagText + `\(` + reLogPIDText + `\):` + reLogMessageText),
+		LogFormatThreadTime: regexp.MustCompile(`^` + reLogTimeText + `\s` + reLogPIDText + `\s` + reLogTIDText + ` ` + reLogPriorityText + ` ` + reLogTagText + `:` + reLogMessageText),
+		LogFormatLong:       regexp.MustCompile(`^\[ ` + reLogTimeText + `\s` + reLogPIDText + `:` + reLogTIDText + ` ` + reLogPriorityText + `/` + reLogTagText + `\]$`),
+	}
+
+	// reLogBufferMarker matches the lines logcat prints before the first entry of each buffer.
+	reLogBufferMarker = regexp.MustCompile(`^--------- (?:beginning of|switch to) (\w+)$`)
+)
+
+/*
+LogTextReader parses the text output of logcat in any of the formats of logcat -v, e.g. from a
+capture of adb logcat. The modifiers of the format, e.g. year or uid, are detected from the
+text. Times without a year are assumed to be from the last year, and users printed instead
+of uids are ignored.
+
+Messages with more than one line are printed as one entry per line by logcat, except in the long
+format, so they're returned as separate entries.
+*/
+type LogTextReader struct {
+	scanner *bufio.Scanner
+	format  LogTextFormat
+
+	// buffer is the buffer of the entries, from the last beginning of marker.
+	buffer LogBuffer
+
+	// The entry of the long format being read, and the lines of its message.
+	pending      *LogEntry
+	pendingLines []string
+
+	now func() time.Time
+}
+
+// NewLogTextReader returns a LogTextReader that parses r, which is in format.
+func NewLogTextReader(r io.Reader, format LogTextFormat) *LogTextReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	return &LogTextReader{scanner: scanner, format: format, buffer: LogBufferUnknown, now: time.Now}
+}
+
+// Next returns the next entry, or io.EOF at the end of the text. If a line can't be parsed,
+// returns a ParseError, and the next call returns the following entry. Once r fails, or a line is
+// longer than 1 MiB, every call returns the same error.
+func (r *LogTextReader) Next() (*LogEntry, error) {
+	for r.scanner.Scan() {
+		line := strings.TrimRight(r.scanner.Text(), "\r")
+		if matches := reLogBufferMarker.FindStringSubmatch(line); matches != nil {
+			// Unknown buffers are reported as LogBufferUnknown.
+			r.buffer, _ = ParseLogBuffer(matches[1])
+			if r.pending != nil {
+				return r.flushPending(nil), nil
+			}
+			continue
+		}
+
+		if r.format.Format == LogFormatRaw {
+			return &LogEntry{Message: line, Buffer: r.buffer}, nil
+		}
+
+		entry, err := r.parseLine(line)
+		if r.format.Format != LogFormatLong {
+			return entry, err
+		}
+		switch {
+		case err == nil && r.pending != nil:
+			return r.flushPending(entry), nil
+		case err == nil:
+			r.pending = entry
+		case r.pending != nil:
+			r.pendingLines = append(r.pendingLines, line)
+		case line != "":
+			return nil, err
+		}
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		// The scanner stops at its first error, so it mustn't be reported as a ParseError,
+		// which callers skip to read the following entry.
+		if _, ok := err.(*errors.Err); ok {
+			return nil, err
+		}
+		return nil, errors.WrapErrorf(err, errors.NetworkError, "error reading log")
+	}
+	if r.pending != nil {
+		return r.flushPending(nil), nil
+	}
+	return nil, io.EOF
+}
+
+// flushPending returns the pending entry of the long format, and replaces it with next.
+func (r *LogTextReader) flushPending(next *LogEntry) *LogEntry {
+	entry, lines := r.pending, r.pendingLines
+	// Entries are followed by an empty line.
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	entry.Message = strings.Join(lines, "\n")
+	r.pending, r.pendingLines = next, nil
+	return entry
+}
+
+func (r *LogTextReader) parseLine(line string) (*LogEntry, error) {
+	re := reLogLines[r.format.Format]
+	if re == nil {
+		return nil, errors.AssertionErrorf("invalid log format: %d", r.format.Format)
+	}
+	matches := re.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, errors.Errorf(errors.ParseError, "invalid %s log line: %s", r.format.Format, line)
+	}
+
+	entry := &LogEntry{Buffer: r.buffer}
+	for i, name := range re.SubexpNames() {
+		value := matches[i]
+		var err error
+		switch name {
+		case "time":
+			entry.Time, err = r.parseTime(value)
+		case "priority":
+			entry.Priority = LogPriority(strings.Index(logPriorityLetters[2:], value) + 2)
+		case "uid":
+			// Some versions print the user name instead, which can't be converted.
+			entry.UID, _ = strconv.Atoi(value)
+		case "pid":
+			entry.PID, err = strconv.Atoi(value)
+		case "tid":
+			entry.TID, err = strconv.Atoi(value)
+		case "tag":
+			entry.Tag = value
+		case "message":
+			entry.Message = value
+		}
+		if err != nil {
+			return nil, errors.WrapErrorf(err, errors.ParseError, "invalid %s in log line: %s", name, line)
+		}
+	}
+	return entry, nil
+}
+
+// parseTime parses the time of an entry, in any of the formats of formatTime.
+func (r *LogTextReader) parseTime(s string) (time.Time, error) {
+	if !strings.Contains(s, " ") {
+		parts := strings.SplitN(s, ".", 2)
+		sec, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		nsec, err := strconv.ParseInt((parts[1] + "000000000")[:9], 10, 64)
+		return time.Unix(sec, nsec).In(r.format.location()), err
+	}
+
+	layout := "01-02 15:04:05"
+	hasYear := len(s) > 4 && s[4] == '-'
+	if hasYear {
+		layout = "2006-01-02 15:04:05"
+	}
+	// Fractional seconds are parsed even though the layout doesn't have them.
+	if i := strings.LastIndexAny(s, "+-"); i > len(layout) {
+		t, err := time.Parse(layout+" -0700", s)
+		if err != nil || hasYear {
+			return t, err
+		}
+		return r.withLastYear(t), nil
+	}
+	t, err := time.ParseInLocation(layout, s, r.format.location())
+	if err != nil || hasYear {
+		return t, err
+	}
+	return r.withLastYear(t), nil
+}
+
+// withLastYear returns t, which has no year, in the last year it could be in.
+func (r *LogTextReader) withLastYear(t time.Time) time.Time {
+	now := r.now().In(t.Location())
+	withYear := t.AddDate(now.Year(), 0, 0)
+	// Allow for the clock of the device to be ahead.
+	if withYear.After(now.Add(24 * time.Hour)) {
+		withYear = withYear.AddDate(-1, 0, 0)
+	}
+	return withYear
+}
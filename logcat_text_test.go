@@ -0,0 +1,199 @@
+package adb
+
+import (
+	stderrors "errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLogLocation = time.FixedZone("", 9*60*60)
+
+func testLogEntry() *LogEntry {
+	return &LogEntry{
+		Time:     time.Date(2020, 4, 1, 9, 0, 0, 123456789, testLogLocation),
+		PID:      1234,
+		TID:      1240,
+		UID:      10042,
+		Priority: LogInfo,
+		Tag:      "ActivityManager",
+		Message:  "Start proc 1234:com.example.app/u0a42",
+		Buffer:   LogBufferUnknown,
+	}
+}
+
+func TestParseLogTextFormat(t *testing.T) {
+	format, err := ParseLogTextFormat()
+	assert.NoError(t, err)
+	assert.Equal(t, LogTextFormat{Format: LogFormatThreadTime}, format)
+
+	format, err = ParseLogTextFormat("brief", "year,zone", "uid,nsec", "UTC")
+	assert.NoError(t, err)
+	assert.Equal(t, LogTextFormat{Format: LogFormatBrief, Year: true, Zone: true, UID: true, NSec: true, Location: time.UTC}, format)
+
+	_, err = ParseLogTextFormat("threadtime,color")
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+}
+
+func TestLogTextFormat(t *testing.T) {
+	entry := testLogEntry()
+	for _, test := range []struct {
+		format   LogTextFormat
+		expected string
+	}{
+		{LogTextFormat{Format: LogFormatBrief}, "I/ActivityManager( 1234): Start proc 1234:com.example.app/u0a42\n"},
+		{LogTextFormat{Format: LogFormatProcess}, "I( 1234) Start proc 1234:com.example.app/u0a42  (ActivityManager)\n"},
+		{LogTextFormat{Format: LogFormatTag}, "I/ActivityManager: Start proc 1234:com.example.app/u0a42\n"},
+		{LogTextFormat{Format: LogFormatThread}, "I( 1234: 1240) Start proc 1234:com.example.app/u0a42\n"},
+		{LogTextFormat{Format: LogFormatRaw}, "Start proc 1234:com.example.app/u0a42\n"},
+		{LogTextFormat{Format: LogFormatTime}, "04-01 09:00:00.123 I/ActivityManager( 1234): Start proc 1234:com.example.app/u0a42\n"},
+		{LogTextFormat{Format: LogFormatThreadTime}, "04-01 09:00:00.123  1234  1240 I ActivityManager: Start proc 1234:com.example.app/u0a42\n"},
+		{LogTextFormat{Format: LogFormatLong}, "[ 04-01 09:00:00.123  1234: 1240 I/ActivityManager ]\nStart proc 1234:com.example.app/u0a42\n\n"},
+		{
+			LogTextFormat{Format: LogFormatThreadTime, Year: true, Zone: true, UID: true, USec: true},
+			"2020-04-01 09:00:00.123456 +0900 10042: 1234  1240 I ActivityManager: Start proc 1234:com.example.app/u0a42\n",
+		},
+		{
+			LogTextFormat{Format: LogFormatTime, Epoch: true, NSec: true},
+			"1585699200.123456789 I/ActivityManager( 1234): Start proc 1234:com.example.app/u0a42\n",
+		},
+	} {
+		test.format.Location = testLogLocation
+		assert.Equal(t, test.expected, test.format.FormatEntry(entry))
+	}
+
+	short := &LogEntry{Priority: LogWarn, Tag: "Tag", PID: 1, Message: "line 1\nline 2"}
+	assert.Equal(t, "W/Tag     (    1): line 1\nW/Tag     (    1): line 2\n", LogTextFormat{}.FormatEntry(short))
+}
+
+func TestLogTextRoundTrip(t *testing.T) {
+	now := func() time.Time { return time.Date(2020, 6, 1, 0, 0, 0, 0, testLogLocation) }
+	for format := LogFormatBrief; format <= LogFormatLong; format++ {
+		for _, modifiers := range []LogTextFormat{
+			{},
+			{Year: true, Zone: true, UID: true, NSec: true},
+			{Epoch: true, USec: true, UID: true},
+		} {
+			modifiers.Format = format
+			modifiers.Location = testLogLocation
+			text := modifiers.FormatEntry(testLogEntry())
+
+			r := NewLogTextReader(strings.NewReader(text), LogTextFormat{Format: format, Location: testLogLocation})
+			r.now = now
+			entry, err := r.Next()
+			require.NoError(t, err, text)
+
+			expected := testLogEntry()
+			if format == LogFormatRaw {
+				expected = &LogEntry{Message: expected.Message, Buffer: LogBufferUnknown}
+			}
+			if !strings.Contains(text, ":00.") && !strings.Contains(text, "1585699200.") {
+				expected.Time = time.Time{}
+			} else if !modifiers.NSec {
+				expected.Time = expected.Time.Truncate(time.Millisecond)
+				if modifiers.USec {
+					expected.Time = testLogEntry().Time.Truncate(time.Microsecond)
+				}
+			}
+			if format == LogFormatTag || format == LogFormatRaw {
+				expected.PID = 0
+			}
+			if format == LogFormatThread {
+				expected.Tag = ""
+			}
+			if format != LogFormatThread && format != LogFormatThreadTime && format != LogFormatLong {
+				expected.TID = 0
+			}
+			if !modifiers.UID || format == LogFormatTag || format == LogFormatRaw {
+				expected.UID = 0
+			}
+			assert.True(t, expected.Time.Equal(entry.Time), "%s: %s", text, entry.Time)
+			entry.Time = expected.Time
+			assert.Equal(t, expected, entry, text)
+
+			_, err = r.Next()
+			assert.Equal(t, io.EOF, err)
+		}
+	}
+}
+
+func TestLogTextReader(t *testing.T) {
+	text := "--------- beginning of main\n" +
+		"04-01 09:00:00.123  1234  1240 I ActivityManager: first\n" +
+		"garbage\n" +
+		"--------- switch to system\n" +
+		"04-01 09:00:01.000  1234  1240 W Tag     : \n"
+	r := NewLogTextReader(strings.NewReader(text), LogTextFormat{Format: LogFormatThreadTime, Location: time.UTC})
+	r.now = func() time.Time { return time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	entry, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, LogBufferMain, entry.Buffer)
+	assert.Equal(t, "first", entry.Message)
+	// Times without year are in the last year.
+	assert.Equal(t, time.Date(2020, 4, 1, 9, 0, 0, 123000000, time.UTC), entry.Time)
+
+	_, err = r.Next()
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+
+	entry, err = r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, LogBufferSystem, entry.Buffer)
+	assert.Equal(t, "Tag", entry.Tag)
+	assert.Equal(t, "", entry.Message)
+	assert.Equal(t, LogWarn, entry.Priority)
+
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestLogTextReaderReadError(t *testing.T) {
+	text := "04-01 09:00:00.123  1234  1240 I ActivityManager: first\n"
+	r := NewLogTextReader(io.MultiReader(strings.NewReader(text), iotest.ErrReader(stderrors.New("broken pipe"))),
+		LogTextFormat{Format: LogFormatThreadTime})
+
+	_, err := r.Next()
+	require.NoError(t, err)
+	// The error isn't a ParseError, which would be skipped forever.
+	for i := 0; i < 2; i++ {
+		_, err = r.Next()
+		assert.Equal(t, errors.NetworkError, err.(*errors.Err).Code)
+	}
+
+	r = NewLogTextReader(strings.NewReader(strings.Repeat("a", 2*1024*1024)), LogTextFormat{Format: LogFormatRaw})
+	_, err = r.Next()
+	assert.Equal(t, errors.NetworkError, err.(*errors.Err).Code)
+}
+
+func TestLogTextReaderLong(t *testing.T) {
+	text := "--------- beginning of crash\n" +
+		"[ 04-01 09:00:00.123  1234: 1240 E/AndroidRuntime ]\n" +
+		"FATAL EXCEPTION: main\n" +
+		"\n" +
+		"java.lang.RuntimeException\n" +
+		"\n" +
+		"[ 04-01 09:00:01.000  1234: 1240 I/Process ]\n" +
+		"Sending signal\n" +
+		"\n"
+	r := NewLogTextReader(strings.NewReader(text), LogTextFormat{Format: LogFormatLong})
+
+	entry, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "AndroidRuntime", entry.Tag)
+	assert.Equal(t, LogError, entry.Priority)
+	assert.Equal(t, "FATAL EXCEPTION: main\n\njava.lang.RuntimeException", entry.Message)
+	assert.Equal(t, LogBufferCrash, entry.Buffer)
+
+	entry, err = r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "Sending signal", entry.Message)
+
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,545 @@
+package adb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+// Types of the H.264 NAL units MP4Writer handles.
+const (
+	nalSlice    = 1
+	nalSliceIDR = 5
+	nalSEI      = 6
+	nalSPS      = 7
+	nalPPS      = 8
+	nalAUD      = 9
+)
+
+// mp4Timescale is the number of ticks per second of the timestamps of the samples.
+const mp4Timescale = 90000
+
+// mp4DefaultFrameDuration is the duration of the last frame when it can't be guessed.
+const mp4DefaultFrameDuration = mp4Timescale / 30
+
+type mp4Sample struct {
+	size uint32
+	time int64
+	sync bool
+}
+
+/*
+MP4Writer wraps an H.264 stream in Annex B format, e.g. written by Device.ScreenRecord, into an
+MP4 file so it can be played by most players. Frames are written to the file as they're
+received, and the index of the file is written by Close.
+
+The stream has no timestamps. screenrecord only sends a frame when the screen changes, so by
+default frames are timestamped when they're written to the MP4Writer, which is right while
+recording. Streams that were saved first need a fixed frame rate instead.
+*/
+type MP4Writer struct {
+	w io.WriteSeeker
+
+	// frameDuration is the fixed duration of frames in ticks, or 0 to use their arrival time.
+	frameDuration float64
+
+	// pending is the NAL unit being received, without its start code. started is false until
+	// the first start code is received, and scanned is how much of pending was searched for
+	// the next start code.
+	pending []byte
+	started bool
+	scanned int
+	nalTime time.Time
+
+	sps, pps []byte
+
+	// sample is the access unit being assembled, as length-prefixed NAL units.
+	sample         bytes.Buffer
+	sampleTime     time.Time
+	sampleHasSlice bool
+	sampleSync     bool
+
+	samples   []mp4Sample
+	firstTime time.Time
+
+	mdatStart int64
+	mdatSize  int64
+	err       error
+}
+
+// NewMP4Writer returns an MP4Writer that writes to w, which is usually an *os.File.
+// If frameRate is 0, frames are timestamped when they're received, otherwise they're spaced
+// evenly at that many frames per second.
+func NewMP4Writer(w io.WriteSeeker, frameRate float64) *MP4Writer {
+	m := &MP4Writer{w: w}
+	if frameRate > 0 {
+		m.frameDuration = mp4Timescale / frameRate
+	}
+	return m
+}
+
+// Write writes a part of the H.264 stream, which doesn't need to be split at NAL units.
+func (m *MP4Writer) Write(p []byte) (int, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	if m.mdatStart == 0 {
+		m.err = m.writeHeader()
+		if m.err != nil {
+			return 0, m.err
+		}
+	}
+
+	now := time.Now()
+	if !m.started {
+		// The bytes before the first NAL unit aren't part of the stream.
+		m.pending = append(m.pending, p...)
+		i := bytes.Index(m.pending, []byte{0, 0, 1})
+		if i < 0 {
+			if len(m.pending) > 2 {
+				m.pending = m.pending[len(m.pending)-2:]
+			}
+			return len(p), nil
+		}
+		m.pending = append([]byte{}, m.pending[i+3:]...)
+		m.started = true
+		m.nalTime = now
+	} else {
+		m.pending = append(m.pending, p...)
+	}
+
+	for {
+		i := bytes.Index(m.pending[m.scanned:], []byte{0, 0, 1})
+		if i < 0 {
+			m.scanned = 0
+			if len(m.pending) > 2 {
+				m.scanned = len(m.pending) - 2
+			}
+			return len(p), nil
+		}
+		i += m.scanned
+		if m.err = m.handleNAL(m.pending[:i], m.nalTime); m.err != nil {
+			return 0, m.err
+		}
+		m.pending = append(m.pending[:0], m.pending[i+3:]...)
+		m.scanned = 0
+		m.nalTime = now
+	}
+}
+
+/*
+Close writes the rest of the stream and the index of the MP4 file, but doesn't close the
+underlying writer. Returns a ParseError if the stream has no video, or doesn't describe it
+with an SPS and a PPS.
+*/
+func (m *MP4Writer) Close() error {
+	if m.err != nil {
+		return m.err
+	}
+	if m.started {
+		if m.err = m.handleNAL(m.pending, m.nalTime); m.err != nil {
+			return m.err
+		}
+		m.pending = nil
+		m.started = false
+	}
+	if m.err = m.finishSample(); m.err != nil {
+		return m.err
+	}
+	if len(m.samples) == 0 || m.sps == nil || m.pps == nil {
+		m.err = errors.Errorf(errors.ParseError, "H.264 stream has no video")
+		return m.err
+	}
+	width, height, err := parseSPSSize(m.sps)
+	if err != nil {
+		m.err = err
+		return err
+	}
+
+	// The size of mdat is only known now.
+	if _, err := m.w.Seek(m.mdatStart+8, io.SeekStart); err != nil {
+		m.err = wrapLocalErr(err, "error writing MP4 file")
+		return m.err
+	}
+	if err := binary.Write(m.w, binary.BigEndian, uint64(16+m.mdatSize)); err != nil {
+		m.err = wrapLocalErr(err, "error writing MP4 file")
+		return m.err
+	}
+	if _, err := m.w.Seek(0, io.SeekEnd); err != nil {
+		m.err = wrapLocalErr(err, "error writing MP4 file")
+		return m.err
+	}
+	if _, err := m.w.Write(m.moov(width, height)); err != nil {
+		m.err = wrapLocalErr(err, "error writing MP4 file")
+		return m.err
+	}
+	m.err = errors.AssertionErrorf("MP4Writer is closed")
+	return nil
+}
+
+func (m *MP4Writer) writeHeader() error {
+	start, err := m.w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return wrapLocalErr(err, "error writing MP4 file")
+	}
+	ftyp := mp4Box("ftyp", []byte("isom"), be32(0x200), []byte("isomiso2avc1mp41"))
+	// mdat has a 64-bit size, which is set by Close.
+	mdat := append(be32(1), "mdat\x00\x00\x00\x00\x00\x00\x00\x00"...)
+	if _, err := m.w.Write(append(ftyp, mdat...)); err != nil {
+		return wrapLocalErr(err, "error writing MP4 file")
+	}
+	m.mdatStart = start + int64(len(ftyp))
+	return nil
+}
+
+// handleNAL adds a NAL unit received at t to the samples. Access units are delimited like in
+// H.264 Annex B.1.2: by AUDs, parameter sets, SEI, or the first slice of a new picture.
+func (m *MP4Writer) handleNAL(nal []byte, t time.Time) error {
+	// A 4-byte start code leaves a zero at the end of the previous unit.
+	nal = bytes.TrimRight(nal, "\x00")
+	if len(nal) == 0 {
+		return nil
+	}
+	typ := nal[0] & 0x1f
+	firstSlice := (typ == nalSlice || typ == nalSliceIDR) && len(nal) > 1 && nal[1]&0x80 != 0
+	if typ == nalAUD || typ == nalSPS || typ == nalPPS || typ == nalSEI || firstSlice {
+		if m.sampleHasSlice {
+			if err := m.finishSample(); err != nil {
+				return err
+			}
+		}
+	}
+	if m.sample.Len() == 0 && m.sampleTime.IsZero() {
+		m.sampleTime = t
+	}
+
+	switch typ {
+	case nalSPS:
+		// Parameter sets go in the sample description, the first ones describe the stream.
+		if m.sps == nil {
+			m.sps = append([]byte{}, nal...)
+		}
+		return nil
+	case nalPPS:
+		if m.pps == nil {
+			m.pps = append([]byte{}, nal...)
+		}
+		return nil
+	case nalAUD:
+		return nil
+	case nalSlice, nalSliceIDR:
+		m.sampleHasSlice = true
+		m.sampleSync = m.sampleSync || typ == nalSliceIDR
+	}
+	m.sample.Write(be32(uint32(len(nal))))
+	m.sample.Write(nal)
+	return nil
+}
+
+func (m *MP4Writer) finishSample() error {
+	if !m.sampleHasSlice {
+		return nil
+	}
+	if _, err := m.w.Write(m.sample.Bytes()); err != nil {
+		return wrapLocalErr(err, "error writing MP4 file")
+	}
+
+	var t int64
+	if m.frameDuration > 0 {
+		t = int64(float64(len(m.samples)) * m.frameDuration)
+	} else {
+		if len(m.samples) == 0 {
+			m.firstTime = m.sampleTime
+		}
+		t = int64(m.sampleTime.Sub(m.firstTime) * mp4Timescale / time.Second)
+	}
+	m.samples = append(m.samples, mp4Sample{size: uint32(m.sample.Len()), time: t, sync: m.sampleSync})
+	m.mdatSize += int64(m.sample.Len())
+
+	m.sample.Reset()
+	m.sampleTime = time.Time{}
+	m.sampleHasSlice = false
+	m.sampleSync = false
+	return nil
+}
+
+// durations returns the duration of each sample in ticks.
+func (m *MP4Writer) durations() []uint32 {
+	durations := make([]uint32, len(m.samples))
+	for i := range m.samples {
+		var d int64
+		switch {
+		case i+1 < len(m.samples):
+			d = m.samples[i+1].time - m.samples[i].time
+		case m.frameDuration > 0:
+			d = int64(m.frameDuration)
+		case i > 0:
+			d = int64(durations[i-1])
+		default:
+			d = mp4DefaultFrameDuration
+		}
+		if d < 1 {
+			d = 1
+		}
+		durations[i] = uint32(d)
+	}
+	return durations
+}
+
+func (m *MP4Writer) moov(width, height int) []byte {
+	durations := m.durations()
+	var total int64
+	for _, d := range durations {
+		total += int64(d)
+	}
+	movieDuration := total * 1000 / mp4Timescale
+
+	// Sample times are stored as runs of equal durations.
+	var stts [][]byte
+	for i := 0; i < len(durations); {
+		j := i
+		for j < len(durations) && durations[j] == durations[i] {
+			j++
+		}
+		stts = append(stts, be32(uint32(j-i)), be32(durations[i]))
+		i = j
+	}
+	var stss, stsz [][]byte
+	for i, sample := range m.samples {
+		if sample.sync {
+			stss = append(stss, be32(uint32(i+1)))
+		}
+		stsz = append(stsz, be32(sample.size))
+	}
+
+	avcC := mp4Box("avcC",
+		[]byte{1, m.sps[1], m.sps[2], m.sps[3], 0xff, 0xe1},
+		be16(uint16(len(m.sps))), m.sps,
+		[]byte{1}, be16(uint16(len(m.pps))), m.pps)
+	avc1 := mp4Box("avc1",
+		make([]byte, 6), be16(1), // reserved, data reference index
+		make([]byte, 16), // pre-defined and reserved
+		be16(uint16(width)), be16(uint16(height)),
+		be32(0x00480000), be32(0x00480000), // 72 dpi
+		be32(0), be16(1), // reserved, frame count
+		make([]byte, 32), // compressor name
+		be16(0x18), be16(0xffff), // depth, pre-defined
+		avcC)
+
+	stbl := mp4Box("stbl",
+		mp4FullBox("stsd", 0, 0, be32(1), avc1),
+		mp4FullBox("stts", 0, 0, append([][]byte{be32(uint32(len(stts) / 2))}, stts...)...),
+		mp4FullBox("stss", 0, 0, append([][]byte{be32(uint32(len(stss)))}, stss...)...),
+		// All the samples are in a single chunk, at the start of mdat.
+		mp4FullBox("stsc", 0, 0, be32(1), be32(1), be32(uint32(len(m.samples))), be32(1)),
+		mp4FullBox("stsz", 0, 0, append([][]byte{be32(0), be32(uint32(len(m.samples)))}, stsz...)...),
+		mp4FullBox("co64", 0, 0, be32(1), be64(uint64(m.mdatStart+16))))
+	minf := mp4Box("minf",
+		mp4FullBox("vmhd", 0, 1, make([]byte, 8)),
+		mp4Box("dinf", mp4FullBox("dref", 0, 0, be32(1), mp4FullBox("url ", 0, 1))),
+		stbl)
+	mdia := mp4Box("mdia",
+		mp4FullBox("mdhd", 0, 0, be32(0), be32(0), be32(mp4Timescale), be32(uint32(total)),
+			be16(0x55c4), be16(0)), // "und" language
+		mp4FullBox("hdlr", 0, 0, be32(0), []byte("vide"), make([]byte, 12), []byte("VideoHandler\x00")),
+		minf)
+	tkhd := mp4FullBox("tkhd", 0, 3, // enabled, in movie
+		be32(0), be32(0), be32(1), be32(0), be32(uint32(movieDuration)),
+		make([]byte, 8), be16(0), be16(0), be16(0), be16(0), // reserved, layer, group, volume, reserved
+		mp4Matrix,
+		be32(uint32(width)<<16), be32(uint32(height)<<16))
+	mvhd := mp4FullBox("mvhd", 0, 0,
+		be32(0), be32(0), be32(1000), be32(uint32(movieDuration)),
+		be32(0x00010000), be16(0x0100), make([]byte, 10), // rate, volume, reserved
+		mp4Matrix,
+		make([]byte, 24), be32(2)) // pre-defined, next track id
+	return mp4Box("moov", mvhd, mp4Box("trak", tkhd, mdia))
+}
+
+// mp4Matrix is the identity transformation matrix.
+var mp4Matrix = bytes.Join([][]byte{
+	be32(0x00010000), be32(0), be32(0),
+	be32(0), be32(0x00010000), be32(0),
+	be32(0), be32(0), be32(0x40000000),
+}, nil)
+
+func mp4Box(typ string, payload ...[]byte) []byte {
+	data := bytes.Join(payload, nil)
+	return append(append(be32(uint32(8+len(data))), typ...), data...)
+}
+
+func mp4FullBox(typ string, version uint8, flags uint32, payload ...[]byte) []byte {
+	return mp4Box(typ, append([][]byte{be32(uint32(version)<<24 | flags)}, payload...)...)
+}
+
+func be16(v uint16) []byte {
+	return []byte{byte(v >> 8), byte(v)}
+}
+
+func be32(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+func be64(v uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, v)
+}
+
+// bitReader reads the bits of an H.264 RBSP.
+type bitReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *bitReader) bit() uint32 {
+	if r.pos >= len(r.data)*8 {
+		r.err = errors.Errorf(errors.ParseError, "truncated SPS")
+		return 0
+	}
+	bit := uint32(r.data[r.pos/8]>>(7-uint(r.pos%8))) & 1
+	r.pos++
+	return bit
+}
+
+func (r *bitReader) bits(n int) uint32 {
+	var v uint32
+	for i := 0; i < n; i++ {
+		v = v<<1 | r.bit()
+	}
+	return v
+}
+
+// ue reads an unsigned Exp-Golomb code.
+func (r *bitReader) ue() uint32 {
+	zeros := 0
+	for r.bit() == 0 && r.err == nil {
+		zeros++
+		if zeros > 31 {
+			r.err = errors.Errorf(errors.ParseError, "invalid SPS")
+			return 0
+		}
+	}
+	return 1<<uint(zeros) - 1 + r.bits(zeros)
+}
+
+// se reads a signed Exp-Golomb code.
+func (r *bitReader) se() int32 {
+	v := r.ue()
+	if v%2 == 1 {
+		return int32(v/2 + 1)
+	}
+	return -int32(v / 2)
+}
+
+// parseSPSSize returns the size of the pictures described by an SPS NAL unit, as decoded after
+// cropping.
+func parseSPSSize(nal []byte) (width, height int, err error) {
+	// Remove the emulation prevention bytes, the 3 of each 00 00 03.
+	var rbsp []byte
+	zeros := 0
+	for _, b := range nal[1:] {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+		rbsp = append(rbsp, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	r := &bitReader{data: rbsp}
+
+	profile := r.bits(8)
+	r.bits(16) // constraint flags, level
+	r.ue()     // seq_parameter_set_id
+	chromaFormat := uint32(1)
+	separateColourPlanes := false
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chromaFormat = r.ue()
+		if chromaFormat == 3 {
+			separateColourPlanes = r.bit() == 1
+		}
+		r.ue()  // bit_depth_luma_minus8
+		r.ue()  // bit_depth_chroma_minus8
+		r.bit() // qpprime_y_zero_transform_bypass_flag
+		if r.bit() == 1 {
+			lists := 8
+			if chromaFormat == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if r.bit() == 1 {
+					size := 16
+					if i >= 6 {
+						size = 64
+					}
+					skipScalingList(r, size)
+				}
+			}
+		}
+	}
+	r.ue() // log2_max_frame_num_minus4
+	switch r.ue() {
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.bit() // delta_pic_order_always_zero_flag
+		r.se()  // offset_for_non_ref_pic
+		r.se()  // offset_for_top_to_bottom_field
+		n := r.ue()
+		for i := uint32(0); i < n && r.err == nil; i++ {
+			r.se()
+		}
+	}
+	r.ue()  // max_num_ref_frames
+	r.bit() // gaps_in_frame_num_value_allowed_flag
+	widthInMbs := int(r.ue()) + 1
+	heightInMapUnits := int(r.ue()) + 1
+	frameMbsOnly := int(r.bit())
+	if frameMbsOnly == 0 {
+		r.bit() // mb_adaptive_frame_field_flag
+	}
+	r.bit() // direct_8x8_inference_flag
+
+	width = widthInMbs * 16
+	height = (2 - frameMbsOnly) * heightInMapUnits * 16
+	if r.bit() == 1 {
+		left, right, top, bottom := int(r.ue()), int(r.ue()), int(r.ue()), int(r.ue())
+		cropX, cropY := 1, 2-frameMbsOnly
+		if chromaFormat != 0 && !separateColourPlanes {
+			if chromaFormat < 3 {
+				cropX = 2
+			}
+			if chromaFormat == 1 {
+				cropY *= 2
+			}
+		}
+		width -= cropX * (left + right)
+		height -= cropY * (top + bottom)
+	}
+	if r.err != nil {
+		return 0, 0, r.err
+	}
+	if width <= 0 || height <= 0 {
+		return 0, 0, errors.Errorf(errors.ParseError, "invalid SPS picture size: %dx%d", width, height)
+	}
+	return width, height, nil
+}
+
+func skipScalingList(r *bitReader, size int) {
+	last, next := int32(8), int32(8)
+	for j := 0; j < size && r.err == nil; j++ {
+		if next != 0 {
+			next = (last + r.se() + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
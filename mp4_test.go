@@ -0,0 +1,122 @@
+package adb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSPS is the SPS of a 1920x1080 stream encoded by x264, whose pictures are cropped from
+// 1920x1088.
+var testSPS, _ = hex.DecodeString("67640028acd940780227e5c044000003000400000300f03c60c658")
+
+// findMP4Box returns the payload of the box at path, e.g. "moov", "trak".
+func findMP4Box(data []byte, path ...string) []byte {
+	for len(data) >= 8 {
+		size := int(binary.BigEndian.Uint32(data))
+		header := 8
+		if size == 1 {
+			size, header = int(binary.BigEndian.Uint64(data[8:])), 16
+		}
+		if string(data[4:8]) == path[0] {
+			if len(path) == 1 {
+				return data[header:size]
+			}
+			return findMP4Box(data[header:size], path[1:]...)
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+func TestParseSPSSize(t *testing.T) {
+	width, height, err := parseSPSSize(testSPS)
+	assert.NoError(t, err)
+	assert.Equal(t, 1920, width)
+	assert.Equal(t, 1080, height)
+
+	_, _, err = parseSPSSize(testSPS[:6])
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+}
+
+func TestMP4Writer(t *testing.T) {
+	idr := []byte{0x65, 0x88, 0x84, 0x00, 0x21}
+	slice := []byte{0x41, 0x9a, 0x02, 0x03}
+	var stream []byte
+	for _, nal := range [][]byte{{0x09, 0xf0}, testSPS, {0x68, 0xeb, 0xe3, 0xcb, 0x22, 0xc0}, {0x06, 0x05, 0x01}, idr, slice, slice} {
+		// Both 3 and 4-byte start codes are used.
+		if len(nal)%2 == 0 {
+			stream = append(stream, 0)
+		}
+		stream = append(append(stream, 0, 0, 1), nal...)
+	}
+
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	m := NewMP4Writer(f, 30)
+	// The stream may be split anywhere.
+	stream = append([]byte("garbage"), stream...)
+	for i := range stream {
+		_, err := m.Write(stream[i : i+1])
+		require.NoError(t, err)
+	}
+	require.NoError(t, m.Close())
+	require.NoError(t, f.Close())
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	var types []string
+	for rest := data; len(rest) >= 8; {
+		types = append(types, string(rest[4:8]))
+		size := int(binary.BigEndian.Uint32(rest))
+		if size == 1 {
+			size = int(binary.BigEndian.Uint64(rest[8:]))
+		}
+		rest = rest[size:]
+	}
+	assert.Equal(t, []string{"ftyp", "mdat", "moov"}, types)
+
+	// The SEI and the IDR slice are the first sample, each slice is a sample.
+	stbl := findMP4Box(data, "moov", "trak", "mdia", "minf", "stbl")
+	firstSize := 4 + 3 + 4 + len(idr)
+	assert.Equal(t, append([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 3},
+		append(be32(uint32(firstSize)), append(be32(8), be32(8)...)...)...), findMP4Box(stbl, "stsz"))
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 3, 0, 0, 0x0b, 0xb8}, findMP4Box(stbl, "stts"))
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 1}, findMP4Box(stbl, "stss"))
+
+	offset := binary.BigEndian.Uint64(findMP4Box(stbl, "co64")[8:])
+	mdat := findMP4Box(data, "mdat")
+	assert.Equal(t, uint64(len(data)-len(findMP4Box(data, "moov"))-8-len(mdat)), offset)
+	assert.Equal(t, append(append(be32(3), 0x06, 0x05, 0x01), be32(uint32(len(idr)))...), mdat[:11])
+
+	avc1 := findMP4Box(stbl, "stsd")[8:]
+	assert.Equal(t, []byte{0x07, 0x80, 0x04, 0x38}, avc1[8+24:8+28])
+	avcC := findMP4Box(avc1[8+78:], "avcC")
+	assert.Equal(t, testSPS, avcC[8:8+len(testSPS)])
+}
+
+func TestMP4WriterTimestamps(t *testing.T) {
+	m := &MP4Writer{samples: []mp4Sample{{time: 0}, {time: 1000}, {time: 1000}, {time: 4000}}}
+	assert.Equal(t, []uint32{1000, 1, 3000, 3000}, m.durations())
+}
+
+func TestMP4WriterNoVideo(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "video.mp4"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	m := NewMP4Writer(f, 0)
+	_, err = m.Write(bytes.Repeat([]byte{0, 0, 1, 0x09, 0xf0}, 3))
+	require.NoError(t, err)
+	err = m.Close()
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+}
@@ -0,0 +1,454 @@
+package adb
+
+import (
+	"bufio"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kvnxiao/go-adb/apk"
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+// PackageInfo describes an installed package, as reported by dumpsys package.
+// Fields that the device's version of Android doesn't report are left empty.
+type PackageInfo struct {
+	Name    string
+	Path    string
+	Version struct {
+		Code int
+		Name string
+	}
+
+	UID       int
+	MinSdk    int
+	TargetSdk int
+
+	// Times are in the time zone of the device, which dumpsys doesn't report, so they're
+	// returned as UTC.
+	FirstInstallTime time.Time
+	LastUpdateTime   time.Time
+
+	// Installer is the package that installed this one, empty if unknown.
+	Installer string
+
+	// Flags and PrivateFlags are the ApplicationInfo flags, e.g. HAS_CODE or ALLOW_BACKUP.
+	Flags        []string
+	PrivateFlags []string
+
+	// SigningDigests are the short hashes of the signing certificates printed by dumpsys,
+	// which are enough to tell whether two installations have the same signers.
+	SigningDigests []string
+
+	// RequestedPermissions lists all the permissions in the manifest, runtime or not.
+	RequestedPermissions []string
+
+	// InstallPermissions are the permissions granted at install time to all users.
+	InstallPermissions []PermissionState
+
+	Users []PackageUserState
+}
+
+// PermissionState is whether a permission is granted to a package.
+type PermissionState struct {
+	Name    string
+	Granted bool
+
+	// Flags are the permission flags, e.g. USER_SET or USER_FIXED.
+	Flags []string
+}
+
+// PackageUserState is the state of a package for one user of the device.
+type PackageUserState struct {
+	User        int
+	Installed   bool
+	Hidden      bool
+	Suspended   bool
+	Stopped     bool
+	NotLaunched bool
+
+	// Enabled is the enabled setting of the package, 0 for the default from the manifest.
+	Enabled int
+
+	// FirstInstallTime is only reported per user by Android 12 and later.
+	FirstInstallTime time.Time
+
+	RuntimePermissions []PermissionState
+}
+
+// GrantedPermissions returns the names of the runtime permissions granted to user, followed by
+// the granted install permissions.
+func (pi *PackageInfo) GrantedPermissions(user int) []string {
+	var granted []string
+	for _, state := range pi.Users {
+		if state.User != user {
+			continue
+		}
+		for _, perm := range state.RuntimePermissions {
+			if perm.Granted {
+				granted = append(granted, perm.Name)
+			}
+		}
+	}
+	for _, perm := range pi.InstallPermissions {
+		if perm.Granted {
+			granted = append(granted, perm.Name)
+		}
+	}
+	return granted
+}
+
+// StatPackage returns PackageInfo
+// If package not found, err will be ErrPackageNotExist
+func (c *Device) StatPackage(packageName string) (pi PackageInfo, err error) {
+	out, err := c.RunCommandAsString("dumpsys", "package", packageName)
+	if err != nil {
+		return
+	}
+	return parsePackageInfo(packageName, out)
+}
+
+// APKInstallState is how the package of an APK is installed on a device, as returned by
+// Device.APKInstallState.
+type APKInstallState struct {
+	// Installed is true if the package is installed for at least one user.
+	Installed bool
+
+	// VersionCode and VersionName are the version of the installed package.
+	VersionCode int64
+	VersionName string
+
+	// SameVersion is true if the installed package has the versionCode of the APK, so
+	// installing the APK again can usually be skipped.
+	SameVersion bool
+}
+
+/*
+APKInstallState compares the manifest of an APK, e.g. from apk.Open, with the package installed
+on the device, to tell whether the device already has that exact versionCode:
+	m, err := apk.Open("app.apk")
+	state, err := device.APKInstallState(m)
+	if !state.SameVersion {
+		device.Install(...)
+	}
+*/
+func (c *Device) APKInstallState(m *apk.Manifest) (APKInstallState, error) {
+	return c.APKInstallStateContext(context.Background(), m)
+}
+
+// APKInstallStateContext is like APKInstallState, but gives up as soon as ctx is done.
+func (c *Device) APKInstallStateContext(ctx context.Context, m *apk.Manifest) (APKInstallState, error) {
+	var state APKInstallState
+	output, err := c.RunCommandAsStringContext(ctx, "dumpsys", "package", m.Package)
+	if err != nil {
+		return state, err
+	}
+	pi, err := parsePackageInfo(m.Package, output)
+	if err == ErrPackageNotExist {
+		return state, nil
+	}
+	if err != nil {
+		return state, wrapClientError(err, c, "APKInstallState(%s)", m.Package)
+	}
+
+	// Packages uninstalled with their data kept are still listed, but aren't installed for
+	// any user.
+	state.Installed = len(pi.Users) == 0
+	for _, user := range pi.Users {
+		state.Installed = state.Installed || user.Installed
+	}
+	if state.Installed {
+		state.VersionCode = int64(pi.Version.Code)
+		state.VersionName = pi.Version.Name
+		state.SameVersion = state.VersionCode == m.VersionCode
+	}
+	return state, nil
+}
+
+// dumpsysTimeLayout is the layout of the times printed by dumpsys package.
+const dumpsysTimeLayout = "2006-01-02 15:04:05"
+
+// dumpsysLine is a line of dumpsys output, split into its indentation and the rest.
+type dumpsysLine struct {
+	indent int
+	text   string
+}
+
+/*
+parsePackageInfo parses the section for packageName in the output of dumpsys package, which
+starts with
+	Packages:
+	  Package [com.example.app] (3f1b2c1):
+and contains a line per field, plus indented blocks for permissions and the state of each user.
+Returns ErrPackageNotExist if there is no such section.
+*/
+func parsePackageInfo(packageName, output string) (pi PackageInfo, err error) {
+	lines, ok := findPackageSection(packageName, output)
+	if !ok {
+		return pi, ErrPackageNotExist
+	}
+	pi.Name = packageName
+
+	// The indentation of the fields of the package, anything deeper belongs to the last field.
+	fieldIndent := -1
+	var block string
+	var user *PackageUserState
+	var userIndent int
+
+	for _, line := range lines {
+		if fieldIndent < 0 {
+			fieldIndent = line.indent
+		}
+
+		if line.indent == fieldIndent {
+			block, user = "", nil
+			if err = pi.parseField(line.text); err != nil {
+				return
+			}
+			switch {
+			case line.text == "requested permissions:", line.text == "install permissions:":
+				block = line.text
+			case strings.HasPrefix(line.text, "User "):
+				var state PackageUserState
+				if state, err = parseUserState(line.text); err != nil {
+					return
+				}
+				pi.Users = append(pi.Users, state)
+				user, userIndent = &pi.Users[len(pi.Users)-1], -1
+			}
+			continue
+		}
+
+		switch {
+		case block == "requested permissions:":
+			// Newer versions append attributes, e.g. ": restricted=true".
+			name := strings.SplitN(line.text, ":", 2)[0]
+			pi.RequestedPermissions = append(pi.RequestedPermissions, name)
+		case block == "install permissions:":
+			pi.InstallPermissions = append(pi.InstallPermissions, parsePermissionState(line.text))
+		case user != nil:
+			if userIndent < 0 {
+				userIndent = line.indent
+			}
+			if line.indent == userIndent {
+				block = ""
+				if line.text == "runtime permissions:" {
+					block = "runtime permissions:"
+				} else if strings.HasPrefix(line.text, "firstInstallTime=") {
+					if user.FirstInstallTime, err = parseDumpsysTime(line.text); err != nil {
+						return
+					}
+				}
+			} else if block == "runtime permissions:" {
+				user.RuntimePermissions = append(user.RuntimePermissions, parsePermissionState(line.text))
+			}
+		}
+	}
+
+	// Android 12 and later only report the first install time per user.
+	if pi.FirstInstallTime.IsZero() {
+		for _, state := range pi.Users {
+			if !state.FirstInstallTime.IsZero() &&
+				(pi.FirstInstallTime.IsZero() || state.FirstInstallTime.Before(pi.FirstInstallTime)) {
+				pi.FirstInstallTime = state.FirstInstallTime
+			}
+		}
+	}
+	return pi, nil
+}
+
+// findPackageSection returns the lines of the section for packageName in the Packages block,
+// without the line that starts it. Sections for the same package in other blocks, e.g. for the
+// version in the system image of an updated system app, are ignored.
+func findPackageSection(packageName, output string) ([]dumpsysLine, bool) {
+	header := "Package [" + packageName + "]"
+	var lines []dumpsysLine
+	inPackages, inSection := false, false
+	sectionIndent := 0
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		raw := strings.TrimRight(scanner.Text(), " \r")
+		text := strings.TrimLeft(raw, " ")
+		if text == "" {
+			if inSection {
+				break
+			}
+			continue
+		}
+		indent := len(raw) - len(text)
+
+		switch {
+		case inSection:
+			if indent <= sectionIndent {
+				return lines, true
+			}
+			lines = append(lines, dumpsysLine{indent, text})
+		case indent == 0:
+			inPackages = text == "Packages:"
+		case inPackages && strings.HasPrefix(text, header):
+			inSection, sectionIndent = true, indent
+		}
+	}
+	return lines, inSection
+}
+
+// parseField sets the field of pi on a line of the package section, if it's one PackageInfo has.
+func (pi *PackageInfo) parseField(text string) (err error) {
+	key, value := text, ""
+	if i := strings.Index(text, "="); i >= 0 {
+		key, value = text[:i], text[i+1:]
+	}
+
+	switch key {
+	case "userId", "appId":
+		pi.UID, err = parseDumpsysInt(key, value)
+	case "codePath":
+		pi.Path = value
+	case "versionCode":
+		// e.g. versionCode=42 minSdk=24 targetSdk=29
+		for _, field := range strings.Fields(text) {
+			parts := strings.SplitN(field, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			var n int
+			if n, err = parseDumpsysInt(parts[0], parts[1]); err != nil {
+				return
+			}
+			switch parts[0] {
+			case "versionCode":
+				pi.Version.Code = n
+			case "minSdk":
+				pi.MinSdk = n
+			case "targetSdk":
+				pi.TargetSdk = n
+			}
+		}
+	case "versionName":
+		pi.Version.Name = value
+	case "firstInstallTime":
+		pi.FirstInstallTime, err = parseDumpsysTime(text)
+	case "lastUpdateTime":
+		pi.LastUpdateTime, err = parseDumpsysTime(text)
+	case "installerPackageName":
+		if value != "null" {
+			pi.Installer = value
+		}
+	case "flags":
+		pi.Flags = parseDumpsysList(value)
+	case "privateFlags":
+		pi.PrivateFlags = parseDumpsysList(value)
+	case "signatures":
+		pi.SigningDigests = parseSignatures(value)
+	}
+	return
+}
+
+// parseUserState parses a line like
+//	User 0: ceDataInode=12345 installed=true hidden=false stopped=false notLaunched=false enabled=0
+func parseUserState(text string) (state PackageUserState, err error) {
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		return state, errors.Errorf(errors.ParseError, "invalid user state: %s", text)
+	}
+	if state.User, err = parseDumpsysInt("user", strings.TrimSuffix(fields[1], ":")); err != nil {
+		return
+	}
+
+	for _, field := range fields[2:] {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "installed":
+			state.Installed = parts[1] == "true"
+		case "hidden":
+			state.Hidden = parts[1] == "true"
+		case "suspended":
+			state.Suspended = parts[1] == "true"
+		case "stopped":
+			state.Stopped = parts[1] == "true"
+		case "notLaunched":
+			state.NotLaunched = parts[1] == "true"
+		case "enabled":
+			if state.Enabled, err = parseDumpsysInt("enabled", parts[1]); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// parsePermissionState parses a line like
+//	android.permission.CAMERA: granted=true, flags=[ USER_SET|USER_SENSITIVE_WHEN_GRANTED ]
+func parsePermissionState(text string) PermissionState {
+	parts := strings.SplitN(text, ":", 2)
+	state := PermissionState{Name: parts[0]}
+	if len(parts) < 2 {
+		return state
+	}
+	attrs := parts[1]
+	state.Granted = strings.Contains(attrs, "granted=true")
+	if i := strings.Index(attrs, "flags=["); i >= 0 {
+		flags := strings.Trim(strings.TrimSuffix(strings.TrimSpace(attrs[i+len("flags=["):]), "]"), " ")
+		for _, flag := range strings.Split(flags, "|") {
+			if flag = strings.TrimSpace(flag); flag != "" {
+				state.Flags = append(state.Flags, flag)
+			}
+		}
+	}
+	return state
+}
+
+// parseSignatures parses the value of the signatures field, which is either
+//	PackageSignatures{5e6f7a8 [1b2c3d4e]}
+// or, since Android 9,
+//	PackageSignatures{1b2c3d4 version:2, signatures:[a1b2c3d4], past signatures:[]}
+func parseSignatures(value string) []string {
+	start := strings.Index(value, "signatures:[")
+	if start >= 0 {
+		start += len("signatures:[")
+	} else if start = strings.Index(value, "["); start >= 0 {
+		start++
+	} else {
+		return nil
+	}
+	end := strings.Index(value[start:], "]")
+	if end < 0 {
+		return nil
+	}
+
+	var digests []string
+	for _, digest := range strings.Split(value[start:start+end], ",") {
+		if digest = strings.TrimSpace(digest); digest != "" {
+			digests = append(digests, digest)
+		}
+	}
+	return digests
+}
+
+// parseDumpsysList parses a list of flags like "[ HAS_CODE ALLOW_BACKUP ]".
+func parseDumpsysList(value string) []string {
+	return strings.Fields(strings.Trim(value, "[]"))
+}
+
+func parseDumpsysInt(name, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.WrapErrorf(err, errors.ParseError, "invalid %s: %s", name, value)
+	}
+	return n, nil
+}
+
+// parseDumpsysTime parses a line like "firstInstallTime=2020-04-01 09:00:00".
+func parseDumpsysTime(text string) (time.Time, error) {
+	value := text[strings.Index(text, "=")+1:]
+	t, err := time.Parse(dumpsysTimeLayout, value)
+	if err != nil {
+		return time.Time{}, errors.WrapErrorf(err, errors.ParseError, "invalid time: %s", text)
+	}
+	return t, nil
+}
@@ -0,0 +1,167 @@
+package adb
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kvnxiao/go-adb/apk"
+	"github.com/kvnxiao/go-adb/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readDumpsysFixture(t *testing.T, name string) string {
+	data, err := ioutil.ReadFile("testdata/" + name)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestParsePackageInfoAndroid6(t *testing.T) {
+	pi, err := parsePackageInfo("com.example.app", readDumpsysFixture(t, "dumpsys_package_android6.txt"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "com.example.app", pi.Name)
+	assert.Equal(t, "/data/app/com.example.app-1", pi.Path)
+	assert.Equal(t, 12, pi.Version.Code)
+	assert.Equal(t, "1.2", pi.Version.Name)
+	assert.Equal(t, 10061, pi.UID)
+	assert.Equal(t, 0, pi.MinSdk)
+	assert.Equal(t, 23, pi.TargetSdk)
+	assert.Equal(t, time.Date(2016, 3, 1, 12, 0, 1, 0, time.UTC), pi.FirstInstallTime)
+	assert.Equal(t, time.Date(2016, 3, 2, 8, 30, 0, 0, time.UTC), pi.LastUpdateTime)
+	assert.Equal(t, "com.android.vending", pi.Installer)
+	assert.Equal(t, []string{"HAS_CODE", "ALLOW_CLEAR_USER_DATA", "ALLOW_BACKUP"}, pi.Flags)
+	assert.Empty(t, pi.PrivateFlags)
+	assert.Equal(t, []string{"1b2c3d4e"}, pi.SigningDigests)
+	assert.Equal(t, []string{"android.permission.INTERNET", "android.permission.CAMERA", "android.permission.READ_CONTACTS"},
+		pi.RequestedPermissions)
+	assert.Equal(t, []PermissionState{{Name: "android.permission.INTERNET", Granted: true}}, pi.InstallPermissions)
+	assert.Equal(t, []PackageUserState{{
+		User:      0,
+		Installed: true,
+		RuntimePermissions: []PermissionState{
+			{Name: "android.permission.CAMERA", Granted: true},
+		},
+	}}, pi.Users)
+	assert.Equal(t, []string{"android.permission.CAMERA", "android.permission.INTERNET"}, pi.GrantedPermissions(0))
+}
+
+func TestParsePackageInfoAndroid10(t *testing.T) {
+	pi, err := parsePackageInfo("com.example.app", readDumpsysFixture(t, "dumpsys_package_android10.txt"))
+	require.NoError(t, err)
+
+	// The section under Hidden system packages must be ignored.
+	assert.Equal(t, "/data/app/com.example.app-9QaZ1w==", pi.Path)
+	assert.Equal(t, 42, pi.Version.Code)
+	assert.Equal(t, "1.4.2", pi.Version.Name)
+	assert.Equal(t, 10123, pi.UID)
+	assert.Equal(t, 24, pi.MinSdk)
+	assert.Equal(t, 29, pi.TargetSdk)
+	assert.Equal(t, time.Date(2020, 4, 1, 9, 0, 0, 0, time.UTC), pi.FirstInstallTime)
+	assert.Equal(t, []string{"HAS_CODE", "ALLOW_CLEAR_USER_DATA", "ALLOW_BACKUP", "LARGE_HEAP"}, pi.Flags)
+	assert.Equal(t, []string{"PRIVATE_FLAG_ACTIVITIES_RESIZE_MODE_RESIZEABLE_VIA_SDK_VERSION", "ALLOW_AUDIO_PLAYBACK_CAPTURE"},
+		pi.PrivateFlags)
+	assert.Equal(t, []string{"a1b2c3d4"}, pi.SigningDigests)
+	assert.Equal(t, []string{
+		"android.permission.INTERNET",
+		"android.permission.CAMERA",
+		"android.permission.ACCESS_FINE_LOCATION",
+		"android.permission.WRITE_EXTERNAL_STORAGE",
+	}, pi.RequestedPermissions)
+
+	require.Len(t, pi.Users, 2)
+	assert.Equal(t, []PermissionState{
+		{Name: "android.permission.CAMERA", Granted: true,
+			Flags: []string{"USER_SET", "USER_SENSITIVE_WHEN_GRANTED", "USER_SENSITIVE_WHEN_DENIED"}},
+		{Name: "android.permission.ACCESS_FINE_LOCATION",
+			Flags: []string{"USER_SENSITIVE_WHEN_GRANTED", "USER_SENSITIVE_WHEN_DENIED"}},
+		{Name: "android.permission.WRITE_EXTERNAL_STORAGE", Flags: []string{"RESTRICTION_INSTALLER_EXEMPT"}},
+	}, pi.Users[0].RuntimePermissions)
+	assert.Equal(t, PackageUserState{User: 10, Stopped: true, NotLaunched: true}, pi.Users[1])
+	assert.Equal(t, []string{"android.permission.INTERNET"}, pi.GrantedPermissions(10))
+}
+
+func TestParsePackageInfoAndroid13(t *testing.T) {
+	pi, err := parsePackageInfo("com.example.app", readDumpsysFixture(t, "dumpsys_package_android13.txt"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "/data/app/~~Kx3A_b==/com.example.app-9QaZ1w==", pi.Path)
+	assert.Equal(t, 1000042, pi.Version.Code)
+	assert.Equal(t, "2.0.0-beta (build 7)", pi.Version.Name)
+	assert.Equal(t, 10123, pi.UID)
+	assert.Equal(t, 26, pi.MinSdk)
+	assert.Equal(t, 33, pi.TargetSdk)
+	// Only reported per user.
+	assert.Equal(t, time.Date(2023, 4, 1, 9, 0, 0, 0, time.UTC), pi.FirstInstallTime)
+	assert.Equal(t, time.Date(2023, 5, 1, 10, 11, 13, 0, time.UTC), pi.LastUpdateTime)
+	assert.Equal(t, []string{"a1b2c3d4", "9f8e7d6c"}, pi.SigningDigests)
+	assert.Len(t, pi.RequestedPermissions, 4)
+	assert.Len(t, pi.InstallPermissions, 2)
+
+	require.Len(t, pi.Users, 1)
+	assert.Equal(t, pi.FirstInstallTime, pi.Users[0].FirstInstallTime)
+	assert.Len(t, pi.Users[0].RuntimePermissions, 2)
+	assert.Equal(t, []string{
+		"android.permission.POST_NOTIFICATIONS",
+		"android.permission.INTERNET",
+		"com.example.app.permission.C2D_MESSAGE",
+	}, pi.GrantedPermissions(0))
+}
+
+func TestParsePackageInfoNotExist(t *testing.T) {
+	_, err := parsePackageInfo("com.example.other", readDumpsysFixture(t, "dumpsys_package_android10.txt"))
+	assert.Equal(t, ErrPackageNotExist, err)
+
+	_, err = parsePackageInfo("com.example.app", "Unable to find package: com.example.app\n")
+	assert.Equal(t, ErrPackageNotExist, err)
+}
+
+func TestParseSignatures(t *testing.T) {
+	assert.Equal(t, []string{"1b2c3d4e"}, parseSignatures("PackageSignatures{5e6f7a8 [1b2c3d4e]}"))
+	assert.Equal(t, []string{"a1b2c3d4", "9f8e7d6c"},
+		parseSignatures("PackageSignatures{1b2c3d4 version:3, signatures:[a1b2c3d4, 9f8e7d6c], past signatures:[]}"))
+	assert.Empty(t, parseSignatures("PackageSignatures{1b2c3d4 version:0, signatures:[], past signatures:[]}"))
+	assert.Empty(t, parseSignatures("null"))
+}
+
+func TestAPKInstallState(t *testing.T) {
+	dumpsys := readDumpsysFixture(t, "dumpsys_package_android10.txt")
+	for _, test := range []struct {
+		manifest apk.Manifest
+		output   string
+		expected APKInstallState
+	}{
+		{
+			apk.Manifest{Package: "com.example.app", VersionCode: 42},
+			dumpsys,
+			APKInstallState{Installed: true, VersionCode: 42, VersionName: "1.4.2", SameVersion: true},
+		},
+		{
+			apk.Manifest{Package: "com.example.app", VersionCode: 43},
+			dumpsys,
+			APKInstallState{Installed: true, VersionCode: 42, VersionName: "1.4.2"},
+		},
+		{
+			apk.Manifest{Package: "com.example.app", VersionCode: 42},
+			strings.Replace(dumpsys, "installed=true", "installed=false", -1),
+			APKInstallState{},
+		},
+		{
+			apk.Manifest{Package: "com.example.other", VersionCode: 1},
+			dumpsys,
+			APKInstallState{},
+		},
+	} {
+		s := &MockServer{
+			Status:   wire.StatusSuccess,
+			Messages: []string{test.output},
+		}
+		client := (&Adb{s}).Device(AnyDevice())
+		state, err := client.APKInstallState(&test.manifest)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, state)
+		assert.Equal(t, "exec:dumpsys package "+test.manifest.Package, s.Requests[1])
+	}
+}
@@ -0,0 +1,211 @@
+package adb
+
+import (
+	"bufio"
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+// UninstallOptions configures Device.Uninstall.
+type UninstallOptions struct {
+	// KeepData keeps the data and cache directories of the app (-k).
+	KeepData bool
+
+	// User uninstalls the app only for a specific user, e.g. "0" or "current".
+	User string
+}
+
+/*
+Uninstall removes the package packageName from the device.
+
+If the package manager reports a failure, the returned error wraps an *InstallError, e.g. with
+the code DELETE_FAILED_INTERNAL_ERROR if the package isn't installed.
+*/
+func (c *Device) Uninstall(packageName string, opts UninstallOptions) error {
+	return c.UninstallContext(context.Background(), packageName, opts)
+}
+
+// UninstallContext is like Uninstall, but gives up as soon as ctx is done.
+func (c *Device) UninstallContext(ctx context.Context, packageName string, opts UninstallOptions) error {
+	args := []string{"uninstall"}
+	if opts.KeepData {
+		args = append(args, "-k")
+	}
+	if opts.User != "" {
+		args = append(args, "--user", opts.User)
+	}
+	output, err := c.runPackageCommand(ctx, nil, 0, append(args, packageName)...)
+	if err == nil {
+		err = parseInstallOutput(output)
+	}
+	return wrapClientError(err, c, "Uninstall(%s)", packageName)
+}
+
+// ClearPackageData deletes all the data of the package packageName, like clearing it from
+// the app's settings.
+func (c *Device) ClearPackageData(packageName string) error {
+	return c.ClearPackageDataContext(context.Background(), packageName)
+}
+
+// ClearPackageDataContext is like ClearPackageData, but gives up as soon as ctx is done.
+func (c *Device) ClearPackageDataContext(ctx context.Context, packageName string) error {
+	output, err := c.runPackageCommand(ctx, nil, 0, "clear", packageName)
+	if err == nil && strings.TrimSpace(output) != "Success" {
+		err = errors.Errorf(errors.AdbError, "error clearing data of %s: %s", packageName, strings.TrimSpace(output))
+	}
+	return wrapClientError(err, c, "ClearPackageData(%s)", packageName)
+}
+
+// PackageState filters packages by whether they're enabled, for PackageFilter.
+type PackageState int
+
+const (
+	PackageAnyState PackageState = iota
+	PackageEnabled
+	PackageDisabled
+)
+
+// PackageSource filters packages by whether they're part of the system image, for
+// PackageFilter.
+type PackageSource int
+
+const (
+	PackageAnySource PackageSource = iota
+	PackageSystem
+	PackageThirdParty
+)
+
+// PackageFilter selects the packages returned by Device.ListPackages.
+// The zero value selects all the packages.
+type PackageFilter struct {
+	State  PackageState
+	Source PackageSource
+
+	// Installer only selects the packages installed by the given package, e.g.
+	// "com.android.vending".
+	Installer string
+
+	// Contains only selects the packages whose name contains the given string.
+	Contains string
+
+	// User only selects the packages installed for the given user, e.g. "0".
+	User string
+}
+
+// args returns the arguments of pm list packages that select the packages, except for the
+// installer which it can't filter by.
+func (f PackageFilter) args() []string {
+	args := []string{"list", "packages", "-f", "-U", "-i"}
+	switch f.State {
+	case PackageEnabled:
+		args = append(args, "-e")
+	case PackageDisabled:
+		args = append(args, "-d")
+	}
+	switch f.Source {
+	case PackageSystem:
+		args = append(args, "-s")
+	case PackageThirdParty:
+		args = append(args, "-3")
+	}
+	if f.User != "" {
+		args = append(args, "--user", f.User)
+	}
+	if f.Contains != "" {
+		args = append(args, f.Contains)
+	}
+	return args
+}
+
+// InstalledPackage is a package returned by Device.ListPackages.
+type InstalledPackage struct {
+	Name string
+
+	// Path of the base APK of the package.
+	Path string
+
+	UID int
+
+	// Installer is the package that installed this one, empty if unknown, e.g. for system
+	// packages and packages installed with adb.
+	Installer string
+}
+
+// ListPackages returns the packages installed on the device that match filter, in the order
+// the package manager lists them.
+func (c *Device) ListPackages(filter PackageFilter) ([]InstalledPackage, error) {
+	return c.ListPackagesContext(context.Background(), filter)
+}
+
+// ListPackagesContext is like ListPackages, but gives up as soon as ctx is done.
+func (c *Device) ListPackagesContext(ctx context.Context, filter PackageFilter) ([]InstalledPackage, error) {
+	output, err := c.runPackageCommand(ctx, nil, 0, filter.args()...)
+	if err != nil {
+		return nil, wrapClientError(err, c, "ListPackages")
+	}
+	packages, err := parsePackageList(output)
+	if err != nil {
+		return nil, wrapClientError(err, c, "ListPackages")
+	}
+
+	if filter.Installer == "" {
+		return packages, nil
+	}
+	filtered := packages[:0]
+	for _, pkg := range packages {
+		if pkg.Installer == filter.Installer {
+			filtered = append(filtered, pkg)
+		}
+	}
+	return filtered, nil
+}
+
+/*
+parsePackageList parses the output of pm list packages -f -U -i, e.g.
+	package:/data/app/~~Kx3A==/com.example-9Qa==/base.apk=com.example uid:10123 installer=com.android.vending
+The path may contain '=', but the package name can't.
+*/
+func parsePackageList(output string) ([]InstalledPackage, error) {
+	var packages []InstalledPackage
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "package:") {
+			return nil, errors.Errorf(errors.ParseError, "invalid package list line: %s", line)
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(line, "package:"))
+		if len(fields) == 0 {
+			return nil, errors.Errorf(errors.ParseError, "invalid package list line: %s", line)
+		}
+		var pkg InstalledPackage
+		if i := strings.LastIndex(fields[0], "="); i >= 0 {
+			pkg.Path, pkg.Name = fields[0][:i], fields[0][i+1:]
+		} else {
+			pkg.Name = fields[0]
+		}
+
+		for _, field := range fields[1:] {
+			switch {
+			case strings.HasPrefix(field, "uid:"):
+				uid, err := strconv.Atoi(strings.TrimPrefix(field, "uid:"))
+				if err != nil {
+					return nil, errors.WrapErrorf(err, errors.ParseError, "invalid uid in package list line: %s", line)
+				}
+				pkg.UID = uid
+			case strings.HasPrefix(field, "installer="):
+				if installer := strings.TrimPrefix(field, "installer="); installer != "null" {
+					pkg.Installer = installer
+				}
+			}
+		}
+		packages = append(packages, pkg)
+	}
+	return packages, nil
+}
@@ -0,0 +1,101 @@
+package adb
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/kvnxiao/go-adb/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const packageListOutput = `package:/system/priv-app/Settings/Settings.apk=com.android.settings uid:1000 installer=null
+package:/data/app/~~Kx3A_b==/com.example.app-9QaZ1w==/base.apk=com.example.app uid:10123 installer=com.android.vending
+package:/data/app/~~7rHc==/com.example.debug-1A==/base.apk=com.example.debug uid:10124 installer=null
+`
+
+func TestParsePackageList(t *testing.T) {
+	packages, err := parsePackageList(packageListOutput)
+	assert.NoError(t, err)
+	assert.Equal(t, []InstalledPackage{
+		{Name: "com.android.settings", Path: "/system/priv-app/Settings/Settings.apk", UID: 1000},
+		{Name: "com.example.app", Path: "/data/app/~~Kx3A_b==/com.example.app-9QaZ1w==/base.apk", UID: 10123, Installer: "com.android.vending"},
+		{Name: "com.example.debug", Path: "/data/app/~~7rHc==/com.example.debug-1A==/base.apk", UID: 10124},
+	}, packages)
+}
+
+func TestParsePackageListWithoutPaths(t *testing.T) {
+	packages, err := parsePackageList("package:com.android.shell\n")
+	assert.NoError(t, err)
+	assert.Equal(t, []InstalledPackage{{Name: "com.android.shell"}}, packages)
+}
+
+func TestParsePackageListInvalid(t *testing.T) {
+	_, err := parsePackageList("Error: unknown option -U\n")
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+
+	_, err = parsePackageList("package:/a.apk=a uid:abc\n")
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+}
+
+func TestPackageFilterArgs(t *testing.T) {
+	assert.Equal(t, []string{"list", "packages", "-f", "-U", "-i"}, PackageFilter{}.args())
+	assert.Equal(t, []string{"list", "packages", "-f", "-U", "-i", "-d", "-3", "--user", "0", "example"}, PackageFilter{
+		State:     PackageDisabled,
+		Source:    PackageThirdParty,
+		Installer: "com.android.vending",
+		Contains:  "example",
+		User:      "0",
+	}.args())
+	assert.Equal(t, []string{"list", "packages", "-f", "-U", "-i", "-e", "-s"}, PackageFilter{
+		State:  PackageEnabled,
+		Source: PackageSystem,
+	}.args())
+}
+
+func TestListPackagesByInstaller(t *testing.T) {
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"cmd", packageListOutput},
+	}
+	client := (&Adb{s}).Device(DeviceWithSerial("abc"))
+
+	packages, err := client.ListPackages(PackageFilter{Installer: "com.android.vending"})
+	assert.NoError(t, err)
+	require.Len(t, packages, 1)
+	assert.Equal(t, "com.example.app", packages[0].Name)
+	assert.Equal(t, "exec:cmd package list packages -f -U -i", s.Requests[2])
+}
+
+func TestUninstall(t *testing.T) {
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"cmd", "Failure [DELETE_FAILED_INTERNAL_ERROR]\n"},
+	}
+	client := (&Adb{s}).Device(DeviceWithSerial("abc"))
+
+	err := client.Uninstall("com.example.app", UninstallOptions{KeepData: true, User: "0"})
+	var installErr *InstallError
+	require.True(t, stderrors.As(err, &installErr))
+	assert.Equal(t, "DELETE_FAILED_INTERNAL_ERROR", installErr.Code)
+	assert.Equal(t, "exec:cmd package uninstall -k --user 0 com.example.app", s.Requests[2])
+}
+
+func TestClearPackageData(t *testing.T) {
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"cmd", "Success\n"},
+	}
+	client := (&Adb{s}).Device(DeviceWithSerial("abc"))
+	assert.NoError(t, client.ClearPackageData("com.example.app"))
+	assert.Equal(t, "exec:cmd package clear com.example.app", s.Requests[2])
+
+	s = &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"cmd", "Failed\n"},
+	}
+	client = (&Adb{s}).Device(DeviceWithSerial("abc"))
+	err := client.ClearPackageData("com.example.app")
+	assert.Equal(t, errors.AdbError, err.(*errors.Err).Code)
+}
@@ -0,0 +1,249 @@
+package adb
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+// GrantPermission grants the runtime permission to the package packageName, e.g.
+// android.permission.CAMERA, for the current user.
+func (c *Device) GrantPermission(packageName, permission string) error {
+	return c.GrantPermissionContext(context.Background(), packageName, permission)
+}
+
+// GrantPermissionContext is like GrantPermission, but gives up as soon as ctx is done.
+func (c *Device) GrantPermissionContext(ctx context.Context, packageName, permission string) error {
+	err := c.runPermissionCommand(ctx, "package", "grant", packageName, permission)
+	return wrapClientError(err, c, "GrantPermission(%s, %s)", packageName, permission)
+}
+
+// RevokePermission revokes the runtime permission from the package packageName for the
+// current user. The app is killed if it's running.
+func (c *Device) RevokePermission(packageName, permission string) error {
+	return c.RevokePermissionContext(context.Background(), packageName, permission)
+}
+
+// RevokePermissionContext is like RevokePermission, but gives up as soon as ctx is done.
+func (c *Device) RevokePermissionContext(ctx context.Context, packageName, permission string) error {
+	err := c.runPermissionCommand(ctx, "package", "revoke", packageName, permission)
+	return wrapClientError(err, c, "RevokePermission(%s, %s)", packageName, permission)
+}
+
+// ResetPermissions reverts the runtime permissions of all the packages to their default
+// state, as if they had just been installed.
+func (c *Device) ResetPermissions() error {
+	return c.ResetPermissionsContext(context.Background())
+}
+
+// ResetPermissionsContext is like ResetPermissions, but gives up as soon as ctx is done.
+func (c *Device) ResetPermissionsContext(ctx context.Context) error {
+	err := c.runPermissionCommand(ctx, "package", "reset-permissions")
+	return wrapClientError(err, c, "ResetPermissions")
+}
+
+/*
+GrantAllRuntimePermissions grants all the runtime permissions requested by the package
+packageName that aren't granted to user yet, and returns the ones it granted.
+
+The runtime permissions are the requested ones that pm list permissions reports as dangerous,
+or that dumpsys package lists for the user. Doesn't stop at the first permission that can't be
+granted, the returned error combines the errors of all of them.
+*/
+func (c *Device) GrantAllRuntimePermissions(packageName string, user int) ([]string, error) {
+	return c.GrantAllRuntimePermissionsContext(context.Background(), packageName, user)
+}
+
+// GrantAllRuntimePermissionsContext is like GrantAllRuntimePermissions, but gives up as soon
+// as ctx is done.
+func (c *Device) GrantAllRuntimePermissionsContext(ctx context.Context, packageName string, user int) ([]string, error) {
+	output, err := c.RunCommandAsStringContext(ctx, "dumpsys", "package", packageName)
+	if err != nil {
+		return nil, err
+	}
+	pi, err := parsePackageInfo(packageName, output)
+	if err == ErrPackageNotExist {
+		err = errors.Errorf(errors.AdbError, "package not found: %s", packageName)
+	}
+	if err != nil {
+		return nil, wrapClientError(err, c, "GrantAllRuntimePermissions(%s)", packageName)
+	}
+	// Before Android 10, dumpsys package only lists the runtime permissions that are granted or
+	// have flags, so the others are found among the dangerous permissions.
+	output, err = c.runServiceCommand(ctx, "package", nil, 0, "list", "permissions", "-g", "-d")
+	if err != nil {
+		return nil, wrapClientError(err, c, "GrantAllRuntimePermissions(%s)", packageName)
+	}
+
+	var granted []string
+	var errs []error
+	for _, perm := range ungrantedRuntimePermissions(&pi, user, parseDangerousPermissions(output)) {
+		err := c.runPermissionCommand(ctx, "package", "grant", "--user", fmt.Sprint(user), packageName, perm)
+		if err != nil {
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		granted = append(granted, perm)
+	}
+	if len(errs) > 0 {
+		err = errors.CombineErrs("error granting permissions of "+packageName, errs[0].(*errors.Err).Code, errs...)
+	}
+	return granted, wrapClientError(err, c, "GrantAllRuntimePermissions(%s)", packageName)
+}
+
+/*
+ungrantedRuntimePermissions returns the requested runtime permissions of pi that aren't granted
+to user, in the order they're requested, or nil if pi isn't installed for user. The runtime
+permissions are the dangerous ones, and the ones listed in the runtime permissions of user.
+*/
+func ungrantedRuntimePermissions(pi *PackageInfo, user int, dangerous map[string]bool) []string {
+	var state *PackageUserState
+	for i := range pi.Users {
+		if pi.Users[i].User == user {
+			state = &pi.Users[i]
+		}
+	}
+	if state == nil || !state.Installed {
+		return nil
+	}
+	runtime := map[string]bool{}
+	granted := map[string]bool{}
+	for _, perm := range state.RuntimePermissions {
+		runtime[perm.Name] = true
+		granted[perm.Name] = perm.Granted
+	}
+
+	var ungranted []string
+	for _, perm := range pi.RequestedPermissions {
+		if (runtime[perm] || dangerous[perm]) && !granted[perm] {
+			ungranted = append(ungranted, perm)
+			// Permissions may be requested more than once.
+			granted[perm] = true
+		}
+	}
+	return ungranted
+}
+
+/*
+parseDangerousPermissions parses the output of pm list permissions -g -d, e.g.
+	Dangerous Permissions:
+
+	group:android.permission-group.CONTACTS
+	  permission:android.permission.READ_CONTACTS
+	  permission:android.permission.WRITE_CONTACTS
+
+	ungrouped:
+	  permission:android.permission.READ_CALL_LOG
+*/
+func parseDangerousPermissions(output string) map[string]bool {
+	dangerous := map[string]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		if perm := strings.TrimSpace(line); strings.HasPrefix(perm, "permission:") {
+			dangerous[strings.TrimPrefix(perm, "permission:")] = true
+		}
+	}
+	return dangerous
+}
+
+// AppOpMode is the mode of an app-op, which decides whether the app is allowed to perform the
+// operation.
+type AppOpMode string
+
+const (
+	AppOpAllow   AppOpMode = "allow"
+	AppOpIgnore  AppOpMode = "ignore"
+	AppOpDeny    AppOpMode = "deny"
+	AppOpDefault AppOpMode = "default"
+
+	// AppOpForeground only allows the operation while the app is in the foreground.
+	AppOpForeground AppOpMode = "foreground"
+)
+
+// AppOp is the mode of an app-op for a package, as returned by Device.AppOps.
+type AppOp struct {
+	// Name of the operation, e.g. CAMERA or SYSTEM_ALERT_WINDOW.
+	Name string
+	Mode AppOpMode
+
+	// UIDMode is true if the mode applies to the whole UID of the package rather than just the
+	// package.
+	UIDMode bool
+}
+
+// AppOps returns the app-ops of the package packageName whose mode isn't the default, or that
+// the app has used.
+func (c *Device) AppOps(packageName string) ([]AppOp, error) {
+	return c.AppOpsContext(context.Background(), packageName)
+}
+
+// AppOpsContext is like AppOps, but gives up as soon as ctx is done.
+func (c *Device) AppOpsContext(ctx context.Context, packageName string) ([]AppOp, error) {
+	output, err := c.runServiceCommand(ctx, "appops", nil, 0, "get", packageName)
+	if err == nil {
+		err = parseServiceOutput("appops", "get", output)
+	}
+	if err != nil {
+		return nil, wrapClientError(err, c, "AppOps(%s)", packageName)
+	}
+	ops, err := parseAppOps(output)
+	return ops, wrapClientError(err, c, "AppOps(%s)", packageName)
+}
+
+// SetAppOp sets the mode of the app-op op, e.g. CAMERA, for the package packageName.
+func (c *Device) SetAppOp(packageName, op string, mode AppOpMode) error {
+	return c.SetAppOpContext(context.Background(), packageName, op, mode)
+}
+
+// SetAppOpContext is like SetAppOp, but gives up as soon as ctx is done.
+func (c *Device) SetAppOpContext(ctx context.Context, packageName, op string, mode AppOpMode) error {
+	err := c.runPermissionCommand(ctx, "appops", "set", packageName, op, string(mode))
+	return wrapClientError(err, c, "SetAppOp(%s, %s, %s)", packageName, op, mode)
+}
+
+// runPermissionCommand runs a command of service that prints nothing if it succeeds.
+func (c *Device) runPermissionCommand(ctx context.Context, service string, args ...string) error {
+	output, err := c.runServiceCommand(ctx, service, nil, 0, args...)
+	if err != nil {
+		return err
+	}
+	return parseServiceOutput(service, args[0], output)
+}
+
+/*
+parseAppOps parses the output of appops get, e.g.
+	Uid mode: COARSE_LOCATION: foreground
+	CAMERA: allow; time=+2h3m ago; duration=+1s
+	RECORD_AUDIO: ignore
+or "No operations." if there are none. Newer versions add indented details about each access,
+which are ignored.
+*/
+func parseAppOps(output string) ([]AppOp, error) {
+	var ops []AppOp
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \r")
+		if line == "" || line == "No operations." || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+
+		var op AppOp
+		if strings.HasPrefix(line, "Uid mode: ") {
+			op.UIDMode = true
+			line = strings.TrimPrefix(line, "Uid mode: ")
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf(errors.ParseError, "invalid app-op line: %s", line)
+		}
+		op.Name = parts[0]
+		op.Mode = AppOpMode(strings.TrimSpace(strings.SplitN(parts[1], ";", 2)[0]))
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
@@ -0,0 +1,85 @@
+package adb
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/kvnxiao/go-adb/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAppOps(t *testing.T) {
+	ops, err := parseAppOps("Uid mode: COARSE_LOCATION: foreground\n" +
+		"CAMERA: allow; time=+2h3m ago; duration=+1s\n" +
+		"  null=[\n" +
+		"    Access: [top-s] 2023-05-01 10:00:00.000 (-1h2m3s)\n" +
+		"  ]\n" +
+		"RECORD_AUDIO: ignore\n")
+	assert.NoError(t, err)
+	assert.Equal(t, []AppOp{
+		{Name: "COARSE_LOCATION", Mode: AppOpForeground, UIDMode: true},
+		{Name: "CAMERA", Mode: AppOpAllow},
+		{Name: "RECORD_AUDIO", Mode: AppOpIgnore},
+	}, ops)
+
+	ops, err = parseAppOps("No operations.\n")
+	assert.NoError(t, err)
+	assert.Empty(t, ops)
+
+	_, err = parseAppOps("garbage\n")
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+}
+
+func TestUngrantedRuntimePermissions(t *testing.T) {
+	pi, err := parsePackageInfo("com.example.app", readDumpsysFixture(t, "dumpsys_package_android10.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"android.permission.ACCESS_FINE_LOCATION", "android.permission.WRITE_EXTERNAL_STORAGE"},
+		ungrantedRuntimePermissions(&pi, 0, nil))
+	assert.Empty(t, ungrantedRuntimePermissions(&pi, 10, map[string]bool{"android.permission.CAMERA": true}))
+
+	// Android 6 to 9 don't list the runtime permissions that were never granted.
+	pi, err = parsePackageInfo("com.example.app", readDumpsysFixture(t, "dumpsys_package_android6.txt"))
+	require.NoError(t, err)
+	assert.Empty(t, ungrantedRuntimePermissions(&pi, 0, nil))
+	dangerous := parseDangerousPermissions("Dangerous Permissions:\n" +
+		"\n" +
+		"group:android.permission-group.CONTACTS\n" +
+		"  permission:android.permission.WRITE_CONTACTS\n" +
+		"  permission:android.permission.READ_CONTACTS\n" +
+		"\n" +
+		"group:android.permission-group.CAMERA\n" +
+		"  permission:android.permission.CAMERA\n" +
+		"\n" +
+		"ungrouped:\n")
+	assert.Equal(t, map[string]bool{
+		"android.permission.WRITE_CONTACTS": true,
+		"android.permission.READ_CONTACTS":  true,
+		"android.permission.CAMERA":         true,
+	}, dangerous)
+	assert.Equal(t, []string{"android.permission.READ_CONTACTS"}, ungrantedRuntimePermissions(&pi, 0, dangerous))
+}
+
+func TestGrantPermission(t *testing.T) {
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"cmd", ""},
+	}
+	client := (&Adb{s}).Device(DeviceWithSerial("abc"))
+	assert.NoError(t, client.GrantPermission("com.example.app", "android.permission.CAMERA"))
+	assert.Equal(t, "exec:cmd package grant com.example.app android.permission.CAMERA", s.Requests[2])
+}
+
+func TestSetAppOp(t *testing.T) {
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"abb_exec", "Error: Unknown operation string: FOO\n"},
+	}
+	client := (&Adb{s}).Device(DeviceWithSerial("abc"))
+	err := client.SetAppOp("com.example.app", "FOO", AppOpAllow)
+	var serviceErr *ServiceError
+	require.True(t, stderrors.As(err, &serviceErr))
+	assert.Equal(t, &ServiceError{Service: "appops", Command: "set", Message: "Unknown operation string: FOO"}, serviceErr)
+	assert.Equal(t, "abb_exec:appops\x00set\x00com.example.app\x00FOO\x00allow", s.Requests[2])
+}
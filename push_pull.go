@@ -0,0 +1,572 @@
+package adb
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/kvnxiao/go-adb/wire"
+)
+
+// SymlinkPolicy controls what Push and Pull do with the symlinks they find in a tree.
+type SymlinkPolicy int
+
+const (
+	// SymlinkSkip doesn't copy symlinks.
+	SymlinkSkip SymlinkPolicy = iota
+
+	// SymlinkFollow copies the file or directory a symlink points to, as if it was where the
+	// symlink is. Directories that contain themselves are only copied once.
+	SymlinkFollow
+
+	// SymlinkPreserve recreates symlinks at the destination, pointing to the same target.
+	SymlinkPreserve
+)
+
+// TreeOptions configures Device.PushWithOptions and Device.PullWithOptions.
+type TreeOptions struct {
+	Symlinks SymlinkPolicy
+
+	// Transfer configures the transfer of each file.
+	Transfer TransferOptions
+
+	// Progress, if not nil, is called while each file is copied and once after it's done.
+	// It's called from the goroutine that called Push or Pull.
+	Progress func(FileProgress)
+}
+
+// FileProgress reports the progress of copying a single file of a tree.
+type FileProgress struct {
+	// Src and Dst are the paths the file is copied from and to.
+	Src, Dst string
+
+	// Transferred bytes out of Size. Size is 0 for symlinks.
+	Transferred, Size int64
+
+	// Done is true for the last report of each file, after which Err is set if copying failed.
+	Done bool
+	Err  error
+}
+
+// maxTreeDepth limits how deep Pull follows symlinks when the device can't report which
+// directories are the same.
+const maxTreeDepth = 64
+
+/*
+Push copies the file or directory tree at localPath to remotePath on the device.
+
+Like adb push, if remotePath is an existing directory the tree is copied into it. Directories
+are created as needed, and both directories and files keep their permissions and modification
+times. If localPath is a symlink it's followed, but symlinks inside the tree are skipped, use
+PushWithOptions to copy them. Special files like sockets are always skipped.
+
+Push doesn't stop at the first file it fails to copy, the returned error combines the errors
+for all the files that couldn't be copied.
+*/
+func (c *Device) Push(localPath, remotePath string) error {
+	return c.PushContext(context.Background(), localPath, remotePath)
+}
+
+// PushContext is like Push, but stops as soon as ctx is done.
+func (c *Device) PushContext(ctx context.Context, localPath, remotePath string) error {
+	return c.PushWithOptions(ctx, localPath, remotePath, TreeOptions{})
+}
+
+// PushWithOptions is like PushContext, but allows choosing how symlinks are copied, how files
+// are transferred, and getting notified of the progress of each file.
+func (c *Device) PushWithOptions(ctx context.Context, localPath, remotePath string, opts TreeOptions) error {
+	t := &treeCopier{ctx: ctx, device: c, opts: opts}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		err = wrapLocalErr(err, "error reading %s", localPath)
+		return wrapClientError(err, c, "Push(%s, %s)", localPath, remotePath)
+	}
+
+	dest, err := c.statFollow(ctx, remotePath)
+	if err == nil && dest.Mode.IsDir() {
+		remotePath = path.Join(remotePath, filepath.Base(localPath))
+	} else if err != nil && !HasErrCode(err, FileNoExistError) {
+		return wrapClientError(err, c, "Push(%s, %s)", localPath, remotePath)
+	}
+
+	t.push(localPath, remotePath, info, nil)
+	return wrapClientError(t.err("error pushing "+localPath), c, "Push(%s, %s)", localPath, remotePath)
+}
+
+/*
+Pull copies the file or directory tree at remotePath on the device to localPath.
+
+Like adb pull, if localPath is an existing directory the tree is copied into it. Directories
+are created as needed, and both directories and files keep their permissions and modification
+times. If remotePath is a symlink it's followed, but symlinks inside the tree are skipped, use
+PullWithOptions to copy them. Special files like sockets are always skipped.
+
+Pull doesn't stop at the first file it fails to copy, the returned error combines the errors
+for all the files that couldn't be copied.
+*/
+func (c *Device) Pull(remotePath, localPath string) error {
+	return c.PullContext(context.Background(), remotePath, localPath)
+}
+
+// PullContext is like Pull, but stops as soon as ctx is done.
+func (c *Device) PullContext(ctx context.Context, remotePath, localPath string) error {
+	return c.PullWithOptions(ctx, remotePath, localPath, TreeOptions{})
+}
+
+// PullWithOptions is like PullContext, but allows choosing how symlinks are copied, how files
+// are transferred, and getting notified of the progress of each file.
+func (c *Device) PullWithOptions(ctx context.Context, remotePath, localPath string, opts TreeOptions) error {
+	t := &treeCopier{ctx: ctx, device: c, opts: opts}
+	entry, err := c.statFollow(ctx, remotePath)
+	if err != nil {
+		return wrapClientError(err, c, "Pull(%s, %s)", remotePath, localPath)
+	}
+
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		localPath = filepath.Join(localPath, path.Base(remotePath))
+	}
+
+	t.pull(remotePath, localPath, entry, nil)
+	return wrapClientError(t.err("error pulling "+remotePath), c, "Pull(%s, %s)", remotePath, localPath)
+}
+
+// treeCopier holds the state of a single Push or Pull.
+type treeCopier struct {
+	ctx    context.Context
+	device *Device
+	opts   TreeOptions
+
+	// Errors for the files that couldn't be copied, in the order they happened.
+	errs []error
+}
+
+// err combines the errors of all the files, with the code of the first one.
+func (t *treeCopier) err(msg string) error {
+	if err := wrapContextErr(t.ctx, t.ctx.Err()); err != nil {
+		t.errs = append(t.errs, err)
+	}
+	if len(t.errs) == 0 {
+		return nil
+	}
+	code := errors.AssertionError
+	if err, ok := t.errs[0].(*errors.Err); ok {
+		code = err.Code
+	}
+	return errors.CombineErrs(msg, code, t.errs...)
+}
+
+// fail records err for the file at src, and reports it to the progress callback.
+func (t *treeCopier) fail(src, dst string, err error) {
+	t.errs = append(t.errs, err)
+	t.report(FileProgress{Src: src, Dst: dst, Done: true, Err: err})
+}
+
+func (t *treeCopier) report(progress FileProgress) {
+	if t.opts.Progress != nil {
+		t.opts.Progress(progress)
+	}
+}
+
+// copyFile copies src to dst, reporting progress as it goes.
+func (t *treeCopier) copyFile(dst io.Writer, src io.Reader, progress FileProgress) (int64, error) {
+	if t.opts.Progress == nil {
+		return io.Copy(dst, src)
+	}
+	t.report(progress)
+	return io.Copy(io.MultiWriter(dst, &progressWriter{progress, t.opts.Progress}), src)
+}
+
+func (t *treeCopier) push(localPath, remotePath string, info os.FileInfo, ancestors []os.FileInfo) {
+	if t.ctx.Err() != nil {
+		return
+	}
+
+	switch mode := info.Mode(); {
+	case mode&os.ModeSymlink != 0:
+		switch t.opts.Symlinks {
+		case SymlinkFollow:
+			target, err := os.Stat(localPath)
+			if err != nil {
+				t.fail(localPath, remotePath, wrapLocalErr(err, "error following symlink %s", localPath))
+				return
+			}
+			t.push(localPath, remotePath, target, ancestors)
+		case SymlinkPreserve:
+			t.pushSymlink(localPath, remotePath, info)
+		}
+
+	case mode.IsDir():
+		for _, ancestor := range ancestors {
+			if os.SameFile(ancestor, info) {
+				// A symlink to one of the directories being pushed, don't loop.
+				return
+			}
+		}
+		t.pushDir(localPath, remotePath, info, append(ancestors, info))
+
+	case mode.IsRegular():
+		t.pushFile(localPath, remotePath, info)
+	}
+}
+
+func (t *treeCopier) pushDir(localPath, remotePath string, info os.FileInfo, ancestors []os.FileInfo) {
+	children, err := ioutil.ReadDir(localPath)
+	if err != nil {
+		t.fail(localPath, remotePath, wrapLocalErr(err, "error reading directory %s", localPath))
+		return
+	}
+
+	// Pushing files creates their parent directories, so only empty directories need to be
+	// created explicitly.
+	if len(children) == 0 {
+		if err := t.runShell("mkdir", "-p", remotePath); err != nil {
+			t.fail(localPath, remotePath, err)
+			return
+		}
+	}
+	for _, child := range children {
+		t.push(filepath.Join(localPath, child.Name()), path.Join(remotePath, child.Name()), child, ancestors)
+	}
+
+	// Set the permissions last, in case they don't allow writing to the directory, and the
+	// modification time after the children are written, since writing them changes it.
+	if t.ctx.Err() == nil {
+		mode := strconv.FormatUint(uint64(info.Mode().Perm()), 8)
+		if err := t.runShell("chmod", mode, remotePath); err != nil {
+			t.fail(localPath, remotePath, err)
+			return
+		}
+		mtime := "@" + strconv.FormatInt(info.ModTime().Unix(), 10)
+		if err := t.runShell("touch", "-m", "-d", mtime, remotePath); err != nil {
+			t.fail(localPath, remotePath, err)
+		}
+	}
+}
+
+func (t *treeCopier) pushFile(localPath, remotePath string, info os.FileInfo) {
+	progress := FileProgress{Src: localPath, Dst: remotePath, Size: info.Size()}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		t.fail(localPath, remotePath, wrapLocalErr(err, "error opening %s", localPath))
+		return
+	}
+	defer file.Close()
+
+	writer, err := t.device.OpenWriteWithOptions(t.ctx, remotePath, info.Mode().Perm(), info.ModTime(), t.opts.Transfer)
+	if err != nil {
+		t.fail(localPath, remotePath, err)
+		return
+	}
+
+	progress.Transferred, err = t.copyFile(writer, &localFileReader{file}, progress)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		t.fail(localPath, remotePath, err)
+		return
+	}
+	progress.Done = true
+	t.report(progress)
+}
+
+func (t *treeCopier) pushSymlink(localPath, remotePath string, info os.FileInfo) {
+	target, err := os.Readlink(localPath)
+	if err != nil {
+		t.fail(localPath, remotePath, wrapLocalErr(err, "error reading symlink %s", localPath))
+		return
+	}
+
+	conn, err := t.device.getSyncConn(t.ctx)
+	if err != nil {
+		t.fail(localPath, remotePath, err)
+		return
+	}
+	defer conn.Close()
+
+	// Devices only understand forward slashes.
+	if err := sendSymlink(conn, remotePath, filepath.ToSlash(target), info.ModTime()); err != nil {
+		t.fail(localPath, remotePath, wrapContextErr(t.ctx, err))
+		return
+	}
+	t.report(FileProgress{Src: localPath, Dst: remotePath, Done: true})
+}
+
+func (t *treeCopier) pull(remotePath, localPath string, entry *DirEntry, ancestors []*DirEntry) {
+	if t.ctx.Err() != nil {
+		return
+	}
+
+	switch mode := entry.Mode; {
+	case mode&os.ModeSymlink != 0:
+		switch t.opts.Symlinks {
+		case SymlinkFollow:
+			target, err := t.device.statFollow(t.ctx, remotePath)
+			if err != nil {
+				t.fail(remotePath, localPath, err)
+				return
+			}
+			t.pull(remotePath, localPath, target, ancestors)
+		case SymlinkPreserve:
+			t.pullSymlink(remotePath, localPath)
+		}
+
+	case mode.IsDir():
+		if len(ancestors) >= maxTreeDepth {
+			t.fail(remotePath, localPath, errors.Errorf(errors.AssertionError,
+				"too many levels of directories, probably a symlink loop: %s", remotePath))
+			return
+		}
+		for _, ancestor := range ancestors {
+			if entry.Inode != 0 && ancestor.Dev == entry.Dev && ancestor.Inode == entry.Inode {
+				return
+			}
+		}
+		t.pullDir(remotePath, localPath, entry, append(ancestors, entry))
+
+	case mode.IsRegular():
+		t.pullFile(remotePath, localPath, entry)
+	}
+}
+
+func (t *treeCopier) pullDir(remotePath, localPath string, entry *DirEntry, ancestors []*DirEntry) {
+	// Only the owner needs access while pulling, the permissions are set at the end.
+	if err := os.MkdirAll(localPath, 0700); err != nil {
+		t.fail(remotePath, localPath, wrapLocalErr(err, "error creating directory %s", localPath))
+		return
+	}
+
+	children, err := t.device.listDir(t.ctx, remotePath)
+	if err != nil {
+		t.fail(remotePath, localPath, err)
+		return
+	}
+	for _, child := range children {
+		childPath := path.Join(remotePath, child.Name)
+		if child.Errno != 0 {
+			t.fail(childPath, filepath.Join(localPath, child.Name), errors.Errorf(errors.AdbError,
+				"device couldn't stat %s: errno %d", childPath, child.Errno))
+			continue
+		}
+		t.pull(childPath, filepath.Join(localPath, child.Name), child, ancestors)
+	}
+
+	if err := setLocalAttributes(localPath, entry); err != nil {
+		t.fail(remotePath, localPath, err)
+	}
+}
+
+func (t *treeCopier) pullFile(remotePath, localPath string, entry *DirEntry) {
+	progress := FileProgress{Src: remotePath, Dst: localPath, Size: entry.Size}
+
+	reader, err := t.device.OpenReadWithOptions(t.ctx, remotePath, t.opts.Transfer)
+	if err != nil {
+		t.fail(remotePath, localPath, err)
+		return
+	}
+	defer reader.Close()
+
+	file, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		t.fail(remotePath, localPath, wrapLocalErr(err, "error creating %s", localPath))
+		return
+	}
+
+	progress.Transferred, err = t.copyFile(&localFileWriter{file}, reader, progress)
+	if closeErr := file.Close(); err == nil {
+		err = wrapLocalErr(closeErr, "error writing %s", localPath)
+	}
+	if err == nil {
+		err = setLocalAttributes(localPath, entry)
+	}
+	if err != nil {
+		t.fail(remotePath, localPath, err)
+		return
+	}
+	progress.Done = true
+	t.report(progress)
+}
+
+func (t *treeCopier) pullSymlink(remotePath, localPath string) {
+	result, err := t.device.RunShellCommandContext(t.ctx, "readlink", remotePath)
+	if err != nil {
+		t.fail(remotePath, localPath, err)
+		return
+	}
+	if result.ExitCode != 0 {
+		t.fail(remotePath, localPath, errors.Errorf(errors.AdbError, "error reading symlink %s: %s",
+			remotePath, strings.TrimSpace(string(result.Stdout)+string(result.Stderr))))
+		return
+	}
+
+	// Replace any existing file, like pulling a regular file would.
+	target := strings.TrimSuffix(string(result.Stdout), "\n")
+	if err := os.Remove(localPath); err != nil && !os.IsNotExist(err) {
+		t.fail(remotePath, localPath, wrapLocalErr(err, "error replacing %s", localPath))
+		return
+	}
+	if err := os.Symlink(filepath.FromSlash(target), localPath); err != nil {
+		t.fail(remotePath, localPath, wrapLocalErr(err, "error creating symlink %s", localPath))
+		return
+	}
+	t.report(FileProgress{Src: remotePath, Dst: localPath, Done: true})
+}
+
+// runShell runs a command that's only expected to succeed, and returns an AdbError with its
+// output if it doesn't.
+func (t *treeCopier) runShell(cmd string, args ...string) error {
+	result, err := t.device.RunShellCommandContext(t.ctx, cmd, args...)
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return errors.Errorf(errors.AdbError, "%s %s exited with status %d: %s", cmd,
+			strings.Join(args, " "), result.ExitCode,
+			strings.TrimSpace(string(result.Stdout)+string(result.Stderr)))
+	}
+	return nil
+}
+
+// listDir returns the entries of the directory at path, except for "." and "..".
+func (c *Device) listDir(ctx context.Context, path string) ([]*DirEntry, error) {
+	entries, err := c.ListDirEntriesContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	all, err := entries.ReadAll()
+	if err != nil {
+		if _, ok := err.(*errors.Err); !ok {
+			err = errors.WrapErrorf(err, errors.NetworkError, "error listing %s", path)
+		}
+		return nil, wrapContextErr(ctx, err)
+	}
+
+	children := all[:0]
+	for _, entry := range all {
+		if entry.Name != "." && entry.Name != ".." {
+			children = append(children, entry)
+		}
+	}
+	return children, nil
+}
+
+// statFollow is like StatContext, but follows symlinks.
+func (c *Device) statFollow(ctx context.Context, path string) (*DirEntry, error) {
+	hasStat2, err := c.HasFeatureContext(ctx, FeatureStat2)
+	if err != nil {
+		return nil, err
+	}
+	if hasStat2 {
+		conn, err := c.getSyncConn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		entry, err := statV2(conn, "STA2", path)
+		return entry, wrapContextErr(ctx, err)
+	}
+
+	// The v1 stat request never follows symlinks, so ask the shell instead.
+	result, err := c.RunShellCommandContext(ctx, "stat", "-L", "-c", "%f %s %Y", path)
+	if err != nil {
+		return nil, err
+	}
+	return parseShellStat(path, result)
+}
+
+// parseShellStat parses the output of stat -c '%f %s %Y' for path, i.e. the raw mode in hex,
+// the size and the modification time.
+func parseShellStat(path string, result *ShellResult) (*DirEntry, error) {
+	output := strings.TrimSpace(string(result.Stdout) + string(result.Stderr))
+	if result.ExitCode != 0 {
+		if strings.Contains(output, "No such file") {
+			return nil, errors.Errorf(errors.FileNoExistError, "no such file or directory: %s", path)
+		}
+		return nil, errors.Errorf(errors.AdbError, "error running stat on %s: %s", path, output)
+	}
+
+	fields := strings.Fields(output)
+	if len(fields) != 3 {
+		return nil, errors.Errorf(errors.ParseError, "invalid stat output for %s: %q", path, output)
+	}
+	mode, err := strconv.ParseUint(fields[0], 16, 32)
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.ParseError, "invalid mode in stat output: %s", fields[0])
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.ParseError, "invalid size in stat output: %s", fields[1])
+	}
+	mtime, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.ParseError, "invalid mtime in stat output: %s", fields[2])
+	}
+
+	return &DirEntry{
+		Name:       path,
+		Mode:       wire.ParseFileModeFromAdb(uint32(mode)),
+		Size:       size,
+		ModifiedAt: time.Unix(mtime, 0),
+	}, nil
+}
+
+// setLocalAttributes sets the permissions and times of the local file at path to those of entry.
+func setLocalAttributes(path string, entry *DirEntry) error {
+	if err := os.Chmod(path, entry.Mode.Perm()); err != nil {
+		return wrapLocalErr(err, "error setting permissions of %s", path)
+	}
+	atime := entry.AccessedAt
+	if atime.IsZero() {
+		atime = entry.ModifiedAt
+	}
+	return wrapLocalErr(os.Chtimes(path, atime, entry.ModifiedAt), "error setting times of %s", path)
+}
+
+// wrapLocalErr wraps an error returned by the os package as a LocalFileError.
+func wrapLocalErr(err error, format string, args ...interface{}) error {
+	return errors.WrapErrorf(err, errors.LocalFileError, format, args...)
+}
+
+// localFileReader reports read errors of a local file as LocalFileErrors, so they can be told
+// apart from errors writing to the device.
+type localFileReader struct {
+	file *os.File
+}
+
+func (r *localFileReader) Read(buf []byte) (int, error) {
+	n, err := r.file.Read(buf)
+	if err == io.EOF {
+		return n, err
+	}
+	return n, wrapLocalErr(err, "error reading %s", r.file.Name())
+}
+
+// localFileWriter is like localFileReader, for writes.
+type localFileWriter struct {
+	file *os.File
+}
+
+func (w *localFileWriter) Write(buf []byte) (int, error) {
+	n, err := w.file.Write(buf)
+	return n, wrapLocalErr(err, "error writing %s", w.file.Name())
+}
+
+// progressWriter reports the bytes written to it as transferred bytes.
+type progressWriter struct {
+	progress FileProgress
+	report   func(FileProgress)
+}
+
+func (w *progressWriter) Write(buf []byte) (int, error) {
+	w.progress.Transferred += int64(len(buf))
+	w.report(w.progress)
+	return len(buf), nil
+}
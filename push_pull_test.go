@@ -0,0 +1,152 @@
+package adb
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseShellStat(t *testing.T) {
+	entry, err := parseShellStat("/sdcard", &ShellResult{Stdout: []byte("41f9 3488 1430640488\n")})
+	assert.NoError(t, err)
+	assert.Equal(t, &DirEntry{
+		Name:       "/sdcard",
+		Mode:       os.ModeDir | 0771,
+		Size:       3488,
+		ModifiedAt: time.Unix(1430640488, 0),
+	}, entry)
+}
+
+func TestParseShellStatNoExist(t *testing.T) {
+	_, err := parseShellStat("/nope", &ShellResult{
+		Stderr:   []byte("stat: '/nope': No such file or directory\n"),
+		ExitCode: 1,
+	})
+	assert.Equal(t, errors.FileNoExistError, err.(*errors.Err).Code)
+
+	_, err = parseShellStat("/data", &ShellResult{
+		Stdout:   []byte("stat: '/data': Permission denied\n"),
+		ExitCode: 1,
+	})
+	assert.Equal(t, errors.AdbError, err.(*errors.Err).Code)
+}
+
+func TestParseShellStatInvalid(t *testing.T) {
+	_, err := parseShellStat("/sdcard", &ShellResult{Stdout: []byte("41f9 3488\n")})
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+
+	_, err = parseShellStat("/sdcard", &ShellResult{Stdout: []byte("drwx 3488 1430640488\n")})
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+}
+
+func TestSetLocalAttributes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "adb")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+	require.NoError(t, ioutil.WriteFile(path, nil, 0600))
+
+	require.NoError(t, setLocalAttributes(path, &DirEntry{Mode: 0640, ModifiedAt: someTime}))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode())
+	assert.True(t, someTime.Equal(info.ModTime()), "%s", info.ModTime())
+
+	err = setLocalAttributes(filepath.Join(dir, "nope"), &DirEntry{Mode: 0640})
+	assert.Equal(t, errors.LocalFileError, err.(*errors.Err).Code)
+}
+
+func TestTreeCopierErr(t *testing.T) {
+	var reported []FileProgress
+	copier := &treeCopier{ctx: context.Background(), opts: TreeOptions{
+		Progress: func(p FileProgress) { reported = append(reported, p) },
+	}}
+	assert.NoError(t, copier.err("error pushing"))
+
+	err1 := errors.Errorf(errors.LocalFileError, "one")
+	err2 := errors.Errorf(errors.AdbError, "two")
+	copier.fail("a", "/a", err1)
+	copier.fail("b", "/b", err2)
+	assert.Equal(t, []FileProgress{
+		{Src: "a", Dst: "/a", Done: true, Err: err1},
+		{Src: "b", Dst: "/b", Done: true, Err: err2},
+	}, reported)
+
+	err := copier.err("error pushing")
+	assert.Equal(t, errors.LocalFileError, err.(*errors.Err).Code)
+	assert.Contains(t, err.Error(), "error pushing")
+}
+
+func TestTreeCopierErrCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	copier := &treeCopier{ctx: ctx}
+
+	// Nothing is copied once ctx is done.
+	copier.push("/nope", "/sdcard/nope", nil, nil)
+	err := copier.err("error pushing")
+	assert.Equal(t, errors.ContextCanceled, err.(*errors.Err).Code)
+}
+
+func TestPushDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "adb")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "tree")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "empty"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "file"), []byte("hello"), 0640))
+	for name, mtime := range map[string]time.Time{
+		"file":  someTime,
+		"empty": someTime.Add(time.Hour),
+		"":      someTime.Add(2 * time.Hour),
+	} {
+		require.NoError(t, os.Chtimes(filepath.Join(root, name), mtime, mtime))
+	}
+	require.NoError(t, os.Chmod(root, 0750))
+
+	device := newFakeDevice()
+	device.addFile("/sdcard", os.ModeDir|0771, someTime, "")
+	require.NoError(t, (&Adb{device}).Device(AnyDevice()).Push(root, "/sdcard"))
+
+	file := device.file("/sdcard/tree/file")
+	require.NotNil(t, file)
+	assert.Equal(t, "hello", string(file.data))
+	assert.Equal(t, os.FileMode(0640), file.mode)
+	assert.True(t, someTime.Equal(file.mtime), "%s", file.mtime)
+	for name, want := range map[string]struct {
+		mode  os.FileMode
+		mtime time.Time
+	}{
+		"/sdcard/tree/empty": {os.ModeDir | 0700, someTime.Add(time.Hour)},
+		"/sdcard/tree":       {os.ModeDir | 0750, someTime.Add(2 * time.Hour)},
+	} {
+		dir := device.file(name)
+		require.NotNil(t, dir, name)
+		assert.Equal(t, want.mode, dir.mode, name)
+		assert.True(t, want.mtime.Equal(dir.mtime), "%s: %s", name, dir.mtime)
+	}
+}
+
+func TestPushFileFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "adb")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	local := filepath.Join(dir, "file")
+	require.NoError(t, ioutil.WriteFile(local, []byte("hello"), 0640))
+
+	device := newFakeDevice()
+	device.addFile("/sdcard", os.ModeDir|0771, someTime, "")
+	device.sendFailure = "No space left on device"
+	err = (&Adb{device}).Device(AnyDevice()).Push(local, "/sdcard/file")
+	require.Error(t, err)
+	assert.Equal(t, errors.AdbError, err.(*errors.Err).Code)
+	assert.Contains(t, ErrorWithCauseChain(err), "No space left on device")
+	assert.Nil(t, device.file("/sdcard/file"))
+}
@@ -0,0 +1,99 @@
+package adb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+// ScreenRecordOptions configures Device.ScreenRecord.
+type ScreenRecordOptions struct {
+	// BitRate of the video in bits per second, screenrecord uses 20Mbps by default.
+	BitRate int
+
+	// Width and Height of the video, the size of the display by default. Either both or
+	// neither must be set.
+	Width, Height int
+
+	// TimeLimit stops the recording after that long, rounded up to a second. screenrecord
+	// stops after 3 minutes by default, which is also the maximum before Android 14.
+	TimeLimit time.Duration
+
+	// BugreportOverlay adds the time and frame number to the video, like screenrecord --bugreport.
+	BugreportOverlay bool
+}
+
+func (opts ScreenRecordOptions) args() ([]string, error) {
+	args := []string{"--output-format=h264"}
+	if opts.BitRate < 0 || opts.Width < 0 || opts.Height < 0 || opts.TimeLimit < 0 {
+		return nil, errors.AssertionErrorf("invalid screen record options: %+v", opts)
+	}
+	if opts.BitRate > 0 {
+		args = append(args, "--bit-rate", strconv.Itoa(opts.BitRate))
+	}
+	if (opts.Width == 0) != (opts.Height == 0) {
+		return nil, errors.AssertionErrorf("Width and Height must be set together")
+	}
+	if opts.Width > 0 {
+		args = append(args, "--size", strconv.Itoa(opts.Width)+"x"+strconv.Itoa(opts.Height))
+	}
+	if opts.TimeLimit > 0 {
+		args = append(args, "--time-limit", strconv.Itoa(int(math.Ceil(opts.TimeLimit.Seconds()))))
+	}
+	if opts.BugreportOverlay {
+		args = append(args, "--bugreport")
+	}
+	// Write the video to stdout rather than to a file on the device.
+	return append(args, "-"), nil
+}
+
+/*
+ScreenRecord records the screen of the device, and writes the video to w as a raw H.264 stream
+in Annex B format, until screenrecord exits. Nothing is written to the storage of the device.
+Most players need the stream to be in a container, which MP4Writer does as it's recorded:
+	f, err := os.Create("screen.mp4")
+	mp4 := adb.NewMP4Writer(f, 0)
+	err = device.ScreenRecord(mp4, adb.ScreenRecordOptions{TimeLimit: 10 * time.Second})
+	err = mp4.Close()
+Requires Android 5 or later.
+*/
+func (c *Device) ScreenRecord(w io.Writer, opts ScreenRecordOptions) error {
+	return c.ScreenRecordContext(context.Background(), w, opts)
+}
+
+// ScreenRecordContext is like ScreenRecord, but stops recording as soon as ctx is done, in which
+// case it returns a ContextCanceled error. What was written to w until then is a valid stream,
+// except for its last frame which may be truncated.
+func (c *Device) ScreenRecordContext(ctx context.Context, w io.Writer, opts ScreenRecordOptions) error {
+	args, err := opts.args()
+	if err != nil {
+		return wrapClientError(err, c, "ScreenRecord")
+	}
+	conn, err := c.OpenCommandContext(ctx, "screenrecord", args...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// screenrecord prints its errors instead of the video, e.g. when the size isn't supported
+	// by the encoder.
+	r := bufio.NewReader(&contextReadCloser{ctx, conn})
+	prefix, err := r.Peek(4)
+	if !bytes.HasPrefix(prefix, []byte{0, 0, 1}) && !bytes.HasPrefix(prefix, []byte{0, 0, 0, 1}) {
+		if _, ok := err.(*errors.Err); ok {
+			return wrapClientError(err, c, "ScreenRecord")
+		}
+		output, _ := ioutil.ReadAll(io.LimitReader(r, 4096))
+		err = errors.Errorf(errors.AdbError, "screenrecord failed: %s", strings.TrimSpace(string(output)))
+		return wrapClientError(err, c, "ScreenRecord")
+	}
+	return wrapClientError(copyOutput(w, r, "screen recording"), c, "ScreenRecord")
+}
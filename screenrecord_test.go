@@ -0,0 +1,62 @@
+package adb
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/kvnxiao/go-adb/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScreenRecordOptionsArgs(t *testing.T) {
+	args, err := ScreenRecordOptions{}.args()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"--output-format=h264", "-"}, args)
+
+	args, err = ScreenRecordOptions{
+		BitRate:          4000000,
+		Width:            720,
+		Height:           1280,
+		TimeLimit:        1500 * time.Millisecond,
+		BugreportOverlay: true,
+	}.args()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"--output-format=h264", "--bit-rate", "4000000", "--size", "720x1280",
+		"--time-limit", "2", "--bugreport", "-"}, args)
+
+	_, err = ScreenRecordOptions{Width: 720}.args()
+	assert.Equal(t, errors.AssertionError, err.(*errors.Err).Code)
+	_, err = ScreenRecordOptions{BitRate: -1}.args()
+	assert.Equal(t, errors.AssertionError, err.(*errors.Err).Code)
+}
+
+func TestScreenRecord(t *testing.T) {
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"\x00\x00", "\x00\x01\x67\x42", "\x00\x00\x01\x68"},
+	}
+	var buf bytes.Buffer
+	err := (&Adb{s}).Device(AnyDevice()).ScreenRecord(&buf, ScreenRecordOptions{TimeLimit: time.Minute})
+	require.NoError(t, err)
+	assert.Equal(t, "exec:screenrecord --output-format=h264 --time-limit 60 -", s.Requests[1])
+	assert.Equal(t, "\x00\x00\x00\x01\x67\x42\x00\x00\x01\x68", buf.String())
+}
+
+func TestScreenRecordError(t *testing.T) {
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{"ERROR: unable to configure video/avc codec at 99999x1\n"},
+	}
+	var buf bytes.Buffer
+	err := (&Adb{s}).Device(AnyDevice()).ScreenRecord(&buf, ScreenRecordOptions{})
+	assert.True(t, HasErrCode(err, AdbError))
+	assert.Contains(t, ErrorWithCauseChain(err), "unable to configure")
+	assert.Equal(t, 0, buf.Len())
+
+	s = &MockServer{Status: wire.StatusSuccess}
+	err = (&Adb{s}).Device(AnyDevice()).ScreenRecord(&buf, ScreenRecordOptions{})
+	assert.True(t, HasErrCode(err, AdbError))
+}
@@ -0,0 +1,281 @@
+package adb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+// ScreenshotOptions configures Device.ScreenshotWithOptions.
+type ScreenshotOptions struct {
+	// Display is the id of the display to capture, as listed by
+	// "dumpsys SurfaceFlinger --display-id", or empty for the default display.
+	Display string
+
+	// PNG captures the screen with screencap -p even when the faster framebuffer service could
+	// be used.
+	PNG bool
+}
+
+// ColorSpace is the color space of the pixels of a framebuffer.
+type ColorSpace int
+
+const (
+	ColorSpaceUnknown   ColorSpace = 0
+	ColorSpaceSRGB      ColorSpace = 1
+	ColorSpaceDisplayP3 ColorSpace = 2
+)
+
+// ColorChannel is the position of a color channel in the bits of a pixel.
+type ColorChannel struct {
+	Offset, Length int
+}
+
+// FramebufferHeader describes the pixels sent by the framebuffer service.
+type FramebufferHeader struct {
+	Version int
+
+	// BPP is the number of bits per pixel.
+	BPP int
+
+	// ColorSpace is only reported since version 2.
+	ColorSpace ColorSpace
+
+	// Size of the pixel data in bytes.
+	Size          int
+	Width, Height int
+
+	// Channels of the pixels, Alpha has a Length of 0 if pixels are opaque.
+	Red, Green, Blue, Alpha ColorChannel
+}
+
+// Versions of the framebuffer header.
+const (
+	framebufferV1     = 1
+	framebufferV2     = 2
+	framebufferRGB565 = 16
+)
+
+// maxFramebufferSide and maxFramebufferPixels bound the size of framebuffers, so invalid headers
+// don't exhaust memory. The pixels of the largest framebuffer take 256 MiB once decoded, which is
+// twice as many as an 8K screen has.
+const (
+	maxFramebufferSide   = 1 << 15
+	maxFramebufferPixels = 64 << 20
+)
+
+/*
+Screenshot captures the screen of the device:
+	img, err := device.Screenshot()
+	f, err := os.Create("screen.png")
+	err = png.Encode(f, img)
+The raw pixels of the default display are read from the framebuffer service, which is faster
+than encoding them as a PNG on the device. If the device can't provide them, screencap -p is
+used instead.
+*/
+func (c *Device) Screenshot() (image.Image, error) {
+	return c.ScreenshotContext(context.Background())
+}
+
+// ScreenshotContext is like Screenshot, but gives up as soon as ctx is done.
+func (c *Device) ScreenshotContext(ctx context.Context) (image.Image, error) {
+	return c.ScreenshotWithOptions(ctx, ScreenshotOptions{})
+}
+
+// ScreenshotWithOptions is like ScreenshotContext, but allows capturing other displays, which
+// is always done with screencap -p.
+func (c *Device) ScreenshotWithOptions(ctx context.Context, opts ScreenshotOptions) (image.Image, error) {
+	if opts.Display == "" && !opts.PNG {
+		img, err := c.framebuffer(ctx)
+		// The framebuffer service sends nothing when it fails, and older devices send formats
+		// that aren't supported.
+		if err == nil || !HasErrCode(err, ParseError) && !HasErrCode(err, AdbError) {
+			return img, err
+		}
+	}
+	return c.screencap(ctx, opts.Display)
+}
+
+func (c *Device) framebuffer(ctx context.Context) (image.Image, error) {
+	conn, err := c.dialDevice(ctx)
+	if err != nil {
+		return nil, wrapClientError(err, c, "Screenshot")
+	}
+	defer conn.Close()
+
+	const req = "framebuffer:"
+	if err := conn.SendMessage([]byte(req)); err != nil {
+		return nil, wrapClientError(wrapContextErr(ctx, err), c, "Screenshot")
+	}
+	if _, err := conn.ReadStatus(req); err != nil {
+		return nil, wrapClientError(wrapContextErr(ctx, err), c, "Screenshot")
+	}
+
+	img, _, err := DecodeFramebuffer(&contextReadCloser{ctx, conn})
+	if err != nil {
+		return nil, wrapClientError(err, c, "Screenshot")
+	}
+	return img, nil
+}
+
+func (c *Device) screencap(ctx context.Context, display string) (image.Image, error) {
+	args := []string{"-p"}
+	if display != "" {
+		if strings.Trim(display, "0123456789") != "" {
+			return nil, wrapClientError(errors.AssertionErrorf("invalid display id: %s", display), c, "Screenshot")
+		}
+		args = append(args, "-d", display)
+	}
+	output, err := c.runCommand(ctx, "Screenshot", "screencap", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// screencap prints its errors rather than an image, e.g. for displays that don't exist.
+	if !bytes.HasPrefix(output, []byte("\x89PNG")) {
+		err = errors.Errorf(errors.AdbError, "screencap failed: %s", strings.TrimSpace(string(output)))
+		return nil, wrapClientError(err, c, "Screenshot")
+	}
+	img, err := png.Decode(bytes.NewReader(output))
+	if err != nil {
+		err = errors.WrapErrorf(err, errors.ParseError, "invalid screencap image")
+	}
+	return img, wrapClientError(err, c, "Screenshot")
+}
+
+/*
+DecodeFramebuffer decodes the output of the framebuffer service, which is a header of
+little-endian 32-bit integers followed by the pixels. The header depends on its version:
+	1: version, bpp, size, width, height, red offset and length, blue..., green..., alpha...
+	2: version, bpp, color space, size, width, height, red offset and length, ...
+	16: version, size, width, height, with RGB565 pixels
+*/
+func DecodeFramebuffer(r io.Reader) (*image.NRGBA, *FramebufferHeader, error) {
+	header, err := readFramebufferHeader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	bytesPerPixel := header.BPP / 8
+	if header.BPP%8 != 0 || bytesPerPixel < 1 || bytesPerPixel > 4 {
+		return nil, nil, errors.Errorf(errors.ParseError, "unsupported framebuffer depth: %d bpp", header.BPP)
+	}
+	if header.Width <= 0 || header.Height <= 0 || header.Width > maxFramebufferSide || header.Height > maxFramebufferSide ||
+		header.Width*header.Height > maxFramebufferPixels || header.Size < header.Width*header.Height*bytesPerPixel {
+		return nil, nil, errors.Errorf(errors.ParseError, "invalid framebuffer size: %dx%d, %d bytes",
+			header.Width, header.Height, header.Size)
+	}
+	for _, channel := range []ColorChannel{header.Red, header.Green, header.Blue, header.Alpha} {
+		if channel.Offset < 0 || channel.Length < 0 || channel.Length > 8 || channel.Offset+channel.Length > header.BPP {
+			return nil, nil, errors.Errorf(errors.ParseError, "invalid framebuffer color channel: %+v", channel)
+		}
+	}
+
+	// Size comes from the device and may be anything up to 4 GiB, so only the pixels are read.
+	data := make([]byte, header.Width*header.Height*bytesPerPixel)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, nil, wrapFramebufferReadErr(err)
+	}
+	// Drain the rest, which some devices pad the pixels with.
+	io.Copy(ioutil.Discard, r)
+
+	img := image.NewNRGBA(image.Rect(0, 0, header.Width, header.Height))
+	n := header.Width * header.Height
+	if header.BPP == 32 && header.Red == (ColorChannel{0, 8}) && header.Green == (ColorChannel{8, 8}) &&
+		header.Blue == (ColorChannel{16, 8}) && (header.Alpha == (ColorChannel{24, 8}) || header.Alpha.Length == 0) {
+		// RGBA_8888 and RGBX_8888, which most devices use, are already in the right layout.
+		copy(img.Pix, data[:n*4])
+		if header.Alpha.Length == 0 {
+			for i := 3; i < len(img.Pix); i += 4 {
+				img.Pix[i] = 0xff
+			}
+		}
+		return img, header, nil
+	}
+
+	for i := 0; i < n; i++ {
+		var pixel uint32
+		for j := bytesPerPixel - 1; j >= 0; j-- {
+			pixel = pixel<<8 | uint32(data[i*bytesPerPixel+j])
+		}
+		img.Pix[i*4] = header.Red.value(pixel, 0)
+		img.Pix[i*4+1] = header.Green.value(pixel, 0)
+		img.Pix[i*4+2] = header.Blue.value(pixel, 0)
+		img.Pix[i*4+3] = header.Alpha.value(pixel, 0xff)
+	}
+	return img, header, nil
+}
+
+// value returns the value of the channel in pixel scaled to 8 bits, or missing if the pixel
+// doesn't have the channel.
+func (c ColorChannel) value(pixel uint32, missing uint8) uint8 {
+	if c.Length == 0 {
+		return missing
+	}
+	max := uint32(1)<<uint(c.Length) - 1
+	return uint8((pixel >> uint(c.Offset) & max) * 0xff / max)
+}
+
+func readFramebufferHeader(r io.Reader) (*FramebufferHeader, error) {
+	var version uint32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, wrapFramebufferReadErr(err)
+	}
+	header := &FramebufferHeader{Version: int(version)}
+
+	var fields []uint32
+	switch version {
+	case framebufferV1:
+		fields = make([]uint32, 12)
+	case framebufferV2:
+		fields = make([]uint32, 13)
+	case framebufferRGB565:
+		fields = make([]uint32, 3)
+	default:
+		return nil, errors.Errorf(errors.ParseError, "unsupported framebuffer version: %d", version)
+	}
+	if err := binary.Read(r, binary.LittleEndian, fields); err != nil {
+		return nil, wrapFramebufferReadErr(err)
+	}
+	next := func() int {
+		field := int(fields[0])
+		fields = fields[1:]
+		return field
+	}
+
+	if version == framebufferRGB565 {
+		header.BPP = 16
+		header.Size, header.Width, header.Height = next(), next(), next()
+		header.Red = ColorChannel{11, 5}
+		header.Green = ColorChannel{5, 6}
+		header.Blue = ColorChannel{0, 5}
+		return header, nil
+	}
+
+	header.BPP = next()
+	if version >= framebufferV2 {
+		header.ColorSpace = ColorSpace(next())
+	}
+	header.Size, header.Width, header.Height = next(), next(), next()
+	// The channels are in this order.
+	for _, channel := range []*ColorChannel{&header.Red, &header.Blue, &header.Green, &header.Alpha} {
+		channel.Offset, channel.Length = next(), next()
+	}
+	return header, nil
+}
+
+func wrapFramebufferReadErr(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.WrapErrorf(err, errors.ParseError, "truncated framebuffer")
+	}
+	if _, ok := err.(*errors.Err); ok {
+		return err
+	}
+	return errors.WrapErrorf(err, errors.NetworkError, "error reading framebuffer")
+}
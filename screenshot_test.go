@@ -0,0 +1,144 @@
+package adb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/kvnxiao/go-adb/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeFramebuffer(header []uint32, pixels []byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, header)
+	buf.Write(pixels)
+	return buf.Bytes()
+}
+
+func TestDecodeFramebuffer(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		data   []byte
+		header FramebufferHeader
+	}{
+		{
+			"v1 RGBA_8888",
+			encodeFramebuffer([]uint32{1, 32, 8, 2, 1, 0, 8, 16, 8, 8, 8, 24, 8},
+				[]byte{0xff, 0x80, 0x00, 0xff, 0x10, 0x20, 0x30, 0x40}),
+			FramebufferHeader{Version: 1, BPP: 32, Size: 8, Width: 2, Height: 1,
+				Red: ColorChannel{0, 8}, Green: ColorChannel{8, 8}, Blue: ColorChannel{16, 8}, Alpha: ColorChannel{24, 8}},
+		},
+		{
+			"v2 RGBX_8888",
+			encodeFramebuffer([]uint32{2, 32, 2, 8, 1, 2, 0, 8, 16, 8, 8, 8, 24, 0},
+				[]byte{0xff, 0x80, 0x00, 0x00, 0x10, 0x20, 0x30, 0x00}),
+			FramebufferHeader{Version: 2, BPP: 32, ColorSpace: ColorSpaceDisplayP3, Size: 8, Width: 1, Height: 2,
+				Red: ColorChannel{0, 8}, Green: ColorChannel{8, 8}, Blue: ColorChannel{16, 8}, Alpha: ColorChannel{24, 0}},
+		},
+		{
+			"v1 BGRA_8888",
+			encodeFramebuffer([]uint32{1, 32, 8, 2, 1, 16, 8, 0, 8, 8, 8, 24, 8},
+				[]byte{0x00, 0x80, 0xff, 0xff, 0x30, 0x20, 0x10, 0x40}),
+			FramebufferHeader{Version: 1, BPP: 32, Size: 8, Width: 2, Height: 1,
+				Red: ColorChannel{16, 8}, Green: ColorChannel{8, 8}, Blue: ColorChannel{0, 8}, Alpha: ColorChannel{24, 8}},
+		},
+	} {
+		img, header, err := DecodeFramebuffer(bytes.NewReader(test.data))
+		require.NoError(t, err, test.name)
+		assert.Equal(t, test.header, *header, test.name)
+
+		pixels := []color.NRGBA{img.NRGBAAt(0, 0), img.NRGBAAt(1, 0)}
+		if header.Height == 2 {
+			pixels[1] = img.NRGBAAt(0, 1)
+		}
+		second := color.NRGBA{0x10, 0x20, 0x30, 0x40}
+		if header.Alpha.Length == 0 {
+			second.A = 0xff
+		}
+		assert.Equal(t, []color.NRGBA{{0xff, 0x80, 0x00, 0xff}, second}, pixels, test.name)
+	}
+}
+
+func TestDecodeFramebufferRGB565(t *testing.T) {
+	// Pure red and pure green.
+	data := encodeFramebuffer([]uint32{16, 4, 2, 1}, []byte{0x00, 0xf8, 0xe0, 0x07})
+	img, header, err := DecodeFramebuffer(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 16, header.BPP)
+	assert.Equal(t, color.NRGBA{0xff, 0, 0, 0xff}, img.NRGBAAt(0, 0))
+	assert.Equal(t, color.NRGBA{0, 0xff, 0, 0xff}, img.NRGBAAt(1, 0))
+}
+
+func TestDecodeFramebufferHugeSize(t *testing.T) {
+	// Only the pixels are read, whatever size the header claims.
+	data := encodeFramebuffer([]uint32{1, 32, 0xffffffff, 2, 1, 0, 8, 16, 8, 8, 8, 24, 8},
+		[]byte{0xff, 0x80, 0x00, 0xff, 0x10, 0x20, 0x30, 0x40, 0, 0})
+	img, header, err := DecodeFramebuffer(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 0xffffffff, header.Size)
+	assert.Equal(t, color.NRGBA{0x10, 0x20, 0x30, 0x40}, img.NRGBAAt(1, 0))
+}
+
+func TestDecodeFramebufferTooLarge(t *testing.T) {
+	// The pixels would take 4 GiB, which isn't allocated.
+	data := encodeFramebuffer([]uint32{1, 32, 0xffffffff, 1 << 15, 1 << 15, 0, 8, 8, 8, 16, 8, 24, 8}, nil)
+	_, _, err := DecodeFramebuffer(bytes.NewReader(data))
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+	assert.Contains(t, err.Error(), "invalid framebuffer size: 32768x32768")
+}
+
+func TestDecodeFramebufferInvalid(t *testing.T) {
+	for _, data := range [][]byte{
+		nil,
+		encodeFramebuffer([]uint32{3}, nil),
+		encodeFramebuffer([]uint32{1, 32, 8, 2, 1}, nil),
+		encodeFramebuffer([]uint32{1, 32, 8, 2, 1, 0, 8, 8, 8, 16, 8, 24, 8}, []byte{1, 2, 3}),
+		encodeFramebuffer([]uint32{1, 32, 4, 2, 1, 0, 8, 8, 8, 16, 8, 24, 8}, []byte{1, 2, 3, 4}),
+		encodeFramebuffer([]uint32{1, 12, 3, 2, 1, 0, 4, 4, 4, 8, 4, 0, 0}, []byte{1, 2, 3}),
+	} {
+		_, _, err := DecodeFramebuffer(bytes.NewReader(data))
+		assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+	}
+}
+
+func TestScreenshotFramebuffer(t *testing.T) {
+	data := encodeFramebuffer([]uint32{1, 32, 4, 1, 1, 0, 8, 16, 8, 8, 8, 24, 8}, []byte{1, 2, 3, 4})
+	s := &MockServer{
+		Status:   wire.StatusSuccess,
+		Messages: []string{string(data[:20]), string(data[20:])},
+	}
+	img, err := (&Adb{s}).Device(AnyDevice()).Screenshot()
+	require.NoError(t, err)
+	assert.Equal(t, "framebuffer:", s.Requests[1])
+	assert.Equal(t, color.NRGBA{1, 2, 3, 4}, img.(*image.NRGBA).NRGBAAt(0, 0))
+}
+
+func TestScreenshotDisplay(t *testing.T) {
+	var buf bytes.Buffer
+	expected := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	expected.SetNRGBA(1, 1, color.NRGBA{10, 20, 30, 255})
+	require.NoError(t, png.Encode(&buf, expected))
+
+	s := &MockServer{Status: wire.StatusSuccess, Messages: []string{buf.String()}}
+	client := (&Adb{s}).Device(AnyDevice())
+	img, err := client.ScreenshotWithOptions(context.Background(), ScreenshotOptions{Display: "4619827259835644672"})
+	require.NoError(t, err)
+	assert.Equal(t, "exec:screencap -p -d 4619827259835644672", s.Requests[1])
+	r, g, b, _ := img.At(1, 1).RGBA()
+	assert.Equal(t, []uint32{10, 20, 30}, []uint32{r >> 8, g >> 8, b >> 8})
+
+	s = &MockServer{Status: wire.StatusSuccess, Messages: []string{"Display not found\n"}}
+	_, err = (&Adb{s}).Device(AnyDevice()).ScreenshotWithOptions(context.Background(), ScreenshotOptions{Display: "42"})
+	assert.True(t, HasErrCode(err, AdbError))
+	assert.Contains(t, ErrorWithCauseChain(err), "Display not found")
+
+	_, err = client.ScreenshotWithOptions(context.Background(), ScreenshotOptions{Display: "1; reboot"})
+	assert.True(t, HasErrCode(err, AssertionError))
+}
@@ -1,6 +1,7 @@
 package adb
 
 import (
+	"context"
 	stderrors "errors"
 	"fmt"
 	"os"
@@ -37,25 +38,29 @@ type ServerConfig struct {
 type server interface {
 	Start() error
 	Dial() (*wire.Conn, error)
+
+	// DialContext is like Dial, but the returned connection is closed as soon as ctx is done.
+	DialContext(ctx context.Context) (*wire.Conn, error)
 }
 
-func roundTripSingleResponse(s server, req string) ([]byte, error) {
-	conn, err := s.Dial()
+func roundTripSingleResponse(ctx context.Context, s server, req string) ([]byte, error) {
+	conn, err := s.DialContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
 
-	return conn.RoundTripSingleResponse([]byte(req))
+	resp, err := conn.RoundTripSingleResponse([]byte(req))
+	return resp, wrapContextErr(ctx, err)
 }
 
-func roundTripSingleNoResponse(s server, req string) error {
-	conn, err := s.Dial()
+func roundTripSingleNoResponse(ctx context.Context, s server, req string) error {
+	conn, err := s.DialContext(ctx)
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
-	return conn.RoundTripSingleNoResponse([]byte(req))
+	return wrapContextErr(ctx, conn.RoundTripSingleNoResponse([]byte(req)))
 }
 
 type realServer struct {
@@ -102,21 +107,43 @@ func newServer(config ServerConfig) (server, error) {
 // Dial tries to connect to the server. If the first attempt fails, tries starting the server before
 // retrying. If the second attempt fails, returns the error.
 func (s *realServer) Dial() (*wire.Conn, error) {
-	conn, err := s.config.Dial(s.address)
+	return s.DialContext(context.Background())
+}
+
+// DialContext is like Dial, but gives up as soon as ctx is done. The returned connection is
+// closed if ctx is done before the connection is.
+func (s *realServer) DialContext(ctx context.Context) (*wire.Conn, error) {
+	conn, err := s.dial(ctx)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, wrapContextErr(ctx, err)
+		}
+
 		// Attempt to start the server and try again.
 		if err = s.Start(); err != nil {
 			return nil, errors.WrapErrorf(err, errors.ServerNotAvailable, "error starting server for dial")
 		}
 
-		conn, err = s.config.Dial(s.address)
+		conn, err = s.dial(ctx)
 		if err != nil {
-			return nil, err
+			return nil, wrapContextErr(ctx, err)
 		}
 	}
+
+	conn.WatchContext(ctx)
 	return conn, nil
 }
 
+func (s *realServer) dial(ctx context.Context) (*wire.Conn, error) {
+	if dialer, ok := s.config.Dialer.(ContextDialer); ok {
+		return dialer.DialContext(ctx, s.address)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.config.Dial(s.address)
+}
+
 // StartServer ensures there is a server running.
 func (s *realServer) Start() error {
 	output, err := s.config.fs.CmdCombinedOutput(s.config.PathToAdb, "-L", fmt.Sprintf("tcp:%s", s.address), "start-server")
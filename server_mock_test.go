@@ -1,6 +1,7 @@
 package adb
 
 import (
+	"context"
 	"io"
 	"strings"
 
@@ -20,6 +21,7 @@ type MockServer struct {
 	// Messages are returned from read calls in order, each preceded by a length header.
 	Messages     []string
 	nextMsgIndex int
+	readOffset   int
 
 	// Each message passed to a send call is appended to this slice.
 	Requests []string
@@ -38,6 +40,10 @@ func (s *MockServer) Dial() (*wire.Conn, error) {
 	return wire.NewConn(s, s), nil
 }
 
+func (s *MockServer) DialContext(ctx context.Context) (*wire.Conn, error) {
+	return s.Dial()
+}
+
 func (s *MockServer) Start() error {
 	s.logMethod("Start")
 	return nil
@@ -57,11 +63,18 @@ func (s *MockServer) Read(p []byte) (int, error) {
 		return 0, err
 	}
 	if s.nextMsgIndex >= len(s.Messages) {
-		return 0, errors.WrapErrorf(io.EOF, errors.NetworkError, "")
+		return 0, io.EOF
 	}
 
-	s.nextMsgIndex++
-	return len(p), nil
+	// Messages are read as a stream, without their length headers.
+	msg := s.Messages[s.nextMsgIndex][s.readOffset:]
+	n := copy(p, msg)
+	s.readOffset += n
+	if n == len(msg) {
+		s.nextMsgIndex++
+		s.readOffset = 0
+	}
+	return n, nil
 }
 
 func (s *MockServer) Write(p []byte) (int, error) {
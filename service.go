@@ -0,0 +1,165 @@
+package adb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/kvnxiao/go-adb/wire"
+)
+
+/*
+ServiceError is the failure reported by a system service when running one of its commands, e.g.
+	java.lang.SecurityException: Package com.example.app has not requested permission android.permission.CAMERA
+
+Errors returned by the activity manager, permission and app-ops methods of Device wrap it, use errors.As to get it.
+*/
+type ServiceError struct {
+	// Service that failed, e.g. "activity", "package" or "appops".
+	Service string
+
+	// Command that failed, e.g. "start" or "grant".
+	Command string
+
+	// Exception is the class of the exception thrown by the service, e.g.
+	// java.lang.SecurityException, empty if the command failed without one.
+	Exception string
+
+	Message string
+}
+
+func (e *ServiceError) Error() string {
+	if e.Exception == "" {
+		return fmt.Sprintf("%s %s failed: %s", e.Service, e.Command, e.Message)
+	}
+	return fmt.Sprintf("%s %s failed: %s: %s", e.Service, e.Command, e.Exception, e.Message)
+}
+
+/*
+runServiceCommand runs the command of the system service service (e.g. "package" or "appops")
+with args, streaming size bytes read from r to its stdin if r isn't nil, and returns its output.
+
+Uses abb_exec if the device supports it, else cmd, else the service's own command, e.g. pm.
+*/
+func (c *Device) runServiceCommand(ctx context.Context, service string, r io.Reader, size int64, args ...string) (string, error) {
+	conn, err := c.openServiceCommand(ctx, service, args...)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if r != nil {
+		if _, err := io.CopyN(conn, r, size); err != nil {
+			if _, ok := err.(*errors.Err); !ok {
+				err = errors.WrapErrorf(err, errors.NetworkError, "error streaming to %s", service)
+			}
+			return "", wrapContextErr(ctx, err)
+		}
+	}
+
+	output, err := conn.ReadUntilEof()
+	return string(output), wrapContextErr(ctx, err)
+}
+
+// openServiceCommand opens a connection to the command of service running args.
+func (c *Device) openServiceCommand(ctx context.Context, service string, args ...string) (*wire.Conn, error) {
+	features, err := c.FeaturesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req := serviceCommand(features, service, args...)
+
+	conn, err := c.dialDevice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SendMessage([]byte(req)); err != nil {
+		conn.Close()
+		return nil, wrapContextErr(ctx, err)
+	}
+	if _, err := conn.ReadStatus(req); err != nil {
+		conn.Close()
+		return nil, wrapContextErr(ctx, err)
+	}
+	return conn, nil
+}
+
+// serviceCommands are the commands that talk to system services on devices that don't have cmd,
+// by service name. Services that aren't listed have a command with the same name.
+var serviceCommands = map[string]string{
+	"activity": "am",
+	"package":  "pm",
+}
+
+// serviceCommand returns the adb service that runs the command of service with args on a
+// device that supports features.
+func serviceCommand(features []string, service string, args ...string) string {
+	supported := map[string]bool{}
+	for _, feature := range features {
+		supported[feature] = true
+	}
+
+	// abb_exec takes the arguments separated by NULs, without going through a shell.
+	if supported[FeatureAbbExec] {
+		return "abb_exec:" + strings.Join(append([]string{service}, args...), "\x00")
+	}
+
+	cmd, cmdArgs := service, args
+	if command, ok := serviceCommands[service]; ok {
+		cmd = command
+	}
+	if supported[FeatureCmd] {
+		cmd, cmdArgs = "cmd", append([]string{service}, args...)
+	}
+	// Arguments are quoted for the shell, so they're passed to the command unchanged, like with
+	// abb_exec.
+	cmdLine := cmd
+	for _, arg := range cmdArgs {
+		cmdLine += " " + quoteShellArg(arg)
+	}
+	return "exec:" + cmdLine
+}
+
+var reServiceException = regexp.MustCompile(`([A-Za-z_$][\w$]*(?:\.[A-Za-z_$][\w$]*)+(?:Exception|Error)): (.*)`)
+
+/*
+parseServiceOutput returns an error that wraps a *ServiceError if output reports that command
+of service failed, in any of the formats used by different versions of Android, e.g.
+	Exception occurred while executing 'grant':
+	java.lang.SecurityException: Package com.example.app has not requested permission android.permission.CAMERA
+	    at com.android.server.pm.permission...
+or
+	Operation not allowed: java.lang.SecurityException: ...
+or
+	Error type 3
+	Error: Activity class {com.example.app/com.example.app.Missing} does not exist.
+or
+	Security exception: Permission Denial: starting Intent { ... } not exported from uid 10042
+*/
+func parseServiceOutput(service, command, output string) error {
+	var serviceErr *ServiceError
+	if matches := reServiceException.FindStringSubmatch(output); matches != nil {
+		serviceErr = &ServiceError{Exception: matches[1], Message: matches[2]}
+	} else {
+		for _, line := range strings.Split(output, "\n") {
+			if strings.HasPrefix(line, "Error: ") {
+				serviceErr = &ServiceError{Message: strings.TrimPrefix(line, "Error: ")}
+				break
+			}
+			if strings.HasPrefix(line, "Security exception: ") {
+				serviceErr = &ServiceError{Exception: "java.lang.SecurityException",
+					Message: strings.TrimPrefix(line, "Security exception: ")}
+				break
+			}
+		}
+	}
+	if serviceErr == nil {
+		return nil
+	}
+	serviceErr.Service, serviceErr.Command = service, command
+	serviceErr.Message = strings.TrimSpace(serviceErr.Message)
+	return errors.WrapErrorf(serviceErr, errors.AdbError, "%s", serviceErr)
+}
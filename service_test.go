@@ -0,0 +1,82 @@
+package adb
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceCommand(t *testing.T) {
+	args := []string{"install", "-S", "42", "-r"}
+
+	service := serviceCommand([]string{FeatureShell2, FeatureCmd, FeatureAbbExec}, "package", args...)
+	assert.Equal(t, "abb_exec:package\x00install\x00-S\x0042\x00-r", service)
+
+	service = serviceCommand([]string{FeatureShell2, FeatureCmd}, "package", args...)
+	assert.Equal(t, "exec:cmd package install -S 42 -r", service)
+
+	service = serviceCommand(nil, "package", args...)
+	assert.Equal(t, "exec:pm install -S 42 -r", service)
+
+	service = serviceCommand(nil, "appops", "get", "com.example.app")
+	assert.Equal(t, "exec:appops get com.example.app", service)
+
+	service = serviceCommand(nil, "activity", "force-stop", "com.example.app")
+	assert.Equal(t, "exec:am force-stop com.example.app", service)
+
+	service = serviceCommand(nil, "package", "install", "/data/local/tmp/a;reboot.apk", "$(id)", "`id`", "*", "a&b", `it's "$HOME"`, "")
+	assert.Equal(t, `exec:pm install '/data/local/tmp/a;reboot.apk' '$(id)' '`+"`id`"+`' '*' 'a&b' 'it'\''s "$HOME"' ''`, service)
+}
+
+func TestParseServiceOutput(t *testing.T) {
+	assert.NoError(t, parseServiceOutput("package", "grant", ""))
+	assert.NoError(t, parseServiceOutput("activity", "start", "Starting: Intent { cmp=com.example.app/.Main }\n"))
+
+	var serviceErr *ServiceError
+	for _, output := range []string{
+		"Exception occurred while executing 'grant':\n" +
+			"java.lang.SecurityException: Package com.example.app has not requested permission android.permission.CAMERA\n" +
+			"\tat com.android.server.pm.permission.PermissionManagerServiceImpl.grantRuntimePermissionInternal(PermissionManagerServiceImpl.java:1429)\n",
+		"Operation not allowed: java.lang.SecurityException: Package com.example.app has not requested permission android.permission.CAMERA\n",
+	} {
+		err := parseServiceOutput("package", "grant", output)
+		require.True(t, stderrors.As(err, &serviceErr), output)
+		assert.Equal(t, &ServiceError{
+			Service:   "package",
+			Command:   "grant",
+			Exception: "java.lang.SecurityException",
+			Message:   "Package com.example.app has not requested permission android.permission.CAMERA",
+		}, serviceErr)
+		assert.Equal(t, errors.AdbError, err.(*errors.Err).Code)
+	}
+
+	err := parseServiceOutput("appops", "set", "Error: Unknown operation string: FOO\n")
+	require.True(t, stderrors.As(err, &serviceErr))
+	assert.Equal(t, &ServiceError{Service: "appops", Command: "set", Message: "Unknown operation string: FOO"}, serviceErr)
+	assert.Equal(t, "appops set failed: Unknown operation string: FOO", serviceErr.Error())
+
+	err = parseServiceOutput("activity", "start", "Starting: Intent { cmp=com.example.app/.Missing }\n"+
+		"Error type 3\n"+
+		"Error: Activity class {com.example.app/com.example.app.Missing} does not exist.\n")
+	require.True(t, stderrors.As(err, &serviceErr))
+	assert.Equal(t, &ServiceError{
+		Service: "activity",
+		Command: "start",
+		Message: "Activity class {com.example.app/com.example.app.Missing} does not exist.",
+	}, serviceErr)
+
+	err = parseServiceOutput("activity", "start", "Starting: Intent { cmp=com.example.app/.Private }\n"+
+		"Security exception: Permission Denial: starting Intent { cmp=com.example.app/.Private } not exported from uid 10042\n")
+	require.True(t, stderrors.As(err, &serviceErr))
+	assert.Equal(t, "java.lang.SecurityException", serviceErr.Exception)
+	assert.Equal(t, "Permission Denial: starting Intent { cmp=com.example.app/.Private } not exported from uid 10042",
+		serviceErr.Message)
+
+	err = parseServiceOutput("activity", "broadcast", "Exception occurred while executing 'broadcast':\n"+
+		"java.lang.IllegalArgumentException: Unknown option: --foo\n")
+	require.True(t, stderrors.As(err, &serviceErr))
+	assert.Equal(t, "activity broadcast failed: java.lang.IllegalArgumentException: Unknown option: --foo", serviceErr.Error())
+}
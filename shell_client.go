@@ -0,0 +1,84 @@
+package adb
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/kvnxiao/go-adb/wire"
+)
+
+// ShellResult holds the output of a command run on the device and its exit status.
+type ShellResult struct {
+	Stdout []byte
+	Stderr []byte
+
+	// ExitCode is the real exit status of the command when the device supports the
+	// shell protocol, otherwise it's parsed from the output of the command.
+	ExitCode int
+}
+
+// openShellProtocol requests service (e.g. "shell,v2,raw:ls") on conn and switches it
+// to shell protocol mode.
+func openShellProtocol(conn *wire.Conn, service string) (*wire.ShellConn, error) {
+	if err := conn.SendMessage([]byte(service)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.ReadStatus(service); err != nil {
+		return nil, err
+	}
+	return conn.NewShellConn(), nil
+}
+
+// readShellResult reads packets from s until the exit packet is received, collecting
+// stdout and stderr separately.
+func readShellResult(s wire.ShellScanner) (*ShellResult, error) {
+	var stdout, stderr bytes.Buffer
+
+	for {
+		id, data, err := s.ReadPacket()
+		if err == io.EOF {
+			return nil, errors.Errorf(errors.ConnectionResetError, "shell closed before sending exit status")
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch id {
+		case wire.ShellIDStdout:
+			stdout.Write(data)
+		case wire.ShellIDStderr:
+			stderr.Write(data)
+		case wire.ShellIDExit:
+			if len(data) != 1 {
+				return nil, errors.Errorf(errors.ParseError, "expected 1 byte exit status, but got %d", len(data))
+			}
+			return &ShellResult{
+				Stdout:   stdout.Bytes(),
+				Stderr:   stderr.Bytes(),
+				ExitCode: int(data[0]),
+			}, nil
+		default:
+			// Other packet types are only ever sent from the client to the device.
+			return nil, errors.Errorf(errors.AssertionError, "unexpected shell packet id %d", id)
+		}
+	}
+}
+
+// parseEchoedExitCode splits the output of a command that had "; echo :$?" appended to it
+// into the real output and the exit code.
+func parseEchoedExitCode(output string) (*ShellResult, error) {
+	idx := strings.LastIndexByte(output, ':')
+	if idx == -1 {
+		return nil, errors.Errorf(errors.ParseError, "adb shell aborted, can not parse exit code")
+	}
+	exitCode, err := strconv.Atoi(strings.TrimSpace(output[idx+1:]))
+	if err != nil {
+		return nil, errors.WrapErrorf(err, errors.ParseError, "can not parse exit code: %s", output[idx+1:])
+	}
+	return &ShellResult{
+		Stdout:   []byte(output[:idx]),
+		ExitCode: exitCode,
+	}, nil
+}
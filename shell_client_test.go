@@ -0,0 +1,47 @@
+package adb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/kvnxiao/go-adb/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadShellResult(t *testing.T) {
+	var buf bytes.Buffer
+	sender := wire.NewShellSender(&buf)
+	sender.SendPacket(wire.ShellIDStdout, []byte("out: 1\n"))
+	sender.SendPacket(wire.ShellIDStderr, []byte("err: 2\n"))
+	sender.SendPacket(wire.ShellIDStdout, []byte("out: 3\n"))
+	sender.SendPacket(wire.ShellIDExit, []byte{42})
+
+	result, err := readShellResult(wire.NewShellScanner(&buf))
+	assert.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, "out: 1\nout: 3\n", string(result.Stdout))
+	assert.Equal(t, "err: 2\n", string(result.Stderr))
+	assert.Equal(t, 42, result.ExitCode)
+}
+
+func TestReadShellResultNoExit(t *testing.T) {
+	var buf bytes.Buffer
+	sender := wire.NewShellSender(&buf)
+	sender.SendPacket(wire.ShellIDStdout, []byte("out"))
+
+	result, err := readShellResult(wire.NewShellScanner(&buf))
+	assert.Nil(t, result)
+	assert.Equal(t, errors.ConnectionResetError, code(err))
+}
+
+func TestParseEchoedExitCode(t *testing.T) {
+	result, err := parseEchoedExitCode("a:b:c\n:1\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "a:b:c\n", string(result.Stdout))
+	assert.Equal(t, 1, result.ExitCode)
+
+	_, err = parseEchoedExitCode("no exit code")
+	assert.Equal(t, errors.ParseError, code(err))
+}
@@ -0,0 +1,270 @@
+package adb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/kvnxiao/go-adb/wire"
+)
+
+// ShellOptions configures a session opened by OpenShell.
+type ShellOptions struct {
+	// Raw disables allocating a pseudo-terminal for the session, stdout and stderr are then
+	// kept separate and no terminal processing is done on the device.
+	Raw bool
+
+	// Term is the value of the TERM environment variable in the session. Only used with a PTY.
+	Term string
+}
+
+/*
+ShellSession is a shell running on the device.
+
+Stdout and Stderr must both be read until EOF (or the session closed), reading
+packets for one stream blocks until the previous packet on the other has been consumed.
+
+When the device doesn't support the shell protocol, the session falls back to the legacy
+shell service: Stderr is always empty, window size changes are ignored and the exit code
+reported by Wait is always 0.
+*/
+type ShellSession struct {
+	// Nil when running on the legacy shell service.
+	shellConn *wire.ShellConn
+	conn      *wire.Conn
+
+	// Guards sending packets, since stdin and window size changes are usually sent
+	// from different goroutines.
+	sendLock sync.Mutex
+
+	stdout       *io.PipeReader
+	stdoutWriter *io.PipeWriter
+	stderr       *io.PipeReader
+	stderrWriter *io.PipeWriter
+
+	// Errors are reported as ContextCanceled once ctx is done.
+	ctx context.Context
+
+	done     chan struct{}
+	exitCode int
+	err      error
+}
+
+/*
+OpenShell starts cmd on the device and returns a session to interact with it. If cmd is empty,
+an interactive login shell is started.
+
+Corresponds to the command:
+	adb shell [cmd [args...]]
+*/
+func (c *Device) OpenShell(opts ShellOptions, cmd string, args ...string) (*ShellSession, error) {
+	return c.OpenShellContext(context.Background(), opts, cmd, args...)
+}
+
+// OpenShellContext is like OpenShell, but the session is closed as soon as ctx is done.
+func (c *Device) OpenShellContext(ctx context.Context, opts ShellOptions, cmd string, args ...string) (*ShellSession, error) {
+	hasShellV2, err := c.HasFeatureContext(ctx, FeatureShell2)
+	if err != nil {
+		return nil, wrapClientError(err, c, "OpenShell")
+	}
+
+	if !hasShellV2 {
+		session, err := c.openLegacyShell(ctx, cmd, args...)
+		return session, wrapClientError(err, c, "OpenShell")
+	}
+
+	var modeArgs []string
+	if opts.Term != "" && !opts.Raw {
+		modeArgs = append(modeArgs, "TERM="+opts.Term)
+	}
+	if opts.Raw {
+		modeArgs = append(modeArgs, "raw")
+	} else {
+		modeArgs = append(modeArgs, "pty")
+	}
+
+	conn, err := c.openShellV2(ctx, strings.Join(modeArgs, ","), cmd, args...)
+	if err != nil {
+		return nil, wrapClientError(err, c, "OpenShell")
+	}
+
+	session := newShellSession(ctx, conn, nil)
+	go session.readPackets()
+	return session, nil
+}
+
+func (c *Device) openLegacyShell(ctx context.Context, cmd string, args ...string) (*ShellSession, error) {
+	var err error
+	if cmd != "" {
+		cmd, err = prepareCommandLine(cmd, args...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	conn, err := c.dialDevice(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := "shell:" + cmd
+	if err = conn.SendMessage([]byte(req)); err != nil {
+		conn.Close()
+		return nil, wrapContextErr(ctx, err)
+	}
+	if _, err = conn.ReadStatus(req); err != nil {
+		conn.Close()
+		return nil, wrapContextErr(ctx, err)
+	}
+
+	session := newShellSession(ctx, nil, conn)
+	go session.copyLegacyOutput()
+	return session, nil
+}
+
+func newShellSession(ctx context.Context, shellConn *wire.ShellConn, conn *wire.Conn) *ShellSession {
+	session := &ShellSession{
+		shellConn: shellConn,
+		conn:      conn,
+		ctx:       ctx,
+		done:      make(chan struct{}),
+	}
+	session.stdout, session.stdoutWriter = io.Pipe()
+	session.stderr, session.stderrWriter = io.Pipe()
+	return session
+}
+
+// Stdin returns a writer connected to the standard input of the session.
+// Closing it closes the standard input of the command on the device, but leaves the
+// session running.
+func (s *ShellSession) Stdin() io.WriteCloser {
+	return shellStdinWriter{s}
+}
+
+// Stdout returns a reader connected to the standard output of the session.
+func (s *ShellSession) Stdout() io.Reader {
+	return s.stdout
+}
+
+// Stderr returns a reader connected to the standard error of the session.
+// With a PTY, the device writes everything to Stdout.
+func (s *ShellSession) Stderr() io.Reader {
+	return s.stderr
+}
+
+// SetWindowSize tells the device the terminal has been resized.
+// Only has an effect on sessions with a PTY.
+func (s *ShellSession) SetWindowSize(rows, cols, xPixels, yPixels int) error {
+	if s.shellConn == nil {
+		return nil
+	}
+	// The device parses this with sscanf, so it needs to be null-terminated.
+	size := fmt.Sprintf("%dx%d,%dx%d\x00", rows, cols, xPixels, yPixels)
+	return s.sendPacket(wire.ShellIDWindowSizeChange, []byte(size))
+}
+
+// Wait blocks until the command on the device exits and returns its exit code.
+func (s *ShellSession) Wait() (int, error) {
+	<-s.done
+	return s.exitCode, s.err
+}
+
+// Close terminates the session, closing the connection to the device.
+func (s *ShellSession) Close() error {
+	if s.shellConn != nil {
+		return s.shellConn.Close()
+	}
+	return s.conn.Close()
+}
+
+func (s *ShellSession) sendPacket(id wire.ShellPacketID, data []byte) error {
+	s.sendLock.Lock()
+	defer s.sendLock.Unlock()
+	return s.shellConn.SendPacket(id, data)
+}
+
+// readPackets demultiplexes the packets sent by the device into the stdout and stderr pipes
+// until the exit packet is received.
+func (s *ShellSession) readPackets() {
+	stdout, stderr := s.stdoutWriter, s.stderrWriter
+	defer close(s.done)
+
+	for {
+		id, data, err := s.shellConn.ReadPacket()
+		if err == io.EOF {
+			err = errors.Errorf(errors.ConnectionResetError, "shell closed before sending exit status")
+		}
+		if err != nil {
+			err = wrapContextErr(s.ctx, err)
+			s.err = err
+			stdout.CloseWithError(err)
+			stderr.CloseWithError(err)
+			return
+		}
+
+		switch id {
+		case wire.ShellIDStdout:
+			stdout.Write(data)
+		case wire.ShellIDStderr:
+			stderr.Write(data)
+		case wire.ShellIDExit:
+			if len(data) == 1 {
+				s.exitCode = int(data[0])
+			} else {
+				s.err = errors.Errorf(errors.ParseError, "expected 1 byte exit status, but got %d", len(data))
+			}
+			stdout.Close()
+			stderr.Close()
+			s.shellConn.Close()
+			return
+		}
+	}
+}
+
+// copyLegacyOutput copies the raw output of the legacy shell service to the stdout pipe.
+func (s *ShellSession) copyLegacyOutput() {
+	defer close(s.done)
+	s.stderrWriter.Close()
+
+	_, err := io.Copy(s.stdoutWriter, s.conn)
+	if err != nil {
+		err = wrapContextErr(s.ctx, errors.WrapErrorf(err, errors.NetworkError, "error reading shell output"))
+		s.err = err
+	}
+	s.stdoutWriter.CloseWithError(err)
+	s.conn.Close()
+}
+
+// shellStdinWriter writes to the standard input of a ShellSession.
+type shellStdinWriter struct {
+	session *ShellSession
+}
+
+func (w shellStdinWriter) Write(buf []byte) (n int, err error) {
+	if w.session.shellConn == nil {
+		return w.session.conn.Write(buf)
+	}
+
+	for len(buf) > 0 {
+		partialBuf := buf
+		if len(partialBuf) > wire.ShellMaxPacketSize {
+			partialBuf = partialBuf[:wire.ShellMaxPacketSize]
+		}
+		if err := w.session.sendPacket(wire.ShellIDStdin, partialBuf); err != nil {
+			return n, err
+		}
+		n += len(partialBuf)
+		buf = buf[len(partialBuf):]
+	}
+	return n, nil
+}
+
+func (w shellStdinWriter) Close() error {
+	if w.session.shellConn == nil {
+		// The legacy shell service can't half-close the connection.
+		return nil
+	}
+	return w.session.sendPacket(wire.ShellIDCloseStdin, nil)
+}
@@ -0,0 +1,49 @@
+package adb
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/kvnxiao/go-adb/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShellSession(t *testing.T) {
+	var input bytes.Buffer
+	sender := wire.NewShellSender(&input)
+	sender.SendPacket(wire.ShellIDStdout, []byte("hello\n"))
+	sender.SendPacket(wire.ShellIDStderr, []byte("oops\n"))
+	sender.SendPacket(wire.ShellIDExit, []byte{3})
+
+	var output bytes.Buffer
+	session := newShellSession(context.Background(), &wire.ShellConn{
+		ShellScanner: wire.NewShellScanner(&input),
+		ShellSender:  wire.NewShellSender(&output),
+	}, nil)
+	go session.readPackets()
+
+	stderrC := make(chan []byte)
+	go func() {
+		stderr, _ := ioutil.ReadAll(session.Stderr())
+		stderrC <- stderr
+	}()
+	stdout, err := ioutil.ReadAll(session.Stdout())
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(stdout))
+	assert.Equal(t, "oops\n", string(<-stderrC))
+
+	exitCode, err := session.Wait()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, exitCode)
+
+	n, err := session.Stdin().Write([]byte("ls"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.NoError(t, session.SetWindowSize(24, 80, 0, 0))
+	assert.NoError(t, session.Stdin().Close())
+	assert.Equal(t, "\000\002\000\000\000ls"+
+		"\005\012\000\000\00024x80,0x0\000"+
+		"\004\000\000\000\000", output.String())
+}
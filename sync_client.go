@@ -1,6 +1,7 @@
 package adb
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"time"
@@ -30,6 +31,38 @@ func stat(conn *wire.SyncConn, path string) (*DirEntry, error) {
 	return readStat(conn)
 }
 
+// statV2 is like stat, but uses a v2 request which reports all the fields of DirEntry.
+// id is either "LST2", which doesn't follow symlinks like stat, or "STA2", which does.
+func statV2(conn *wire.SyncConn, id, path string) (*DirEntry, error) {
+	if err := conn.SendOctetString(id); err != nil {
+		return nil, err
+	}
+	if err := conn.SendBytes([]byte(path)); err != nil {
+		return nil, err
+	}
+
+	status, err := conn.ReadStatus("stat")
+	if err != nil {
+		return nil, err
+	}
+	if status != id {
+		return nil, errors.Errorf(errors.AssertionError, "expected stat ID '%s', but got '%s'", id, status)
+	}
+
+	entry, err := readStatV2(conn)
+	if err != nil {
+		return nil, err
+	}
+	switch entry.Errno {
+	case 0:
+		return entry, nil
+	case errnoNoEnt:
+		return nil, errors.Errorf(errors.FileNoExistError, "file doesn't exist")
+	default:
+		return nil, errors.Errorf(errors.AdbError, "stat failed with errno %d", entry.Errno)
+	}
+}
+
 func listDirEntries(conn *wire.SyncConn, path string) (entries *DirEntries, err error) {
 	if err = conn.SendOctetString("LIST"); err != nil {
 		return
@@ -41,6 +74,19 @@ func listDirEntries(conn *wire.SyncConn, path string) (entries *DirEntries, err
 	return &DirEntries{scanner: conn}, nil
 }
 
+// listDirEntriesV2 is like listDirEntries, but uses the LIS2 request which reports all the
+// fields of DirEntry.
+func listDirEntriesV2(conn *wire.SyncConn, path string) (entries *DirEntries, err error) {
+	if err = conn.SendOctetString("LIS2"); err != nil {
+		return
+	}
+	if err = conn.SendBytes([]byte(path)); err != nil {
+		return
+	}
+
+	return &DirEntries{scanner: conn, v2: true}, nil
+}
+
 func receiveFile(conn *wire.SyncConn, path string) (io.ReadCloser, error) {
 	if err := conn.SendOctetString("RECV"); err != nil {
 		return nil, err
@@ -51,6 +97,24 @@ func receiveFile(conn *wire.SyncConn, path string) (io.ReadCloser, error) {
 	return newSyncFileReader(conn)
 }
 
+// receiveFileV2 is like receiveFile, but uses the RCV2 request to receive the file compressed
+// with c.
+func receiveFileV2(conn *wire.SyncConn, path string, c Compression) (io.ReadCloser, error) {
+	if err := conn.SendOctetString("RCV2"); err != nil {
+		return nil, err
+	}
+	if err := conn.SendBytes([]byte(path)); err != nil {
+		return nil, err
+	}
+	if err := conn.SendOctetString("RCV2"); err != nil {
+		return nil, err
+	}
+	if err := conn.SendInt32(int32(c.syncFlag())); err != nil {
+		return nil, err
+	}
+	return newDecompressingSyncFileReader(conn, c)
+}
+
 // sendFile returns a WriteCloser than will write to the file at path on device.
 // The file will be created with permissions specified by mode.
 // The file's modified time will be set to mtime, unless mtime is 0, in which case the time the writer is
@@ -68,6 +132,45 @@ func sendFile(conn *wire.SyncConn, path string, mode os.FileMode, mtime time.Tim
 	return newSyncFileWriter(conn, mtime), nil
 }
 
+// sendFileV2 is like sendFile, but uses the SND2 request to send the file compressed with c.
+func sendFileV2(conn *wire.SyncConn, path string, mode os.FileMode, mtime time.Time, c Compression) (io.WriteCloser, error) {
+	if err := conn.SendOctetString("SND2"); err != nil {
+		return nil, err
+	}
+	if err := conn.SendBytes([]byte(path)); err != nil {
+		return nil, err
+	}
+	if err := conn.SendOctetString("SND2"); err != nil {
+		return nil, err
+	}
+	if err := conn.SendInt32(int32(mode.Perm())); err != nil {
+		return nil, err
+	}
+	if err := conn.SendInt32(int32(c.syncFlag())); err != nil {
+		return nil, err
+	}
+
+	return newCompressingSyncFileWriter(conn, mtime, c)
+}
+
+// sendSymlink creates a symlink at path on the device that points to target.
+func sendSymlink(conn *wire.SyncConn, path, target string, mtime time.Time) error {
+	if err := conn.SendOctetString("SEND"); err != nil {
+		return err
+	}
+	// The device creates a symlink when the mode has the symlink type bits, the data is then
+	// the target of the link.
+	if err := conn.SendBytes([]byte(fmt.Sprintf("%s,%d", path, wire.ModeSymlink|0777))); err != nil {
+		return err
+	}
+
+	writer := newSyncFileWriter(conn, mtime)
+	if _, err := writer.Write([]byte(target)); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
 func readStat(s wire.SyncScanner) (entry *DirEntry, err error) {
 	mode, err := s.ReadFileMode()
 	if err != nil {
@@ -93,8 +196,51 @@ func readStat(s wire.SyncScanner) (entry *DirEntry, err error) {
 
 	entry = &DirEntry{
 		Mode:       mode,
-		Size:       size,
+		Size:       int64(uint32(size)),
 		ModifiedAt: mtime,
 	}
 	return
 }
+
+// The device reports errors as Linux errno values, regardless of the host.
+const errnoNoEnt = 2
+
+/*
+readStatV2 reads the body of a STA2, LST2 or DNT2 response:
+	error, dev, ino, mode, nlink, uid, gid, size, atime, mtime, ctime
+All fields are 32 bits, except dev, ino, size and the timestamps, which are 64 bits.
+If error is non-zero, all other fields are zero.
+*/
+func readStatV2(s wire.SyncScanner) (*DirEntry, error) {
+	var fields [11]int64
+	var err error
+	for i, is64 := range []bool{false, true, true, false, false, false, false, true, true, true, true} {
+		if is64 {
+			fields[i], err = s.ReadInt64()
+		} else {
+			var value int32
+			value, err = s.ReadInt32()
+			fields[i] = int64(uint32(value))
+		}
+		if err != nil {
+			return nil, errors.WrapErrf(err, "error reading stat: %v", err)
+		}
+	}
+
+	if fields[0] != 0 {
+		// The other fields are meaningless if the entry couldn't be stat'ed.
+		return &DirEntry{Errno: uint32(fields[0])}, nil
+	}
+	return &DirEntry{
+		Dev:        uint64(fields[1]),
+		Inode:      uint64(fields[2]),
+		Mode:       wire.ParseFileModeFromAdb(uint32(fields[3])),
+		Nlink:      uint32(fields[4]),
+		UID:        uint32(fields[5]),
+		GID:        uint32(fields[6]),
+		Size:       fields[7],
+		AccessedAt: time.Unix(fields[8], 0).UTC(),
+		ModifiedAt: time.Unix(fields[9], 0).UTC(),
+		ChangedAt:  time.Unix(fields[10], 0).UTC(),
+	}, nil
+}
@@ -17,7 +17,7 @@ var someTime = time.Date(2015, 5, 3, 8, 8, 8, 0, time.UTC)
 
 func TestStatValid(t *testing.T) {
 	var buf bytes.Buffer
-	conn := &wire.SyncConn{wire.NewSyncScanner(&buf), wire.NewSyncSender(&buf)}
+	conn := &wire.SyncConn{SyncScanner: wire.NewSyncScanner(&buf), SyncSender: wire.NewSyncSender(&buf)}
 
 	var mode os.FileMode = 0777
 
@@ -30,14 +30,14 @@ func TestStatValid(t *testing.T) {
 	assert.NoError(t, err)
 	require.NotNil(t, entry)
 	assert.Equal(t, mode, entry.Mode, "expected os.FileMode %s, got %s", mode, entry.Mode)
-	assert.Equal(t, int32(4), entry.Size)
+	assert.Equal(t, int64(4), entry.Size)
 	assert.Equal(t, someTime, entry.ModifiedAt)
 	assert.Equal(t, "", entry.Name)
 }
 
 func TestStatBadResponse(t *testing.T) {
 	var buf bytes.Buffer
-	conn := &wire.SyncConn{wire.NewSyncScanner(&buf), wire.NewSyncSender(&buf)}
+	conn := &wire.SyncConn{SyncScanner: wire.NewSyncScanner(&buf), SyncSender: wire.NewSyncSender(&buf)}
 
 	conn.SendOctetString("SPAT")
 
@@ -48,7 +48,7 @@ func TestStatBadResponse(t *testing.T) {
 
 func TestStatNoExist(t *testing.T) {
 	var buf bytes.Buffer
-	conn := &wire.SyncConn{wire.NewSyncScanner(&buf), wire.NewSyncSender(&buf)}
+	conn := &wire.SyncConn{SyncScanner: wire.NewSyncScanner(&buf), SyncSender: wire.NewSyncSender(&buf)}
 
 	conn.SendOctetString("STAT")
 	conn.SendFileMode(0)
@@ -59,3 +59,103 @@ func TestStatNoExist(t *testing.T) {
 	assert.Nil(t, entry)
 	assert.Equal(t, errors.FileNoExistError, err.(*errors.Err).Code)
 }
+
+// sendStatV2 writes the body of a STA2, LST2 or DNT2 response.
+func sendStatV2(conn *wire.SyncConn, errno int32, mode uint32, size int64, mtime time.Time) {
+	conn.SendInt32(errno)
+	conn.SendInt64(1)  // dev
+	conn.SendInt64(42) // ino
+	conn.SendInt32(int32(mode))
+	conn.SendInt32(1)    // nlink
+	conn.SendInt32(2000) // uid
+	conn.SendInt32(1000) // gid
+	conn.SendInt64(size)
+	conn.SendInt64(mtime.Unix() - 60) // atime
+	conn.SendInt64(mtime.Unix())
+	conn.SendInt64(mtime.Unix() + 60) // ctime
+}
+
+func TestStatV2Valid(t *testing.T) {
+	var buf bytes.Buffer
+	conn := &wire.SyncConn{SyncScanner: wire.NewSyncScanner(&buf), SyncSender: wire.NewSyncSender(&buf)}
+
+	conn.SendOctetString("LST2")
+	sendStatV2(conn, 0, 0644, 5<<30, someTime)
+
+	entry, err := statV2(conn, "LST2", "/thing")
+	assert.NoError(t, err)
+	assert.Equal(t, &DirEntry{
+		Mode:       0644,
+		Size:       5 << 30,
+		ModifiedAt: someTime,
+		Dev:        1,
+		Inode:      42,
+		Nlink:      1,
+		UID:        2000,
+		GID:        1000,
+		AccessedAt: someTime.Add(-time.Minute),
+		ChangedAt:  someTime.Add(time.Minute),
+	}, entry)
+}
+
+func TestStatV2NoExist(t *testing.T) {
+	var buf bytes.Buffer
+	conn := &wire.SyncConn{SyncScanner: wire.NewSyncScanner(&buf), SyncSender: wire.NewSyncSender(&buf)}
+
+	conn.SendOctetString("LST2")
+	sendStatV2(conn, errnoNoEnt, 0, 0, zeroTime)
+
+	entry, err := statV2(conn, "LST2", "/")
+	assert.Nil(t, entry)
+	assert.Equal(t, errors.FileNoExistError, err.(*errors.Err).Code)
+}
+
+func TestListDirEntriesV2(t *testing.T) {
+	var buf bytes.Buffer
+	conn := &wire.SyncConn{SyncScanner: wire.NewSyncScanner(&buf), SyncSender: wire.NewSyncSender(&buf)}
+
+	conn.SendOctetString("DNT2")
+	sendStatV2(conn, 0, wire.ModeDir|0755, 4096, someTime)
+	conn.SendBytes([]byte("dir"))
+	conn.SendOctetString("DNT2")
+	sendStatV2(conn, 13, 0, 0, zeroTime)
+	conn.SendBytes([]byte("secret"))
+	conn.SendOctetString("DONE")
+
+	entries, err := listDirEntriesV2(conn, "/")
+	require.NoError(t, err)
+	result, err := entries.ReadAll()
+	assert.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, "dir", result[0].Name)
+	assert.Equal(t, os.ModeDir|0755, result[0].Mode)
+	assert.Equal(t, int64(4096), result[0].Size)
+	assert.Equal(t, someTime, result[0].ModifiedAt)
+	// Only the name is kept if the device couldn't stat the entry.
+	assert.Equal(t, &DirEntry{Name: "secret", Errno: 13}, result[1])
+}
+
+func TestSendSymlink(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, sendSymlink(newSendSyncConn(&buf, syncOkay), "/sdcard/link", "../target", someTime))
+
+	conn := wire.NewSyncScanner(&buf)
+	id, err := conn.ReadStatus("")
+	assert.NoError(t, err)
+	assert.Equal(t, "SEND", id)
+	request, err := conn.ReadString()
+	assert.NoError(t, err)
+	assert.Equal(t, "/sdcard/link,41471", request) // 0120777
+	id, err = conn.ReadStatus("")
+	assert.NoError(t, err)
+	assert.Equal(t, "DATA", id)
+	target, err := conn.ReadString()
+	assert.NoError(t, err)
+	assert.Equal(t, "../target", target)
+	id, err = conn.ReadStatus("")
+	assert.NoError(t, err)
+	assert.Equal(t, "DONE", id)
+	mtime, err := conn.ReadTime()
+	assert.NoError(t, err)
+	assert.Equal(t, someTime, mtime)
+}
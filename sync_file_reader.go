@@ -2,6 +2,7 @@ package adb
 
 import (
 	"io"
+	"io/ioutil"
 
 	"github.com/kvnxiao/go-adb/internal/errors"
 	"github.com/kvnxiao/go-adb/wire"
@@ -79,6 +80,44 @@ func (r *syncFileReader) Close() error {
 	return r.scanner.Close()
 }
 
+// decompressingReader decompresses the data read from a syncFileReader.
+type decompressingReader struct {
+	decompressor io.ReadCloser
+	file         io.ReadCloser
+}
+
+// newDecompressingSyncFileReader is like newSyncFileReader, but the data is decompressed with c.
+func newDecompressingSyncFileReader(s wire.SyncScanner, c Compression) (io.ReadCloser, error) {
+	file, err := newSyncFileReader(s)
+	if err != nil {
+		return nil, err
+	}
+
+	decompressor, err := newDecompressor(c, file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &decompressingReader{decompressor, file}, nil
+}
+
+func (r *decompressingReader) Read(buf []byte) (int, error) {
+	n, err := r.decompressor.Read(buf)
+	if err == io.EOF {
+		// Decompressors stop at the end of the compressed stream, read up to the end of the
+		// file so errors the device reports after it aren't missed.
+		if _, err := io.Copy(ioutil.Discard, r.file); err != nil {
+			return n, err
+		}
+	}
+	return n, wrapCompressionErr(err, "error decompressing file")
+}
+
+func (r *decompressingReader) Close() error {
+	return errors.CombineErrs("error closing decompressing reader", errors.NetworkError,
+		r.decompressor.Close(), r.file.Close())
+}
+
 // readNextChunk creates an io.LimitedReader for the next chunk of data,
 // and returns io.EOF if the last chunk has been read.
 func readNextChunk(r wire.SyncScanner) (io.Reader, error) {
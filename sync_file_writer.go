@@ -1,6 +1,7 @@
 package adb
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"os"
@@ -16,17 +17,48 @@ type syncFileWriter struct {
 	// If 0, use the current time.
 	mtime time.Time
 
-	// Reader used to read data from the adb connection.
+	// Writer used to send data to the adb connection.
 	sender wire.SyncSender
+
+	// Reader used to read the status of the transfer once the file is sent.
+	scanner wire.SyncScanner
+
+	// Sends data chunks.
+	chunks syncChunkWriter
+
+	// If set, data is compressed before being sent, see newCompressingSyncFileWriter.
+	compressor io.WriteCloser
+
+	// Coalesces the compressor's output into full chunks.
+	compressedBuf *bufio.Writer
 }
 
 var _ io.WriteCloser = &syncFileWriter{}
 
-func newSyncFileWriter(s wire.SyncSender, mtime time.Time) io.WriteCloser {
+func newSyncFileWriter(conn *wire.SyncConn, mtime time.Time) io.WriteCloser {
 	return &syncFileWriter{
-		mtime:  mtime,
-		sender: s,
+		mtime:   mtime,
+		sender:  conn,
+		scanner: conn,
+		chunks:  syncChunkWriter{conn},
+	}
+}
+
+// newCompressingSyncFileWriter is like newSyncFileWriter, but the data is compressed with c.
+func newCompressingSyncFileWriter(conn *wire.SyncConn, mtime time.Time, c Compression) (io.WriteCloser, error) {
+	w := &syncFileWriter{
+		mtime:   mtime,
+		sender:  conn,
+		scanner: conn,
+		chunks:  syncChunkWriter{conn},
 	}
+	w.compressedBuf = bufio.NewWriterSize(w.chunks, wire.SyncMaxChunkSize)
+
+	var err error
+	if w.compressor, err = newCompressor(c, w.compressedBuf); err != nil {
+		return nil, err
+	}
+	return w, nil
 }
 
 /*
@@ -41,8 +73,21 @@ func encodePathAndMode(path string, mode os.FileMode) []byte {
 	return []byte(fmt.Sprintf("%s,%d", path, uint32(mode.Perm())))
 }
 
-// Write writes the min of (len(buf), 64k).
 func (w *syncFileWriter) Write(buf []byte) (n int, err error) {
+	if w.compressor != nil {
+		n, err = w.compressor.Write(buf)
+		return n, wrapCompressionErr(err, "error compressing file")
+	}
+	return w.chunks.Write(buf)
+}
+
+// syncChunkWriter sends everything written to it as data chunks.
+type syncChunkWriter struct {
+	sender wire.SyncSender
+}
+
+// Write writes the min of (len(buf), 64k).
+func (w syncChunkWriter) Write(buf []byte) (n int, err error) {
 	written := 0
 
 	// If buf > 64k we'll have to send multiple chunks.
@@ -71,6 +116,15 @@ func (w *syncFileWriter) Write(buf []byte) (n int, err error) {
 }
 
 func (w *syncFileWriter) Close() error {
+	if w.compressor != nil {
+		if err := w.compressor.Close(); err != nil {
+			return wrapCompressionErr(err, "error compressing file")
+		}
+		if err := w.compressedBuf.Flush(); err != nil {
+			return err
+		}
+	}
+
 	if w.mtime.IsZero() {
 		w.mtime = time.Now()
 	}
@@ -82,5 +136,25 @@ func (w *syncFileWriter) Close() error {
 		return errors.WrapErrf(err, "error writing file modification time")
 	}
 
+	// The device only replies once the file is written, with the reason if it couldn't be.
+	if err := w.readStatus(); err != nil {
+		w.sender.Close()
+		return err
+	}
+
 	return errors.WrapErrf(w.sender.Close(), "error closing FileWriter")
 }
+
+func (w *syncFileWriter) readStatus() error {
+	status, err := w.scanner.ReadStatus("send")
+	if err != nil {
+		return err
+	}
+	if status != wire.StatusSuccess {
+		return errors.Errorf(errors.AssertionError, "expected status '%s' after sending file, but got '%s'",
+			wire.StatusSuccess, status)
+	}
+	// The status is followed by a length, which is always 0.
+	_, err = w.scanner.ReadInt32()
+	return err
+}
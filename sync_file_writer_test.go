@@ -2,19 +2,32 @@ package adb
 
 import (
 	"bytes"
+	"io"
 	"testing"
 	"time"
 
 	"encoding/binary"
 	"strings"
 
+	"github.com/kvnxiao/go-adb/internal/errors"
 	"github.com/kvnxiao/go-adb/wire"
 	"github.com/stretchr/testify/assert"
 )
 
+// syncOkay is the status a device replies with once it has written a file.
+const syncOkay = "OKAY\000\000\000\000"
+
+// newSendSyncConn returns a SyncConn that sends to w, and reads the status from response.
+func newSendSyncConn(w io.Writer, response string) *wire.SyncConn {
+	return &wire.SyncConn{
+		SyncScanner: wire.NewSyncScanner(strings.NewReader(response)),
+		SyncSender:  wire.NewSyncSender(w),
+	}
+}
+
 func TestFileWriterWriteSingleChunk(t *testing.T) {
 	var buf bytes.Buffer
-	writer := newSyncFileWriter(wire.NewSyncSender(&buf), MtimeOfClose)
+	writer := newSyncFileWriter(newSendSyncConn(&buf, syncOkay), MtimeOfClose)
 
 	n, err := writer.Write([]byte("hello"))
 	assert.NoError(t, err)
@@ -25,7 +38,7 @@ func TestFileWriterWriteSingleChunk(t *testing.T) {
 
 func TestFileWriterWriteMultiChunk(t *testing.T) {
 	var buf bytes.Buffer
-	writer := newSyncFileWriter(wire.NewSyncSender(&buf), MtimeOfClose)
+	writer := newSyncFileWriter(newSendSyncConn(&buf, syncOkay), MtimeOfClose)
 
 	n, err := writer.Write([]byte("hello"))
 	assert.NoError(t, err)
@@ -40,7 +53,7 @@ func TestFileWriterWriteMultiChunk(t *testing.T) {
 
 func TestFileWriterWriteLargeChunk(t *testing.T) {
 	var buf bytes.Buffer
-	writer := newSyncFileWriter(wire.NewSyncSender(&buf), MtimeOfClose)
+	writer := newSyncFileWriter(newSendSyncConn(&buf, syncOkay), MtimeOfClose)
 
 	// Send just enough data to get 2 chunks.
 	data := make([]byte, wire.SyncMaxChunkSize+1)
@@ -67,7 +80,7 @@ func TestFileWriterWriteLargeChunk(t *testing.T) {
 func TestFileWriterCloseEmpty(t *testing.T) {
 	var buf bytes.Buffer
 	mtime := time.Unix(1, 0)
-	writer := newSyncFileWriter(wire.NewSyncSender(&buf), mtime)
+	writer := newSyncFileWriter(newSendSyncConn(&buf, syncOkay), mtime)
 
 	assert.NoError(t, writer.Close())
 
@@ -77,7 +90,7 @@ func TestFileWriterCloseEmpty(t *testing.T) {
 func TestFileWriterWriteClose(t *testing.T) {
 	var buf bytes.Buffer
 	mtime := time.Unix(1, 0)
-	writer := newSyncFileWriter(wire.NewSyncSender(&buf), mtime)
+	writer := newSyncFileWriter(newSendSyncConn(&buf, syncOkay), mtime)
 
 	writer.Write([]byte("hello"))
 	assert.NoError(t, writer.Close())
@@ -87,7 +100,7 @@ func TestFileWriterWriteClose(t *testing.T) {
 
 func TestFileWriterCloseAutoMtime(t *testing.T) {
 	var buf bytes.Buffer
-	writer := newSyncFileWriter(wire.NewSyncSender(&buf), MtimeOfClose)
+	writer := newSyncFileWriter(newSendSyncConn(&buf, syncOkay), MtimeOfClose)
 
 	assert.NoError(t, writer.Close())
 	assert.Len(t, buf.String(), 8)
@@ -99,3 +112,13 @@ func TestFileWriterCloseAutoMtime(t *testing.T) {
 	// Delta has to be a whole second since adb only supports second granularity for mtimes.
 	assert.WithinDuration(t, time.Now(), mtimeActual, 1*time.Second)
 }
+
+func TestFileWriterCloseFail(t *testing.T) {
+	var buf bytes.Buffer
+	writer := newSyncFileWriter(newSendSyncConn(&buf, "FAIL\027\000\000\000No space left on device"), time.Unix(1, 0))
+
+	writer.Write([]byte("hello"))
+	err := writer.Close()
+	assert.Equal(t, errors.AdbError, err.(*errors.Err).Code)
+	assert.Contains(t, err.Error(), "No space left on device")
+}
@@ -0,0 +1,136 @@
+package adb
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/pierrec/lz4/v4"
+)
+
+// TransferOptions configures a file transfer, see Device.OpenReadWithOptions and
+// Device.OpenWriteWithOptions.
+type TransferOptions struct {
+	// Compression of the file contents while they're transferred. If the device doesn't
+	// support it, the file is transferred uncompressed.
+	Compression Compression
+}
+
+// Compression is an algorithm used to compress file transfers.
+type Compression int
+
+const (
+	// CompressionNone transfers files uncompressed.
+	CompressionNone Compression = iota
+
+	// CompressionAny uses the fastest compression supported by the device, if any.
+	CompressionAny
+
+	CompressionBrotli
+	CompressionLZ4
+	CompressionZstd
+)
+
+// Flags of the SND2 and RCV2 sync requests.
+const (
+	syncFlagBrotli uint32 = 1
+	syncFlagLZ4    uint32 = 2
+	syncFlagZstd   uint32 = 4
+)
+
+// compressionFeatures maps each compression to the feature a device needs to support it.
+// The order is the order of preference for CompressionAny.
+var compressionFeatures = []struct {
+	Compression Compression
+	Feature     string
+}{
+	{CompressionLZ4, FeatureSendRecv2LZ4},
+	{CompressionZstd, FeatureSendRecv2Zstd},
+	{CompressionBrotli, FeatureSendRecv2Brotli},
+}
+
+// resolveCompression returns the compression to use for a device that supports features,
+// which is CompressionNone if the device doesn't support the requested compression.
+func resolveCompression(requested Compression, features []string) Compression {
+	supported := map[string]bool{}
+	for _, feature := range features {
+		supported[feature] = true
+	}
+	if requested == CompressionNone || !supported[FeatureSendRecv2] {
+		return CompressionNone
+	}
+
+	for _, c := range compressionFeatures {
+		if (requested == CompressionAny || requested == c.Compression) && supported[c.Feature] {
+			return c.Compression
+		}
+	}
+	return CompressionNone
+}
+
+// syncFlag returns the flag to set in SND2 and RCV2 requests for c.
+func (c Compression) syncFlag() uint32 {
+	switch c {
+	case CompressionBrotli:
+		return syncFlagBrotli
+	case CompressionLZ4:
+		return syncFlagLZ4
+	case CompressionZstd:
+		return syncFlagZstd
+	default:
+		return 0
+	}
+}
+
+// newCompressor returns a writer that compresses data with c and writes it to w.
+// Closing it flushes the compressed stream, but doesn't close w.
+func newCompressor(c Compression, w io.Writer) (io.WriteCloser, error) {
+	switch c {
+	case CompressionBrotli:
+		// The device uses the same quality, higher ones are too slow to be worth it.
+		return brotli.NewWriterLevel(w, 1), nil
+	case CompressionLZ4:
+		// Small blocks keep the memory needed to decompress them on the device low.
+		encoder := lz4.NewWriter(w)
+		err := encoder.Apply(lz4.BlockSizeOption(lz4.Block64Kb))
+		return encoder, errors.WrapErrorf(err, errors.AssertionError, "error creating lz4 encoder")
+	case CompressionZstd:
+		encoder, err := zstd.NewWriter(w)
+		return encoder, errors.WrapErrorf(err, errors.AssertionError, "error creating zstd encoder")
+	default:
+		return nil, errors.AssertionErrorf("invalid compression: %d", c)
+	}
+}
+
+// newDecompressor returns a reader that decompresses data compressed with c from r.
+// Closing it releases its resources, but doesn't close r.
+func newDecompressor(c Compression, r io.Reader) (io.ReadCloser, error) {
+	switch c {
+	case CompressionBrotli:
+		return ioutil.NopCloser(brotli.NewReader(r)), nil
+	case CompressionLZ4:
+		return ioutil.NopCloser(lz4.NewReader(r)), nil
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, errors.WrapErrorf(err, errors.AssertionError, "error creating zstd decoder")
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, errors.AssertionErrorf("invalid compression: %d", c)
+	}
+}
+
+// wrapCompressionErr wraps errors returned by compressors and decompressors, unless they
+// come from the underlying connection and are already *errors.Err.
+func wrapCompressionErr(err error, message string) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	if _, ok := err.(*errors.Err); ok {
+		return err
+	}
+	return errors.WrapErrorf(err, errors.ParseError, "%s", message)
+}
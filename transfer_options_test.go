@@ -0,0 +1,239 @@
+package adb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kvnxiao/go-adb/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var allCompressions = []struct {
+	Name        string
+	Compression Compression
+}{
+	{"none", CompressionNone},
+	{"brotli", CompressionBrotli},
+	{"lz4", CompressionLZ4},
+	{"zstd", CompressionZstd},
+}
+
+func TestResolveCompression(t *testing.T) {
+	allFeatures := []string{FeatureShell2, FeatureSendRecv2, FeatureSendRecv2Brotli, FeatureSendRecv2LZ4, FeatureSendRecv2Zstd}
+
+	for _, test := range []struct {
+		Requested Compression
+		Features  []string
+		Want      Compression
+	}{
+		{CompressionNone, allFeatures, CompressionNone},
+		{CompressionAny, allFeatures, CompressionLZ4},
+		{CompressionAny, []string{FeatureSendRecv2, FeatureSendRecv2Brotli}, CompressionBrotli},
+		{CompressionAny, []string{FeatureSendRecv2}, CompressionNone},
+		{CompressionZstd, allFeatures, CompressionZstd},
+		{CompressionZstd, []string{FeatureSendRecv2, FeatureSendRecv2Brotli}, CompressionNone},
+		{CompressionBrotli, []string{FeatureSendRecv2Brotli}, CompressionNone},
+	} {
+		assert.Equal(t, test.Want, resolveCompression(test.Requested, test.Features),
+			"%d with features %v", test.Requested, test.Features)
+	}
+}
+
+func TestSendFileV2(t *testing.T) {
+	data := []byte(fmt.Sprintf("%0100000d", 42))
+
+	for _, c := range allCompressions[1:] {
+		var buf bytes.Buffer
+		conn := newSendSyncConn(&buf, syncOkay)
+
+		writer, err := sendFileV2(conn, "/data/file", 0644, someTime, c.Compression)
+		require.NoError(t, err, c.Name)
+		_, err = writer.Write(data)
+		assert.NoError(t, err, c.Name)
+		assert.NoError(t, writer.Close(), c.Name)
+
+		compression, received, mtime, err := fakeDeviceReceive(wire.NewSyncScanner(&buf))
+		assert.NoError(t, err, c.Name)
+		assert.Equal(t, c.Compression, compression, c.Name)
+		assert.Equal(t, data, received, c.Name)
+		assert.Equal(t, someTime, mtime, c.Name)
+		assert.True(t, buf.Len() == 0, c.Name)
+	}
+}
+
+func TestReceiveFileV2(t *testing.T) {
+	data := []byte(fmt.Sprintf("%0100000d", 42))
+
+	for _, c := range allCompressions[1:] {
+		// Compress the data like the device would.
+		var compressed bytes.Buffer
+		compressor, err := newCompressor(c.Compression, &compressed)
+		require.NoError(t, err, c.Name)
+		compressor.Write(data)
+		compressor.Close()
+
+		var buf bytes.Buffer
+		conn := &wire.SyncConn{SyncScanner: wire.NewSyncScanner(&buf), SyncSender: wire.NewSyncSender(&buf)}
+		chunks := syncChunkWriter{conn}
+		chunks.Write(compressed.Bytes())
+		conn.SendOctetString(wire.StatusSyncDone)
+
+		// The request is written to the same buffer, after the response.
+		reader, err := receiveFileV2(conn, "/data/file", c.Compression)
+		require.NoError(t, err, c.Name)
+		received, err := ioutil.ReadAll(reader)
+		assert.NoError(t, err, c.Name)
+		assert.Equal(t, data, received, c.Name)
+		assert.NoError(t, reader.Close(), c.Name)
+
+		assert.Equal(t, "RCV2\012\000\000\000/data/fileRCV2", buf.String()[:22], c.Name)
+		assert.Equal(t, []byte{byte(c.Compression.syncFlag()), 0, 0, 0}, buf.Bytes()[22:], c.Name)
+	}
+}
+
+// fakeDeviceReceive reads a SEND or SND2 request from s like a device would.
+func fakeDeviceReceive(s wire.SyncScanner) (compression Compression, data []byte, mtime time.Time, err error) {
+	id, err := s.ReadStatus("send")
+	if err != nil {
+		return
+	}
+	if _, err = s.ReadString(); err != nil {
+		return
+	}
+	if id == "SND2" {
+		if _, err = s.ReadStatus("send-v2"); err != nil {
+			return
+		}
+		if _, err = s.ReadInt32(); err != nil {
+			return
+		}
+		var flags int32
+		if flags, err = s.ReadInt32(); err != nil {
+			return
+		}
+		for _, c := range allCompressions[1:] {
+			if uint32(flags) == c.Compression.syncFlag() {
+				compression = c.Compression
+			}
+		}
+	}
+
+	file := &syncFileReader{scanner: s}
+	var reader io.Reader = file
+	if compression != CompressionNone {
+		var decompressor io.ReadCloser
+		if decompressor, err = newDecompressor(compression, file); err != nil {
+			return
+		}
+		defer decompressor.Close()
+		reader = decompressor
+	}
+	if data, err = ioutil.ReadAll(reader); err != nil {
+		return
+	}
+	// Consume the rest of the stream, in case the decompressor stopped before DONE.
+	if _, err = io.Copy(ioutil.Discard, file); err != nil {
+		return
+	}
+	mtime, err = s.ReadTime()
+	return
+}
+
+// throttledWriter simulates a slow link between the host and the device.
+type throttledWriter struct {
+	w              io.Writer
+	bytesPerSecond int
+	written        int64
+}
+
+func (w *throttledWriter) Write(buf []byte) (int, error) {
+	time.Sleep(time.Duration(len(buf)) * time.Second / time.Duration(w.bytesPerSecond))
+	n, err := w.w.Write(buf)
+	w.written += int64(n)
+	return n, err
+}
+
+/*
+BenchmarkSendFile pushes a file to a fake device over a loopback connection throttled to the
+throughput of a device behind a USB 2 hub, with each compression.
+Reports how many bytes were sent over the link per push in addition to the time taken.
+*/
+func BenchmarkSendFile(b *testing.B) {
+	// Looks like the kind of test fixture that's usually pushed, e.g. logs or JSON.
+	random := rand.New(rand.NewSource(1))
+	var data bytes.Buffer
+	for data.Len() < 4*1024*1024 {
+		fmt.Fprintf(&data, `{"id": %d, "name": "item-%d", "value": %f, "tags": ["a", "b"]}`+"\n",
+			random.Int(), random.Intn(1000), random.Float64())
+	}
+
+	for _, c := range allCompressions {
+		c := c
+		b.Run(c.Name, func(b *testing.B) {
+			b.SetBytes(int64(data.Len()))
+			var wireBytes int64
+
+			for i := 0; i < b.N; i++ {
+				host, device := net.Pipe()
+				link := &throttledWriter{w: host, bytesPerSecond: 20 * 1024 * 1024}
+				conn := &wire.SyncConn{SyncScanner: wire.NewSyncScanner(host), SyncSender: wire.NewSyncSender(link)}
+
+				received := make(chan error)
+				go func() {
+					_, _, _, err := fakeDeviceReceive(wire.NewSyncScanner(device))
+					if err == nil {
+						_, err = io.WriteString(device, syncOkay)
+					}
+					received <- err
+				}()
+
+				var writer io.WriteCloser
+				var err error
+				if c.Compression == CompressionNone {
+					writer, err = sendFile(conn, "/data/file", 0644, someTime)
+				} else {
+					writer, err = sendFileV2(conn, "/data/file", 0644, someTime, c.Compression)
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := writer.Write(data.Bytes()); err != nil {
+					b.Fatal(err)
+				}
+				if err := writer.Close(); err != nil {
+					b.Fatal(err)
+				}
+				if err := <-received; err != nil {
+					b.Fatal(err)
+				}
+				host.Close()
+				device.Close()
+				wireBytes += link.written
+			}
+
+			b.ReportMetric(float64(wireBytes)/float64(b.N), "wire-bytes/op")
+		})
+	}
+}
+
+// The fixture was created by the reference implementation with linked blocks, block checksums
+// and the content size, which the device may send:
+//	lz4 -B4 -BD -BX --content-size
+func TestDecompressLZ4LinkedBlocks(t *testing.T) {
+	compressed, err := ioutil.ReadFile("testdata/linked.lz4")
+	require.NoError(t, err)
+
+	decompressor, err := newDecompressor(CompressionLZ4, bytes.NewReader(compressed))
+	require.NoError(t, err)
+	decoded, err := ioutil.ReadAll(decompressor)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("The quick brown fox jumps over the lazy dog.\n", 3500), string(decoded))
+}
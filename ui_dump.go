@@ -0,0 +1,298 @@
+package adb
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+// uiDumpDir is the directory the UI hierarchy is dumped to, before it's read and removed.
+const uiDumpDir = "/data/local/tmp"
+
+// uiDumpCleanupTimeout bounds the removal of the dump file, which is done even if the context of
+// the dump is done.
+const uiDumpCleanupTimeout = 10 * time.Second
+
+// UIHierarchy is the hierarchy of the views on the screen, as dumped by uiautomator.
+type UIHierarchy struct {
+	// Rotation of the screen, from 0 to 3 quarter turns.
+	Rotation int
+
+	// Roots are the root views of the windows on the screen.
+	Roots []*UINode
+}
+
+// UINode is a view of a UIHierarchy.
+type UINode struct {
+	Index       int
+	Text        string
+	ResourceID  string
+	Class       string
+	Package     string
+	ContentDesc string
+
+	Checkable     bool
+	Checked       bool
+	Clickable     bool
+	Enabled       bool
+	Focusable     bool
+	Focused       bool
+	Scrollable    bool
+	LongClickable bool
+	Password      bool
+	Selected      bool
+
+	// Bounds of the view on the screen, in pixels.
+	Bounds image.Rectangle
+
+	// Attrs are all the attributes of the node in the dump, by name, e.g. "resource-id".
+	Attrs map[string]string
+
+	Parent   *UINode
+	Children []*UINode
+}
+
+// Center returns the center of the bounds of the node, where it's tapped.
+func (n *UINode) Center() image.Point {
+	return image.Pt((n.Bounds.Min.X+n.Bounds.Max.X)/2, (n.Bounds.Min.Y+n.Bounds.Max.Y)/2)
+}
+
+// Tap returns an action that taps the center of the node, see Device.Input.
+func (n *UINode) Tap() InputAction {
+	center := n.Center()
+	return Tap(center.X, center.Y)
+}
+
+// String describes the node for debugging, e.g. `android.widget.Button "OK" [0,0][100,50]`.
+func (n *UINode) String() string {
+	s := n.Class
+	if n.ResourceID != "" {
+		s += " " + n.ResourceID
+	}
+	if n.Text != "" {
+		s += " " + strconv.Quote(n.Text)
+	}
+	return fmt.Sprintf("%s [%d,%d][%d,%d]", s, n.Bounds.Min.X, n.Bounds.Min.Y, n.Bounds.Max.X, n.Bounds.Max.Y)
+}
+
+/*
+DumpUI dumps the hierarchy of the views on the screen with uiautomator, so they can be found
+and tapped without an agent on the device:
+	ui, err := device.DumpUI()
+	nodes := ui.Find(adb.UISelector{ResourceID: "login", Clickable: true})
+	if len(nodes) > 0 {
+		err = device.Input(nodes[0].Tap())
+	}
+uiautomator fails while the screen is animating, in which case an AdbError is returned and the
+dump should be retried.
+*/
+func (c *Device) DumpUI() (*UIHierarchy, error) {
+	return c.DumpUIContext(context.Background())
+}
+
+// DumpUIContext is like DumpUI, but gives up as soon as ctx is done.
+func (c *Device) DumpUIContext(ctx context.Context) (*UIHierarchy, error) {
+	// uiautomator can only write the dump to a file. Its name is unique so concurrent dumps
+	// don't overwrite each other.
+	dumpPath := fmt.Sprintf("%s/go-adb-ui-dump-%016x.xml", uiDumpDir, rand.Uint64())
+	output, err := c.RunCommandContext(ctx, "uiautomator", "dump", dumpPath)
+	if err != nil {
+		return nil, err
+	}
+	defer c.removeUIDump(dumpPath)
+	if !bytes.Contains(output, []byte(dumpPath)) {
+		err = errors.Errorf(errors.AdbError, "uiautomator dump failed: %s", strings.TrimSpace(string(output)))
+		return nil, wrapClientError(err, c, "DumpUI")
+	}
+
+	r, err := c.OpenReadContext(ctx, dumpPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	ui, err := ParseUIHierarchy(r)
+	return ui, wrapClientError(err, c, "DumpUI")
+}
+
+// removeUIDump removes the file path the UI hierarchy was dumped to.
+func (c *Device) removeUIDump(path string) {
+	ctx, cancel := context.WithTimeout(context.Background(), uiDumpCleanupTimeout)
+	defer cancel()
+	c.RunCommandContext(ctx, "rm", "-f", path)
+}
+
+var reUIBounds = regexp.MustCompile(`^\[(-?\d+),(-?\d+)\]\[(-?\d+),(-?\d+)\]$`)
+
+// ParseUIHierarchy parses a UI hierarchy dumped by uiautomator.
+func ParseUIHierarchy(r io.Reader) (*UIHierarchy, error) {
+	decoder := xml.NewDecoder(r)
+	ui := &UIHierarchy{}
+	var parent *UINode
+	var seenHierarchy bool
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if _, ok := err.(*errors.Err); ok {
+				return nil, err
+			}
+			return nil, errors.WrapErrorf(err, errors.ParseError, "invalid UI hierarchy")
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			switch token.Name.Local {
+			case "hierarchy":
+				seenHierarchy = true
+				for _, attr := range token.Attr {
+					if attr.Name.Local == "rotation" {
+						ui.Rotation, _ = strconv.Atoi(attr.Value)
+					}
+				}
+			case "node":
+				node, err := newUINode(token.Attr)
+				if err != nil {
+					return nil, err
+				}
+				node.Parent = parent
+				if parent == nil {
+					ui.Roots = append(ui.Roots, node)
+				} else {
+					parent.Children = append(parent.Children, node)
+				}
+				parent = node
+			}
+		case xml.EndElement:
+			if token.Name.Local == "node" && parent != nil {
+				parent = parent.Parent
+			}
+		}
+	}
+	if !seenHierarchy {
+		return nil, errors.Errorf(errors.ParseError, "UI hierarchy has no hierarchy element")
+	}
+	return ui, nil
+}
+
+func newUINode(attrs []xml.Attr) (*UINode, error) {
+	node := &UINode{Attrs: map[string]string{}}
+	for _, attr := range attrs {
+		node.Attrs[attr.Name.Local] = attr.Value
+	}
+	node.Index, _ = strconv.Atoi(node.Attrs["index"])
+	node.Text = node.Attrs["text"]
+	node.ResourceID = node.Attrs["resource-id"]
+	node.Class = node.Attrs["class"]
+	node.Package = node.Attrs["package"]
+	node.ContentDesc = node.Attrs["content-desc"]
+	for name, flag := range map[string]*bool{
+		"checkable":      &node.Checkable,
+		"checked":        &node.Checked,
+		"clickable":      &node.Clickable,
+		"enabled":        &node.Enabled,
+		"focusable":      &node.Focusable,
+		"focused":        &node.Focused,
+		"scrollable":     &node.Scrollable,
+		"long-clickable": &node.LongClickable,
+		"password":       &node.Password,
+		"selected":       &node.Selected,
+	} {
+		*flag = node.Attrs[name] == "true"
+	}
+
+	if bounds, ok := node.Attrs["bounds"]; ok {
+		matches := reUIBounds.FindStringSubmatch(bounds)
+		if matches == nil {
+			return nil, errors.Errorf(errors.ParseError, "invalid UI node bounds: %s", bounds)
+		}
+		var coords [4]int
+		for i := range coords {
+			coords[i], _ = strconv.Atoi(matches[i+1])
+		}
+		node.Bounds = image.Rect(coords[0], coords[1], coords[2], coords[3])
+	}
+	return node, nil
+}
+
+// All returns all the nodes of the hierarchy, parents before their children.
+func (ui *UIHierarchy) All() []*UINode {
+	var nodes []*UINode
+	var walk func([]*UINode)
+	walk = func(children []*UINode) {
+		for _, node := range children {
+			nodes = append(nodes, node)
+			walk(node.Children)
+		}
+	}
+	walk(ui.Roots)
+	return nodes
+}
+
+// UISelector selects the nodes of a UIHierarchy that match all its fields that are set.
+type UISelector struct {
+	// ResourceID is either a full resource id, e.g. "com.example.app:id/login", or the name
+	// of the resource, e.g. "login".
+	ResourceID string
+
+	Text        *regexp.Regexp
+	ContentDesc *regexp.Regexp
+
+	// Class is either a full class name, e.g. "android.widget.Button", or a simple one, e.g.
+	// "Button".
+	Class string
+
+	Package string
+
+	// Flags that nodes must have if true.
+	Clickable bool
+	Enabled   bool
+	Checked   bool
+	Selected  bool
+	Focused   bool
+}
+
+// Match returns true if the selector selects node.
+func (s UISelector) Match(node *UINode) bool {
+	switch {
+	case s.ResourceID != "" && node.ResourceID != s.ResourceID && !strings.HasSuffix(node.ResourceID, ":id/"+s.ResourceID),
+		s.Text != nil && !s.Text.MatchString(node.Text),
+		s.ContentDesc != nil && !s.ContentDesc.MatchString(node.ContentDesc),
+		s.Class != "" && !matchUIClass(node.Class, s.Class),
+		s.Package != "" && node.Package != s.Package,
+		s.Clickable && !node.Clickable,
+		s.Enabled && !node.Enabled,
+		s.Checked && !node.Checked,
+		s.Selected && !node.Selected,
+		s.Focused && !node.Focused:
+		return false
+	}
+	return true
+}
+
+// matchUIClass returns true if class is name, or name is the simple name of class.
+func matchUIClass(class, name string) bool {
+	return class == name || strings.HasSuffix(class, "."+name)
+}
+
+// Find returns the nodes selected by s, parents before their children.
+func (ui *UIHierarchy) Find(s UISelector) []*UINode {
+	var nodes []*UINode
+	for _, node := range ui.All() {
+		if s.Match(node) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
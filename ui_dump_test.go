@@ -0,0 +1,143 @@
+package adb
+
+import (
+	"image"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testUIDump = `<?xml version='1.0' encoding='UTF-8' standalone='yes' ?>` +
+	`<hierarchy rotation="1">` +
+	`<node index="0" text="" resource-id="" class="android.widget.FrameLayout" package="com.example.app" content-desc="" checkable="false" checked="false" clickable="false" enabled="true" focusable="false" focused="false" scrollable="false" long-clickable="false" password="false" selected="false" bounds="[0,0][1080,1920]">` +
+	`<node index="0" text="" resource-id="com.example.app:id/list" class="android.widget.ListView" package="com.example.app" content-desc="" checkable="false" checked="false" clickable="false" enabled="true" focusable="true" focused="false" scrollable="true" long-clickable="false" password="false" selected="false" bounds="[0,0][1080,1000]">` +
+	`<node index="0" text="First item" resource-id="com.example.app:id/title" class="android.widget.TextView" package="com.example.app" content-desc="" checkable="false" checked="false" clickable="true" enabled="true" focusable="false" focused="false" scrollable="false" long-clickable="false" password="false" selected="false" bounds="[0,0][1080,100]" />` +
+	`<node index="1" text="Second item" resource-id="com.example.app:id/title" class="android.widget.TextView" package="com.example.app" content-desc="" checkable="false" checked="false" clickable="true" enabled="true" focusable="false" focused="false" scrollable="false" long-clickable="false" password="false" selected="true" bounds="[0,100][1080,200]" />` +
+	`</node>` +
+	`<node index="1" text="OK" resource-id="com.example.app:id/ok" class="android.widget.Button" package="com.example.app" content-desc="Confirm &amp; close" checkable="false" checked="false" clickable="true" enabled="false" focusable="true" focused="false" scrollable="false" long-clickable="false" password="false" selected="false" bounds="[100,1500][300,1600]" />` +
+	`</node>` +
+	`</hierarchy>`
+
+func TestParseUIHierarchy(t *testing.T) {
+	ui, err := ParseUIHierarchy(strings.NewReader(testUIDump))
+	require.NoError(t, err)
+	assert.Equal(t, 1, ui.Rotation)
+	require.Len(t, ui.Roots, 1)
+	assert.Len(t, ui.All(), 5)
+
+	ok := ui.Roots[0].Children[1]
+	assert.Equal(t, 1, ok.Index)
+	assert.Equal(t, "OK", ok.Text)
+	assert.Equal(t, "com.example.app:id/ok", ok.ResourceID)
+	assert.Equal(t, "android.widget.Button", ok.Class)
+	assert.Equal(t, "Confirm & close", ok.ContentDesc)
+	assert.True(t, ok.Clickable)
+	assert.True(t, ok.Focusable)
+	assert.False(t, ok.Enabled)
+	assert.Equal(t, image.Rect(100, 1500, 300, 1600), ok.Bounds)
+	assert.Equal(t, image.Pt(200, 1550), ok.Center())
+	assert.Equal(t, Tap(200, 1550), ok.Tap())
+	assert.Equal(t, ui.Roots[0], ok.Parent)
+	assert.Equal(t, `android.widget.Button com.example.app:id/ok "OK" [100,1500][300,1600]`, ok.String())
+
+	_, err = ParseUIHierarchy(strings.NewReader(`<hierarchy><node bounds="[0,0]"/></hierarchy>`))
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+	_, err = ParseUIHierarchy(strings.NewReader(`ERROR: null root node returned by UiTestAutomationBridge.`))
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+	_, err = ParseUIHierarchy(strings.NewReader(`<hierarchy><node>`))
+	assert.Equal(t, errors.ParseError, err.(*errors.Err).Code)
+}
+
+func uiNodeTexts(nodes []*UINode) []string {
+	texts := []string{}
+	for _, node := range nodes {
+		texts = append(texts, node.Text)
+	}
+	return texts
+}
+
+func TestUIHierarchyFind(t *testing.T) {
+	ui, err := ParseUIHierarchy(strings.NewReader(testUIDump))
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		selector UISelector
+		expected []string
+	}{
+		{UISelector{ResourceID: "title"}, []string{"First item", "Second item"}},
+		{UISelector{ResourceID: "com.example.app:id/ok"}, []string{"OK"}},
+		{UISelector{ResourceID: "d/ok"}, []string{}},
+		{UISelector{Text: regexp.MustCompile(`^Second`)}, []string{"Second item"}},
+		{UISelector{ContentDesc: regexp.MustCompile(`close`)}, []string{"OK"}},
+		{UISelector{Class: "Button"}, []string{"OK"}},
+		{UISelector{Class: "android.widget.TextView", Selected: true}, []string{"Second item"}},
+		{UISelector{Clickable: true, Enabled: true}, []string{"First item", "Second item"}},
+		{UISelector{Package: "com.other.app"}, []string{}},
+	} {
+		assert.Equal(t, test.expected, uiNodeTexts(ui.Find(test.selector)), "%+v", test.selector)
+	}
+}
+
+func TestUIHierarchyFindPath(t *testing.T) {
+	ui, err := ParseUIHierarchy(strings.NewReader(testUIDump))
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		path     string
+		expected []string
+	}{
+		{"//TextView", []string{"First item", "Second item"}},
+		{"/FrameLayout/*", []string{"", "OK"}},
+		{"/FrameLayout/node[2]", []string{"OK"}},
+		{"//ListView/TextView[2]", []string{"Second item"}},
+		{"//*[@resource-id='com.example.app:id/title'][1]", []string{"First item"}},
+		{`//*[contains(@text, "item") and @selected='true']`, []string{"Second item"}},
+		{"//node[starts-with(@content-desc,'Confirm')]", []string{"OK"}},
+		{"//android.widget.Button[@enabled='true']", []string{}},
+		{"//TextView[3]", []string{}},
+		{"/ListView", []string{}},
+		{"//*//TextView", []string{"First item", "Second item"}},
+	} {
+		nodes, err := ui.FindPath(test.path)
+		require.NoError(t, err, test.path)
+		assert.Equal(t, test.expected, uiNodeTexts(nodes), test.path)
+	}
+
+	for _, path := range []string{
+		"",
+		"TextView",
+		"//",
+		"//*[0]",
+		"//*[@text]",
+		"//*[@text='unterminated]",
+		"//*[contains(@text)]",
+		"//*[@text='a' or @text='b']",
+		"//*[1",
+	} {
+		_, err := ui.FindPath(path)
+		assert.Equal(t, errors.ParseError, err.(*errors.Err).Code, path)
+	}
+}
+
+func TestDumpUI(t *testing.T) {
+	d := newFakeDevice()
+	d.uiDump = testUIDump
+	client := (&Adb{d}).Device(AnyDevice())
+	ui, err := client.DumpUI()
+	require.NoError(t, err)
+	assert.Len(t, ui.All(), 5)
+	_, err = client.DumpUI()
+	require.NoError(t, err)
+
+	// Each dump goes to its own file, which is removed once it's read.
+	require.Len(t, d.Commands, 4)
+	assert.Regexp(t, `^uiautomator dump /data/local/tmp/go-adb-ui-dump-[0-9a-f]{16}\.xml$`, d.Commands[0])
+	dumpPath := strings.TrimPrefix(d.Commands[0], "uiautomator dump ")
+	assert.Equal(t, "rm -f "+dumpPath, d.Commands[1])
+	assert.NotEqual(t, d.Commands[0], d.Commands[2])
+	assert.Nil(t, d.file(dumpPath))
+}
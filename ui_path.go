@@ -0,0 +1,289 @@
+package adb
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+// uiPathStep is a step of a UI path, e.g. //Button[@text='OK'].
+type uiPathStep struct {
+	// descendant is true for //, which selects all the descendants rather than the children.
+	descendant bool
+
+	// class is the class of the nodes, or empty for any node.
+	class string
+
+	predicates [][]uiPathCondition
+}
+
+// uiPathCondition is a condition of a predicate, which are joined by "and". Conditions on the
+// position of nodes have a 1-based position, the others an attribute.
+type uiPathCondition struct {
+	position int
+	function string
+	attr     string
+	value    string
+}
+
+/*
+FindPath returns the nodes selected by an XPath-like path, e.g.
+	//android.widget.ListView/*[2]
+	//Button[@text='OK' and @enabled='true']
+	//*[contains(@resource-id,'login')]
+	/FrameLayout//TextView[starts-with(@text,"Hello")][1]
+Steps are separated by / to select the children of the nodes selected so far, or by // to
+select all their descendants. Paths start from the roots of the windows.
+
+Steps select nodes by class, full or simple, or any node with * or node. Their predicates can be
+the 1-based position of the node among the children of its parent that the step selects, or
+conditions on its attributes, which are named like in the dump, e.g. @resource-id or @checked.
+Conditions can compare attributes with =, or use contains() and starts-with(), and can be
+joined with "and".
+*/
+func (ui *UIHierarchy) FindPath(path string) ([]*UINode, error) {
+	steps, err := parseUIPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The virtual root is the parent of the roots of the hierarchy.
+	nodes := []*UINode{{Children: ui.Roots}}
+	for _, step := range steps {
+		var selected []*UINode
+		seen := map[*UINode]bool{}
+		for _, node := range nodes {
+			parents := []*UINode{node}
+			if step.descendant {
+				parents = append(parents, (&UIHierarchy{Roots: node.Children}).All()...)
+			}
+			for _, parent := range parents {
+				for _, child := range step.selectChildren(parent) {
+					if !seen[child] {
+						seen[child] = true
+						selected = append(selected, child)
+					}
+				}
+			}
+		}
+		nodes = selected
+	}
+
+	// Return nodes in document order, since descendants of different nodes may be interleaved.
+	var result []*UINode
+	selected := map[*UINode]bool{}
+	for _, node := range nodes {
+		selected[node] = true
+	}
+	for _, node := range ui.All() {
+		if selected[node] {
+			result = append(result, node)
+		}
+	}
+	return result, nil
+}
+
+// selectChildren returns the children of parent that the step selects.
+func (step uiPathStep) selectChildren(parent *UINode) []*UINode {
+	var nodes []*UINode
+	for _, child := range parent.Children {
+		if step.class == "" || matchUIClass(child.Class, step.class) {
+			nodes = append(nodes, child)
+		}
+	}
+	for _, predicate := range step.predicates {
+		var filtered []*UINode
+		for i, node := range nodes {
+			if matchUIPredicate(predicate, node, i+1) {
+				filtered = append(filtered, node)
+			}
+		}
+		nodes = filtered
+	}
+	return nodes
+}
+
+func matchUIPredicate(predicate []uiPathCondition, node *UINode, position int) bool {
+	for _, condition := range predicate {
+		if condition.position > 0 {
+			if position != condition.position {
+				return false
+			}
+			continue
+		}
+		value, ok := node.Attrs[condition.attr]
+		switch {
+		case !ok,
+			condition.function == "" && value != condition.value,
+			condition.function == "contains" && !strings.Contains(value, condition.value),
+			condition.function == "starts-with" && !strings.HasPrefix(value, condition.value):
+			return false
+		}
+	}
+	return true
+}
+
+// uiPathParser parses a UI path, see UIHierarchy.FindPath.
+type uiPathParser struct {
+	path string
+	pos  int
+}
+
+func parseUIPath(path string) ([]uiPathStep, error) {
+	p := &uiPathParser{path: path}
+	var steps []uiPathStep
+	for p.pos < len(p.path) {
+		if !p.consume("/") {
+			return nil, p.errorAt("expected /")
+		}
+		step := uiPathStep{descendant: p.consume("/")}
+
+		start := p.pos
+		for p.pos < len(p.path) && !strings.ContainsRune("/[", rune(p.path[p.pos])) {
+			p.pos++
+		}
+		step.class = strings.TrimSpace(p.path[start:p.pos])
+		if step.class == "" {
+			return nil, p.errorAt("expected a class")
+		}
+		if step.class == "*" || step.class == "node" {
+			step.class = ""
+		}
+
+		for p.consume("[") {
+			predicate, err := p.parsePredicate()
+			if err != nil {
+				return nil, err
+			}
+			step.predicates = append(step.predicates, predicate)
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		return nil, p.errorAt("empty path")
+	}
+	return steps, nil
+}
+
+// parsePredicate parses the conditions of a predicate until its closing bracket.
+func (p *uiPathParser) parsePredicate() ([]uiPathCondition, error) {
+	var conditions []uiPathCondition
+	for {
+		p.skipSpaces()
+		condition := uiPathCondition{function: p.consumeFunction()}
+		switch {
+		case condition.function == "" && p.pos < len(p.path) && p.path[p.pos] >= '0' && p.path[p.pos] <= '9':
+			start := p.pos
+			for p.pos < len(p.path) && p.path[p.pos] >= '0' && p.path[p.pos] <= '9' {
+				p.pos++
+			}
+			condition.position, _ = strconv.Atoi(p.path[start:p.pos])
+			if condition.position == 0 {
+				return nil, p.errorAt("positions start at 1")
+			}
+		case condition.function != "":
+			attr, err := p.parseAttr()
+			if err != nil {
+				return nil, err
+			}
+			p.skipSpaces()
+			if !p.consume(",") {
+				return nil, p.errorAt("expected ,")
+			}
+			p.skipSpaces()
+			value, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			p.skipSpaces()
+			if !p.consume(")") {
+				return nil, p.errorAt("expected )")
+			}
+			condition.attr, condition.value = attr, value
+		default:
+			attr, err := p.parseAttr()
+			if err != nil {
+				return nil, err
+			}
+			p.skipSpaces()
+			if !p.consume("=") {
+				return nil, p.errorAt("expected =")
+			}
+			p.skipSpaces()
+			value, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			condition.attr, condition.value = attr, value
+		}
+		conditions = append(conditions, condition)
+
+		p.skipSpaces()
+		if p.consume("]") {
+			return conditions, nil
+		}
+		if !p.consume("and ") {
+			return nil, p.errorAt("expected ] or and")
+		}
+	}
+}
+
+func (p *uiPathParser) parseAttr() (string, error) {
+	if !p.consume("@") {
+		return "", p.errorAt("expected @attribute")
+	}
+	start := p.pos
+	for p.pos < len(p.path) && (p.path[p.pos] == '-' || p.path[p.pos] >= 'a' && p.path[p.pos] <= 'z' ||
+		p.path[p.pos] >= 'A' && p.path[p.pos] <= 'Z') {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorAt("expected an attribute name")
+	}
+	return p.path[start:p.pos], nil
+}
+
+// parseString parses a string literal, in single or double quotes.
+func (p *uiPathParser) parseString() (string, error) {
+	if p.pos >= len(p.path) || p.path[p.pos] != '\'' && p.path[p.pos] != '"' {
+		return "", p.errorAt("expected a quoted string")
+	}
+	quote := p.path[p.pos]
+	end := strings.IndexByte(p.path[p.pos+1:], quote)
+	if end < 0 {
+		return "", p.errorAt("unterminated string")
+	}
+	value := p.path[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	return value, nil
+}
+
+// consumeFunction consumes the name of a function and its opening parenthesis, and returns the
+// name, or an empty string if there's no function.
+func (p *uiPathParser) consumeFunction() string {
+	for _, function := range []string{"contains", "starts-with"} {
+		if p.consume(function + "(") {
+			return function
+		}
+	}
+	return ""
+}
+
+func (p *uiPathParser) consume(s string) bool {
+	if strings.HasPrefix(p.path[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *uiPathParser) skipSpaces() {
+	for p.pos < len(p.path) && p.path[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *uiPathParser) errorAt(message string) error {
+	return errors.Errorf(errors.ParseError, "invalid UI path %q at %d: %s", p.path, p.pos, message)
+}
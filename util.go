@@ -1,7 +1,9 @@
 package adb
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net"
 	"reflect"
 	"regexp"
@@ -13,6 +15,7 @@ import (
 
 var (
 	whitespaceRegex = regexp.MustCompile(`^\s*$`)
+	shellSafeRegex  = regexp.MustCompile(`^[\w@%+=:,./-]+$`)
 )
 
 func containsWhitespace(str string) bool {
@@ -23,6 +26,18 @@ func isBlank(str string) bool {
 	return whitespaceRegex.MatchString(str)
 }
 
+/*
+quoteShellArg returns str as a single argument for the shell of the device. Words the shell
+doesn't interpret are returned unchanged; anything else, including the empty string, is
+single-quoted, so $, *, ;, & and backticks reach the command as they are.
+*/
+func quoteShellArg(str string) string {
+	if shellSafeRegex.MatchString(str) {
+		return str
+	}
+	return "'" + strings.ReplaceAll(str, "'", `'\''`) + "'"
+}
+
 func wrapClientError(err error, client interface{}, operation string, args ...interface{}) error {
 	if err == nil {
 		return nil
@@ -41,6 +56,45 @@ func wrapClientError(err error, client interface{}, operation string, args ...in
 	}
 }
 
+// wrapContextErr returns a ContextCanceled error caused by ctx.Err() if ctx is done, since err was
+// then most likely caused by the connection being closed when ctx was canceled.
+// Otherwise returns err unchanged.
+func wrapContextErr(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	return errors.WrapErrorf(ctx.Err(), errors.ContextCanceled, "operation canceled: %v", err)
+}
+
+// contextReadCloser reports errors caused by its context being done as ContextCanceled errors.
+type contextReadCloser struct {
+	ctx context.Context
+	io.ReadCloser
+}
+
+func (r *contextReadCloser) Read(buf []byte) (int, error) {
+	n, err := r.ReadCloser.Read(buf)
+	if err == io.EOF {
+		return n, err
+	}
+	return n, wrapContextErr(r.ctx, err)
+}
+
+// contextWriteCloser reports errors caused by its context being done as ContextCanceled errors.
+type contextWriteCloser struct {
+	ctx context.Context
+	io.WriteCloser
+}
+
+func (w *contextWriteCloser) Write(buf []byte) (int, error) {
+	n, err := w.WriteCloser.Write(buf)
+	return n, wrapContextErr(w.ctx, err)
+}
+
+func (w *contextWriteCloser) Close() error {
+	return wrapContextErr(w.ctx, w.WriteCloser.Close())
+}
+
 // Get a free port.
 func getFreePort() (port int, err error) {
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
@@ -1,8 +1,11 @@
 package adb
 
 import (
+	"context"
+	stderrors "errors"
 	"testing"
 
+	"github.com/kvnxiao/go-adb/internal/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -25,3 +28,34 @@ func TestIsBlankWhenJustWhitespace(t *testing.T) {
 func TestIsBlankNo(t *testing.T) {
 	assert.False(t, isBlank("     h   "))
 }
+
+func TestQuoteShellArg(t *testing.T) {
+	assert.Equal(t, "com.example/.Main", quoteShellArg("com.example/.Main"))
+	assert.Equal(t, `'hello world'`, quoteShellArg("hello world"))
+	assert.Equal(t, `''`, quoteShellArg(""))
+	assert.Equal(t, `'*'`, quoteShellArg("*"))
+	assert.Equal(t, `'it'\''s "quoted" $HOME'`, quoteShellArg(`it's "quoted" $HOME`))
+}
+
+func TestWrapContextErrNotDone(t *testing.T) {
+	err := errors.Errorf(errors.NetworkError, "broken pipe")
+	assert.Equal(t, err, wrapContextErr(context.Background(), err))
+	assert.NoError(t, wrapContextErr(context.Background(), nil))
+}
+
+func TestWrapContextErrDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := wrapContextErr(ctx, errors.Errorf(errors.NetworkError, "use of closed network connection"))
+	assert.True(t, HasErrCode(err, ContextCanceled))
+	assert.True(t, stderrors.Is(err, context.Canceled))
+}
+
+func TestTcpDialerDialContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := tcpDialer{}.DialContext(ctx, "127.0.0.1:1")
+	assert.Error(t, err)
+}
@@ -1,6 +1,11 @@
 package wire
 
-import "github.com/kvnxiao/go-adb/internal/errors"
+import (
+	"context"
+	"sync"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
 
 const (
 	// The official implementation of adb imposes an undocumented 255-byte limit
@@ -32,10 +37,38 @@ You should still always call Close() when you're done with the connection.
 type Conn struct {
 	Scanner
 	Sender
+
+	// Stops the goroutine started by WatchContext, nil if the connection isn't being watched.
+	stopWatching func()
 }
 
 func NewConn(scanner Scanner, sender Sender) *Conn {
-	return &Conn{scanner, sender}
+	return &Conn{Scanner: scanner, Sender: sender}
+}
+
+// WatchContext closes the connection as soon as ctx is done, which unblocks any reads or
+// writes that are in progress. Watching stops when the connection, or any sync or shell
+// connection created from it, is closed.
+func (c *Conn) WatchContext(ctx context.Context) {
+	if ctx.Done() == nil {
+		// The context can never be canceled.
+		return
+	}
+
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	c.stopWatching = func() {
+		stopOnce.Do(func() { close(stop) })
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Sender.Close()
+			c.Scanner.Close()
+		case <-stop:
+		}
+	}()
 }
 
 // NewSyncConn returns connection that can operate in sync mode.
@@ -43,8 +76,20 @@ func NewConn(scanner Scanner, sender Sender) *Conn {
 // to a specific device), or the return connection will return an error.
 func (c *Conn) NewSyncConn() *SyncConn {
 	return &SyncConn{
-		SyncScanner: c.Scanner.NewSyncScanner(),
-		SyncSender:  c.Sender.NewSyncSender(),
+		SyncScanner:  c.Scanner.NewSyncScanner(),
+		SyncSender:   c.Sender.NewSyncSender(),
+		stopWatching: c.stopWatching,
+	}
+}
+
+// NewShellConn returns connection that can operate in shell protocol mode.
+// The connection must already have been switched (by sending a "shell,v2" request
+// to a specific device), or the returned connection will return an error.
+func (c *Conn) NewShellConn() *ShellConn {
+	return &ShellConn{
+		ShellScanner: NewShellScanner(c.Scanner),
+		ShellSender:  NewShellSender(c.Sender),
+		stopWatching: c.stopWatching,
 	}
 }
 
@@ -74,6 +119,10 @@ func (conn *Conn) RoundTripSingleNoResponse(req []byte) (err error) {
 }
 
 func (conn *Conn) Close() error {
+	if conn.stopWatching != nil {
+		conn.stopWatching()
+	}
+
 	errs := struct {
 		SenderErr  error
 		ScannerErr error
@@ -0,0 +1,45 @@
+package wire
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnWatchContextClosesOnCancel(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	conn := NewConn(NewScanner(client), NewSender(client))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn.WatchContext(ctx)
+
+	errC := make(chan error)
+	go func() {
+		_, err := conn.ReadMessage()
+		errC <- err
+	}()
+	cancel()
+
+	select {
+	case err := <-errC:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("read wasn't unblocked by canceling the context")
+	}
+}
+
+func TestConnWatchContextStopsOnClose(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	conn := NewConn(NewScanner(client), NewSender(client))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn.WatchContext(ctx)
+
+	assert.NoError(t, conn.Close())
+}
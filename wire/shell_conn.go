@@ -0,0 +1,144 @@
+package wire
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+)
+
+// ShellPacketID identifies the stream a shell protocol packet belongs to.
+type ShellPacketID byte
+
+// Packet IDs used by the shell protocol. Values are taken from
+// https://android.googlesource.com/platform/system/core/+/master/adb/shell_protocol.h.
+const (
+	ShellIDStdin            ShellPacketID = 0
+	ShellIDStdout           ShellPacketID = 1
+	ShellIDStderr           ShellPacketID = 2
+	ShellIDExit             ShellPacketID = 3
+	ShellIDCloseStdin       ShellPacketID = 4
+	ShellIDWindowSizeChange ShellPacketID = 5
+	ShellIDInvalid          ShellPacketID = 255
+)
+
+const (
+	// Shell packets carry at most this many bytes of data.
+	ShellMaxPacketSize = 32 * 1024
+
+	// Devices send packets of up to adb's maximum payload, 1 MiB on recent versions. Longer
+	// packets are rejected rather than allocated, since their length can only be corrupt.
+	shellMaxReadPacketSize = 1024 * 1024
+)
+
+/*
+ShellConn is a connection to a device running the "shell,v2" service.
+
+Unlike the exec service, which just streams raw bytes, the shell protocol multiplexes
+stdin, stdout, stderr and the process' exit code over the same connection by
+prefixing every chunk of data with a header:
+	1 byte:  packet ID (see ShellPacketID)
+	4 bytes: data length, little-endian
+*/
+type ShellConn struct {
+	ShellScanner
+	ShellSender
+
+	// Inherited from the Conn this connection was created from, see Conn.WatchContext.
+	stopWatching func()
+}
+
+type ShellScanner interface {
+	io.Closer
+
+	// ReadPacket reads the next packet header and returns its ID and data.
+	ReadPacket() (ShellPacketID, []byte, error)
+}
+
+type ShellSender interface {
+	io.Closer
+
+	// SendPacket sends data with a header for id.
+	// If data is bigger than ShellMaxPacketSize, it returns an assertion error.
+	SendPacket(id ShellPacketID, data []byte) error
+}
+
+func NewShellScanner(r io.Reader) ShellScanner {
+	return &realShellScanner{r}
+}
+
+func NewShellSender(w io.Writer) ShellSender {
+	return &realShellSender{w}
+}
+
+type realShellScanner struct {
+	io.Reader
+}
+
+func (s *realShellScanner) ReadPacket() (ShellPacketID, []byte, error) {
+	header := make([]byte, 5)
+	n, err := io.ReadFull(s.Reader, header)
+	if err == io.ErrUnexpectedEOF {
+		return ShellIDInvalid, nil, errIncompleteMessage("shell packet header", n, len(header))
+	} else if err != nil {
+		// A clean EOF between packets is passed through untouched so callers can detect it.
+		if err == io.EOF {
+			return ShellIDInvalid, nil, err
+		}
+		return ShellIDInvalid, nil, errors.WrapErrorf(err, errors.NetworkError, "error reading shell packet header")
+	}
+
+	length := binary.LittleEndian.Uint32(header[1:])
+	if length > shellMaxReadPacketSize {
+		return ShellIDInvalid, nil, errors.Errorf(errors.NetworkError,
+			"shell packet length exceeds maximum: %d", length)
+	}
+	data := make([]byte, length)
+	n, err = io.ReadFull(s.Reader, data)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return ShellIDInvalid, nil, errIncompleteMessage("shell packet data", n, int(length))
+	} else if err != nil {
+		return ShellIDInvalid, nil, errors.WrapErrorf(err, errors.NetworkError, "error reading shell packet data")
+	}
+
+	return ShellPacketID(header[0]), data, nil
+}
+
+func (s *realShellScanner) Close() error {
+	if closer, ok := s.Reader.(io.Closer); ok {
+		return errors.WrapErrorf(closer.Close(), errors.NetworkError, "error closing shell scanner")
+	}
+	return nil
+}
+
+type realShellSender struct {
+	io.Writer
+}
+
+func (s *realShellSender) SendPacket(id ShellPacketID, data []byte) error {
+	if len(data) > ShellMaxPacketSize {
+		return errors.AssertionErrorf("data must be <= %d in length", ShellMaxPacketSize)
+	}
+
+	packet := make([]byte, 5+len(data))
+	packet[0] = byte(id)
+	binary.LittleEndian.PutUint32(packet[1:], uint32(len(data)))
+	copy(packet[5:], data)
+	return writeFully(s.Writer, packet)
+}
+
+func (s *realShellSender) Close() error {
+	if closer, ok := s.Writer.(io.Closer); ok {
+		return errors.WrapErrorf(closer.Close(), errors.NetworkError, "error closing shell sender")
+	}
+	return nil
+}
+
+// Close closes both the sender and the scanner, and returns any errors.
+func (c ShellConn) Close() error {
+	if c.stopWatching != nil {
+		c.stopWatching()
+	}
+	return errors.CombineErrs("error closing ShellConn", errors.NetworkError,
+		c.ShellScanner.Close(), c.ShellSender.Close())
+}
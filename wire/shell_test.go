@@ -0,0 +1,54 @@
+package wire
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/kvnxiao/go-adb/internal/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShellSendPacket(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewShellSender(&buf)
+	err := s.SendPacket(ShellIDStdin, []byte("hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("\000\005\000\000\000hello"), buf.Bytes())
+}
+
+func TestShellSendPacketTooLong(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewShellSender(&buf)
+	err := s.SendPacket(ShellIDStdin, make([]byte, ShellMaxPacketSize+1))
+	assert.Equal(t, errors.AssertionErrorf("data must be <= %d in length", ShellMaxPacketSize), err)
+}
+
+func TestShellReadPacket(t *testing.T) {
+	s := NewShellScanner(bytes.NewReader([]byte("\002\003\000\000\000err\003\001\000\000\000\001")))
+
+	id, data, err := s.ReadPacket()
+	assert.NoError(t, err)
+	assert.Equal(t, ShellIDStderr, id)
+	assert.Equal(t, "err", string(data))
+
+	id, data, err = s.ReadPacket()
+	assert.NoError(t, err)
+	assert.Equal(t, ShellIDExit, id)
+	assert.Equal(t, []byte{1}, data)
+
+	_, _, err = s.ReadPacket()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestShellReadPacketTooShort(t *testing.T) {
+	s := NewShellScanner(bytes.NewReader([]byte("\001\005\000\000\000h")))
+	_, _, err := s.ReadPacket()
+	assert.Equal(t, errIncompleteMessage("shell packet data", 1, 5), err)
+}
+
+func TestShellReadPacketTooLong(t *testing.T) {
+	s := NewShellScanner(bytes.NewReader([]byte("\001\377\377\377\377h")))
+	_, _, err := s.ReadPacket()
+	assert.Equal(t, errors.Errorf(errors.NetworkError, "shell packet length exceeds maximum: %d", 0xffffffff), err)
+}
@@ -20,6 +20,8 @@ Lengths are binary-encoded (little-endian) instead of hex.
 Notes on Encoding
 
 Length headers and other integers are encoded in little-endian, with 32 bits.
+The v2 stat and list requests (STA2, LST2 and LIS2) also use 64-bit integers, for
+sizes, inode numbers and timestamps.
 
 File mode seems to be encoded as POSIX file mode.
 
@@ -28,10 +30,16 @@ Modification time seems to be the Unix timestamp format, i.e. seconds since Epoc
 type SyncConn struct {
 	SyncScanner
 	SyncSender
+
+	// Inherited from the Conn this connection was created from, see Conn.WatchContext.
+	stopWatching func()
 }
 
 // Close closes both the sender and the scanner, and returns any errors.
 func (c SyncConn) Close() error {
+	if c.stopWatching != nil {
+		c.stopWatching()
+	}
 	return errors.CombineErrs("error closing SyncConn", errors.NetworkError,
 		c.SyncScanner.Close(), c.SyncSender.Close())
 }
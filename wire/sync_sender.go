@@ -15,6 +15,7 @@ type SyncSender interface {
 	// SendOctetString sends a 4-byte string.
 	SendOctetString(string) error
 	SendInt32(int32) error
+	SendInt64(int64) error
 	SendFileMode(os.FileMode) error
 	SendTime(time.Time) error
 
@@ -47,6 +48,11 @@ func (s *realSyncSender) SendInt32(val int32) error {
 		errors.NetworkError, "error sending int on sync sender")
 }
 
+func (s *realSyncSender) SendInt64(val int64) error {
+	return errors.WrapErrorf(binary.Write(s.Writer, binary.LittleEndian, val),
+		errors.NetworkError, "error sending int64 on sync sender")
+}
+
 func (s *realSyncSender) SendFileMode(mode os.FileMode) error {
 	return errors.WrapErrorf(binary.Write(s.Writer, binary.LittleEndian, mode),
 		errors.NetworkError, "error sending filemode on sync sender")
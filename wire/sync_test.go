@@ -79,3 +79,13 @@ func TestSyncReadBytes(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "hello", string(str))
 }
+
+func TestSyncReadInt64(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, NewSyncSender(&buf).SendInt64(1<<40|5))
+	assert.Equal(t, []byte{5, 0, 0, 0, 0, 1, 0, 0}, buf.Bytes())
+
+	value, err := NewSyncScanner(&buf).ReadInt64()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1<<40|5), value)
+}